@@ -3,6 +3,7 @@ package gomap
 import (
 	"errors"
 	"testing"
+	"time"
 )
 
 func TestParseCLIOptionsTopPortsAlias(t *testing.T) {
@@ -102,6 +103,21 @@ func TestParseCLIOptionsUDPRejectsSYN(t *testing.T) {
 	}
 }
 
+func TestParseCLIOptionsRawScanTypes(t *testing.T) {
+	for arg, want := range map[string]string{"fin": "fin", "NULL": "null", "xmas": "xmas", "ack": "ack"} {
+		opts, err := ParseCLIOptions([]string{"--scan-type", arg, "10.0.11.6"})
+		if err != nil {
+			t.Fatalf("%s: unexpected error: %v", arg, err)
+		}
+		if opts.ScanType != want {
+			t.Fatalf("%s: unexpected scan type %q", arg, opts.ScanType)
+		}
+	}
+	if _, err := ParseCLIOptions([]string{"-u", "--scan-type", "xmas", "10.0.11.6"}); err == nil {
+		t.Fatal("expected error for udp with xmas scan type")
+	}
+}
+
 func TestParseCLIOptionsScanTypeInvalid(t *testing.T) {
 	_, err := ParseCLIOptions([]string{"--scan-type", "udp", "10.0.11.6"})
 	if err == nil {
@@ -253,3 +269,277 @@ func TestParseCLIOptionsScanTypeCaseInsensitive(t *testing.T) {
 		t.Fatalf("expected scan type lowercased to syn, got %q", opts.ScanType)
 	}
 }
+
+func TestParseCLIOptionsHostParallelism(t *testing.T) {
+	opts, err := ParseCLIOptions([]string{"--host-parallelism", "8", "10.0.11.0/24"})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if opts.HostParallel != 8 {
+		t.Fatalf("expected host parallelism 8, got %d", opts.HostParallel)
+	}
+}
+
+func TestParseCLIOptionsNegativeHostParallelismRejected(t *testing.T) {
+	_, err := ParseCLIOptions([]string{"--host-parallelism", "-2", "10.0.11.0/24"})
+	if err == nil {
+		t.Fatal("expected error for negative --host-parallelism")
+	}
+}
+
+func TestParseCLIOptionsShowClosedAndFiltered(t *testing.T) {
+	opts, err := ParseCLIOptions([]string{"--show-closed", "--show-filtered", "--format", "csv", "10.0.11.6"})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !opts.ShowClosed || !opts.ShowFiltered {
+		t.Fatalf("expected show-closed and show-filtered enabled: %+v", opts)
+	}
+}
+
+func TestParseCLIOptionsCheckpointAndResume(t *testing.T) {
+	opts, err := ParseCLIOptions([]string{"--checkpoint", "new.ckpt", "--resume", "old.ckpt", "10.0.11.0/24"})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if opts.CheckpointPath != "new.ckpt" || opts.ResumePath != "old.ckpt" {
+		t.Fatalf("unexpected checkpoint paths: %+v", opts)
+	}
+}
+
+func TestParseCLIOptionsBlankResumeRejected(t *testing.T) {
+	_, err := ParseCLIOptions([]string{"--resume", "  ", "10.0.11.6"})
+	if err == nil {
+		t.Fatal("expected error for blank --resume path")
+	}
+}
+
+func TestParseCLIOptionsStreamJSONL(t *testing.T) {
+	opts, err := ParseCLIOptions([]string{"--stream", "--format", "jsonl", "10.0.11.6"})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !opts.StreamFlag {
+		t.Fatal("expected stream enabled")
+	}
+}
+
+func TestParseCLIOptionsStreamRejectsJSON(t *testing.T) {
+	_, err := ParseCLIOptions([]string{"--stream", "--json", "10.0.11.6"})
+	if err == nil {
+		t.Fatal("expected error for --stream with json output")
+	}
+}
+
+func TestParseCLIOptionsIPv6Flag(t *testing.T) {
+	opts, err := ParseCLIOptions([]string{"-6", "lab-host.internal"})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !opts.IPv6Flag {
+		t.Fatal("expected -6 to enable IPv6 resolution")
+	}
+}
+
+func TestParseCLIOptionsTargetFileWithoutHost(t *testing.T) {
+	opts, err := ParseCLIOptions([]string{"-iL", "scope.txt", "--exclude", "10.0.11.1,10.0.12.0/24", "--exclude-file", "oos.txt"})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if opts.TargetFile != "scope.txt" || opts.Host != "" {
+		t.Fatalf("unexpected target options: %+v", opts)
+	}
+	if opts.Exclude != "10.0.11.1,10.0.12.0/24" || opts.ExcludeFile != "oos.txt" {
+		t.Fatalf("unexpected exclude options: %+v", opts)
+	}
+}
+
+func TestParseCLIOptionsMissingTargetRejected(t *testing.T) {
+	_, err := ParseCLIOptions([]string{"-p", "80"})
+	if !errors.Is(err, errUsage) {
+		t.Fatalf("expected usage error without host or -iL, got %v", err)
+	}
+}
+
+func TestParseCLIOptionsRandomizeHosts(t *testing.T) {
+	opts, err := ParseCLIOptions([]string{"-nd", "--randomize-hosts", "10.16.0.0/12"})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !opts.RandomizeHosts || opts.Host != "10.16.0.0/12" {
+		t.Fatalf("unexpected options: %+v", opts)
+	}
+}
+
+func TestParseCLIOptionsDiscoveryMethods(t *testing.T) {
+	opts, err := ParseCLIOptions([]string{"--discovery", "ICMP,tcp,icmp", "10.0.11.0/24"})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if opts.DiscoveryFlag != "icmp,tcp" {
+		t.Fatalf("expected normalized methods, got %q", opts.DiscoveryFlag)
+	}
+	if _, err := ParseCLIOptions([]string{"--discovery", "ndp", "10.0.11.0/24"}); err == nil {
+		t.Fatal("expected error for unknown discovery method")
+	}
+	if _, err := ParseCLIOptions([]string{"-nd", "--discovery", "icmp", "10.0.11.0/24"}); err == nil {
+		t.Fatal("expected error for --discovery with -nd")
+	}
+}
+
+func TestParseCLIOptionsCheckRST(t *testing.T) {
+	opts, err := ParseCLIOptions([]string{"--scan-type", "syn", "--check-rst", "10.0.11.0/24"})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !opts.CheckRST || opts.ScanType != "syn" {
+		t.Fatalf("unexpected options: %+v", opts)
+	}
+}
+
+func TestParseCLIOptionsOSDetect(t *testing.T) {
+	opts, err := ParseCLIOptions([]string{"-O", "--scan-type", "syn", "10.0.11.6"})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !opts.OSDetect || opts.ScanType != "syn" {
+		t.Fatalf("unexpected options: %+v", opts)
+	}
+}
+
+func TestParseCLIOptionsOSPing(t *testing.T) {
+	opts, err := ParseCLIOptions([]string{"-O", "--os-ping", "10.0.11.6"})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !opts.OSDetect || !opts.OSPing {
+		t.Fatalf("unexpected options: %+v", opts)
+	}
+	if _, err := ParseCLIOptions([]string{"--os-ping", "10.0.11.6"}); err == nil {
+		t.Fatal("expected error because --os-ping requires -O")
+	}
+}
+
+func TestParseCLIOptionsTraceroute(t *testing.T) {
+	opts, err := ParseCLIOptions([]string{"--traceroute", "-p", "22", "10.0.11.6"})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !opts.Traceroute {
+		t.Fatalf("unexpected options: %+v", opts)
+	}
+	if _, err := ParseCLIOptions([]string{"--traceroute", "-u", "10.0.11.6"}); err == nil {
+		t.Fatal("expected --traceroute with -u to be rejected")
+	}
+}
+
+func TestParseCLIOptionsSourceBinding(t *testing.T) {
+	opts, err := ParseCLIOptions([]string{"-e", "eth1", "-S", "10.0.11.2", "--source-port", "53", "10.0.11.6"})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if opts.Interface != "eth1" || opts.SourceIP != "10.0.11.2" || opts.SourcePort != 53 {
+		t.Fatalf("unexpected options: %+v", opts)
+	}
+	if _, err := ParseCLIOptions([]string{"-S", "10.0.11", "10.0.11.6"}); err == nil {
+		t.Fatal("expected invalid -S address to be rejected")
+	}
+	if _, err := ParseCLIOptions([]string{"--source-port", "70000", "10.0.11.6"}); err == nil {
+		t.Fatal("expected out of range --source-port to be rejected")
+	}
+}
+
+func TestParseCLIOptionsProxy(t *testing.T) {
+	opts, err := ParseCLIOptions([]string{"--proxy", "socks5://127.0.0.1:1080,http://10.1.0.1:3128", "-s", "intranet.corp.local"})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if opts.Proxy != "socks5://127.0.0.1:1080,http://10.1.0.1:3128" {
+		t.Fatalf("unexpected options: %+v", opts)
+	}
+	for _, args := range [][]string{
+		{"--proxy", "ftp://127.0.0.1", "10.0.11.6"},
+		{"--proxy", "socks5://127.0.0.1:1080", "--scan-type", "syn", "10.0.11.6"},
+		{"--proxy", "socks5://127.0.0.1:1080", "-u", "10.0.11.6"},
+		{"--proxy", "socks5://127.0.0.1:1080", "-O", "10.0.11.6"},
+	} {
+		if _, err := ParseCLIOptions(args); err == nil {
+			t.Fatalf("expected %v to be rejected", args)
+		}
+	}
+}
+
+func TestParseCLIOptionsCongestion(t *testing.T) {
+	opts, err := ParseCLIOptions([]string{"--congestion", "--workers", "400", "10.0.11.6"})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !opts.Congestion || opts.Workers != 400 {
+		t.Fatalf("unexpected options: %+v", opts)
+	}
+	for _, args := range [][]string{
+		{"--congestion", "-u", "10.0.11.6"},
+		{"--congestion", "--scan-type", "syn", "10.0.11.6"},
+	} {
+		if _, err := ParseCLIOptions(args); err == nil {
+			t.Fatalf("expected %v to be rejected", args)
+		}
+	}
+}
+
+func TestParseCLIOptionsMaxRate(t *testing.T) {
+	opts, err := ParseCLIOptions([]string{"--max-rate", "300", "--burst", "20", "--host-parallelism", "8", "10.0.11.0/24"})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if opts.MaxRate != 300 || opts.Burst != 20 {
+		t.Fatalf("unexpected options: %+v", opts)
+	}
+	for _, args := range [][]string{
+		{"--max-rate", "-1", "10.0.11.6"},
+		{"--max-rate", "300", "--burst", "-5", "10.0.11.6"},
+		{"--burst", "20", "10.0.11.6"},
+	} {
+		if _, err := ParseCLIOptions(args); err == nil {
+			t.Fatalf("expected %v to be rejected", args)
+		}
+	}
+}
+
+func TestParseCLIOptionsTimeBudgets(t *testing.T) {
+	opts, err := ParseCLIOptions([]string{"--host-timeout", "90s", "--max-scan-time", "30m", "10.0.11.0/24"})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if opts.HostTimeout != 90*time.Second || opts.MaxScanTime != 30*time.Minute {
+		t.Fatalf("unexpected options: %+v", opts)
+	}
+	for _, args := range [][]string{
+		{"--host-timeout", "-1s", "10.0.11.6"},
+		{"--max-scan-time", "-5m", "10.0.11.6"},
+		{"--host-timeout", "90", "10.0.11.6"},
+	} {
+		if _, err := ParseCLIOptions(args); err == nil {
+			t.Fatalf("expected %v to be rejected", args)
+		}
+	}
+}
+
+func TestParseCLIOptionsCheckTarpit(t *testing.T) {
+	opts, err := ParseCLIOptions([]string{"-s", "--check-tarpit", "--tarpit-skip-service", "--scan-type", "syn", "10.0.11.0/24"})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !opts.CheckTarpit || !opts.TarpitSkipSvc {
+		t.Fatalf("unexpected options: %+v", opts)
+	}
+	for _, args := range [][]string{
+		{"--check-tarpit", "-u", "10.0.11.6"},
+		{"--check-tarpit", "--scan-type", "ack", "10.0.11.6"},
+		{"--tarpit-skip-service", "-s", "10.0.11.6"},
+	} {
+		if _, err := ParseCLIOptions(args); err == nil {
+			t.Fatalf("expected %v to be rejected", args)
+		}
+	}
+}
@@ -1,9 +1,12 @@
 package gomap
 
 import (
+	"context"
 	"errors"
 	"fmt"
 	"os"
+	"os/signal"
+	"syscall"
 
 	"github.com/NexusFireMan/gomap/v2/pkg/app"
 	"github.com/NexusFireMan/gomap/v2/pkg/output"
@@ -54,17 +57,23 @@ func Run() {
 
 	req := app.ScanRequest{
 		Target:          opts.Host,
+		TargetFile:      opts.TargetFile,
+		Exclude:         opts.Exclude,
+		ExcludeFile:     opts.ExcludeFile,
 		PortsFlag:       opts.PortsFlag,
 		ScanType:        opts.ScanType,
 		UDP:             opts.UDPFlag,
+		IPv6:            opts.IPv6Flag,
 		ExcludePorts:    opts.ExcludePorts,
 		TopPorts:        opts.TopPorts,
 		Rate:            opts.Rate,
 		MaxHosts:        opts.MaxHosts,
+		HostParallelism: opts.HostParallel,
 		ServiceDetect:   opts.ServiceFlag,
 		DeepVersion:     opts.DeepVersionFlag,
 		GhostMode:       opts.GhostFlag,
 		NoDiscovery:     opts.NoDiscovery,
+		Discovery:       opts.DiscoveryFlag,
 		Format:          opts.FormatFlag,
 		OutputPath:      opts.OutPath,
 		TimeoutMS:       opts.TimeoutMS,
@@ -74,14 +83,50 @@ func Run() {
 		MaxTimeoutMS:    opts.MaxTimeoutMS,
 		AdaptiveTimeout: opts.AdaptiveTimeout,
 		Details:         opts.DetailsFlag,
+		ShowClosed:      opts.ShowClosed,
+		ShowFiltered:    opts.ShowFiltered,
 		RandomAgent:     opts.RandomAgent,
 		RandomIP:        opts.RandomIP,
+		CheckpointPath:  opts.CheckpointPath,
+		ResumePath:      opts.ResumePath,
+		Stream:          opts.StreamFlag,
+		RandomizeHosts:  opts.RandomizeHosts,
+		CheckRST:        opts.CheckRST,
+		OSDetect:        opts.OSDetect,
+		OSPing:          opts.OSPing,
+		Traceroute:      opts.Traceroute,
+		Interface:       opts.Interface,
+		SourceIP:        opts.SourceIP,
+		SourcePort:      opts.SourcePort,
+		Proxy:           opts.Proxy,
+		Congestion:      opts.Congestion,
+		MaxRate:         opts.MaxRate,
+		Burst:           opts.Burst,
+		HostTimeout:     opts.HostTimeout,
+		MaxScanTime:     opts.MaxScanTime,
+		CheckTarpit:     opts.CheckTarpit,
+		TarpitSkipSvc:   opts.TarpitSkipSvc,
 	}
 	if req.Format == "text" {
 		output.PrintBanner()
 	}
 
-	if err := app.ExecuteScan(req); err != nil {
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	go func() {
+		// After the first signal, restore default handling so a second Ctrl-C exits immediately.
+		<-ctx.Done()
+		stop()
+	}()
+
+	if err := app.ExecuteScan(ctx, req); err != nil {
+		if errors.Is(err, app.ErrInterrupted) {
+			os.Exit(130)
+		}
+		if errors.Is(err, app.ErrTimeLimit) {
+			// Same status as timeout(1).
+			os.Exit(124)
+		}
 		fmt.Printf("%s\n", output.StatusError(err.Error()))
 		os.Exit(1)
 	}
@@ -2,6 +2,6 @@ module github.com/NexusFireMan/gomap/v2
 
 go 1.24.9
 
-require github.com/stacktitan/smb v0.0.0-20190531122847-da9a425dceb8
+require golang.org/x/net v0.50.0
 
-require golang.org/x/crypto v0.47.0 // indirect
+require golang.org/x/sys v0.41.0 // indirect
@@ -0,0 +1,39 @@
+// Package testutil holds fixtures shared by the tests of several packages.
+package testutil
+
+import (
+	"net"
+	"testing"
+)
+
+// StartService listens on a loopback port and runs handler on every accepted
+// connection, closing the connection when it returns; a nil handler hangs up
+// at once. The listener closes when the test ends.
+func StartService(t testing.TB, handler func(net.Conn)) int {
+	t.Helper()
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("listen: %v", err)
+	}
+	return ServeListener(t, listener, handler)
+}
+
+// ServeListener is StartService for a listener the test opened itself.
+func ServeListener(t testing.TB, listener net.Listener, handler func(net.Conn)) int {
+	t.Cleanup(func() { _ = listener.Close() })
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			go func() {
+				defer func() { _ = conn.Close() }()
+				if handler != nil {
+					handler(conn)
+				}
+			}()
+		}
+	}()
+	return listener.Addr().(*net.TCPAddr).Port
+}
@@ -0,0 +1,224 @@
+package app
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"sort"
+	"sync"
+
+	"github.com/NexusFireMan/gomap/v2/pkg/scanner"
+)
+
+const checkpointVersion = "1"
+
+// checkpointRecord is one JSON line of a checkpoint file. The first line is a
+// header carrying the version and scan mode; every other line is one completed
+// (host, port) probe with its result.
+type checkpointRecord struct {
+	Version  string              `json:"checkpoint_version,omitempty"`
+	ScanMode string              `json:"scan_mode,omitempty"`
+	Host     string              `json:"host,omitempty"`
+	Result   *scanner.ScanResult `json:"result,omitempty"`
+}
+
+// checkpointState holds completed work loaded from a checkpoint file.
+type checkpointState struct {
+	done map[string]map[int]scanner.ScanResult
+}
+
+// checkpointWriter appends completed work to a checkpoint file.
+type checkpointWriter struct {
+	mu   sync.Mutex
+	file *os.File
+	enc  *json.Encoder
+}
+
+// scanMode identifies the engine and scan type a checkpoint belongs to, so
+// work recorded by one is never used to skip probes of another: TCP and UDP
+// results differ, and so do the states each TCP scan type reports, e.g. an
+// ACK scan's unfiltered ports are not a SYN scan's closed ones.
+func scanMode(req ScanRequest) string {
+	if req.UDP {
+		return "udp"
+	}
+	if req.ScanType == "" {
+		return "connect"
+	}
+	return req.ScanType
+}
+
+// loadCheckpoint reads a checkpoint file written by a previous run.
+// Malformed lines, such as a record truncated by a hard kill, are skipped.
+func loadCheckpoint(path, mode string) (*checkpointState, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("cannot open resume file: %w", err)
+	}
+	defer func() { _ = f.Close() }()
+
+	state := &checkpointState{done: make(map[string]map[int]scanner.ScanResult)}
+	sc := bufio.NewScanner(f)
+	sc.Buffer(make([]byte, 64*1024), 1024*1024)
+	headerSeen := false
+	for sc.Scan() {
+		var rec checkpointRecord
+		if err := json.Unmarshal(sc.Bytes(), &rec); err != nil {
+			continue
+		}
+		if rec.Version != "" {
+			if rec.Version != checkpointVersion {
+				return nil, fmt.Errorf("unsupported checkpoint version %q", rec.Version)
+			}
+			if rec.ScanMode != mode {
+				return nil, fmt.Errorf("checkpoint was created for a %s scan, current scan is %s", rec.ScanMode, mode)
+			}
+			headerSeen = true
+			continue
+		}
+		if rec.Host == "" || rec.Result == nil {
+			continue
+		}
+		ports := state.done[rec.Host]
+		if ports == nil {
+			ports = make(map[int]scanner.ScanResult)
+			state.done[rec.Host] = ports
+		}
+		ports[rec.Result.Port] = *rec.Result
+	}
+	if err := sc.Err(); err != nil {
+		return nil, fmt.Errorf("cannot read resume file: %w", err)
+	}
+	if !headerSeen {
+		return nil, errors.New("resume file is not a gomap checkpoint")
+	}
+	return state, nil
+}
+
+// remaining returns the ports not yet completed for host, preserving order.
+func (c *checkpointState) remaining(host string, ports []int) []int {
+	if c == nil || len(c.done[host]) == 0 {
+		return ports
+	}
+	done := c.done[host]
+	out := make([]int, 0, len(ports))
+	for _, p := range ports {
+		if _, ok := done[p]; !ok {
+			out = append(out, p)
+		}
+	}
+	return out
+}
+
+// results returns the previously completed results for host that are visible
+// under the current --show-closed/--show-filtered settings, sorted by port.
+func (c *checkpointState) results(host string, showClosed, showFiltered bool) []scanner.ScanResult {
+	if c == nil {
+		return nil
+	}
+	var out []scanner.ScanResult
+	for _, r := range c.done[host] {
+		if scanner.StateVisible(r.EffectiveState(), showClosed, showFiltered) {
+			out = append(out, r)
+		}
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].Port < out[j].Port })
+	return out
+}
+
+// hosts reports which hosts have completed work in the checkpoint.
+func (c *checkpointState) hosts() map[string]struct{} {
+	out := make(map[string]struct{})
+	if c == nil {
+		return out
+	}
+	for host := range c.done {
+		out[host] = struct{}{}
+	}
+	return out
+}
+
+// openCheckpointWriter opens path for appending. A new or empty file gets a
+// header, and prior work is copied in when resuming into a different file so the
+// new checkpoint is self-contained.
+func openCheckpointWriter(path, mode string, prior *checkpointState, priorPath string) (*checkpointWriter, error) {
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o644)
+	if err != nil {
+		return nil, fmt.Errorf("cannot open checkpoint file: %w", err)
+	}
+	w := &checkpointWriter{file: f, enc: json.NewEncoder(f)}
+
+	info, err := f.Stat()
+	if err != nil {
+		_ = f.Close()
+		return nil, fmt.Errorf("cannot stat checkpoint file: %w", err)
+	}
+	if info.Size() == 0 {
+		if err := w.enc.Encode(checkpointRecord{Version: checkpointVersion, ScanMode: mode}); err != nil {
+			_ = f.Close()
+			return nil, fmt.Errorf("cannot write checkpoint header: %w", err)
+		}
+	}
+	if prior != nil && !sameFile(path, priorPath) {
+		if err := prior.writeTo(w.enc); err != nil {
+			_ = f.Close()
+			return nil, fmt.Errorf("cannot copy resumed work into checkpoint: %w", err)
+		}
+	}
+	return w, nil
+}
+
+func (c *checkpointState) writeTo(enc *json.Encoder) error {
+	hosts := make([]string, 0, len(c.done))
+	for host := range c.done {
+		hosts = append(hosts, host)
+	}
+	sort.Strings(hosts)
+	for _, host := range hosts {
+		ports := make([]int, 0, len(c.done[host]))
+		for p := range c.done[host] {
+			ports = append(ports, p)
+		}
+		sort.Ints(ports)
+		for _, p := range ports {
+			r := c.done[host][p]
+			if err := enc.Encode(checkpointRecord{Host: host, Result: &r}); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// record appends one completed probe. It is safe for concurrent use.
+func (w *checkpointWriter) record(host string, result scanner.ScanResult) {
+	if w == nil {
+		return
+	}
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	_ = w.enc.Encode(checkpointRecord{Host: host, Result: &result})
+}
+
+func (w *checkpointWriter) Close() error {
+	if w == nil {
+		return nil
+	}
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return w.file.Close()
+}
+
+func sameFile(a, b string) bool {
+	if a == "" || b == "" {
+		return false
+	}
+	ai, errA := os.Stat(a)
+	bi, errB := os.Stat(b)
+	if errA != nil || errB != nil {
+		return a == b
+	}
+	return os.SameFile(ai, bi)
+}
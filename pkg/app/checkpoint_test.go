@@ -0,0 +1,113 @@
+package app
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/NexusFireMan/gomap/v2/internal/testutil"
+)
+
+func TestExecuteScanResumeSkipsCheckpointedPorts(t *testing.T) {
+	livePort := testutil.StartService(t, nil)
+
+	// Nothing listens on the checkpointed port, so it only shows up if it was taken from the checkpoint.
+	const recordedPort = 1
+	dir := t.TempDir()
+	ckpt := filepath.Join(dir, "scan.ckpt")
+	content := `{"checkpoint_version":"1","scan_mode":"connect"}
+{"host":"127.0.0.1","result":{"port":1,"open":true,"state":"open","service":"tcpmux"}}
+{"host":"127.0.0.1","result":{"port":` // truncated by a hard kill
+	if err := os.WriteFile(ckpt, []byte(content), 0o644); err != nil {
+		t.Fatalf("write checkpoint: %v", err)
+	}
+
+	outPath := filepath.Join(dir, "resume.json")
+	req := ScanRequest{
+		Target:          "127.0.0.1",
+		PortsFlag:       strconv.Itoa(recordedPort) + "," + strconv.Itoa(livePort),
+		Format:          "json",
+		OutputPath:      outPath,
+		TimeoutMS:       300,
+		AdaptiveTimeout: true,
+		CheckpointPath:  filepath.Join(dir, "next.ckpt"),
+		ResumePath:      ckpt,
+	}
+	if err := ExecuteScan(context.Background(), req); err != nil {
+		t.Fatalf("execute scan failed: %v", err)
+	}
+
+	report := readLabReport(t, outPath)
+	if len(report.Hosts) != 1 || len(report.Hosts[0].Results) != 2 {
+		t.Fatalf("expected resumed and fresh results, got %+v", report.Hosts)
+	}
+	if report.Hosts[0].Results[0].Port != recordedPort || report.Hosts[0].Results[1].Port != livePort {
+		t.Fatalf("unexpected result order: %+v", report.Hosts[0].Results)
+	}
+
+	next, err := loadCheckpoint(req.CheckpointPath, "connect")
+	if err != nil {
+		t.Fatalf("load new checkpoint: %v", err)
+	}
+	done := next.done["127.0.0.1"]
+	if _, ok := done[recordedPort]; !ok {
+		t.Fatalf("expected resumed work copied into new checkpoint: %+v", done)
+	}
+	if _, ok := done[livePort]; !ok {
+		t.Fatalf("expected fresh work recorded in new checkpoint: %+v", done)
+	}
+}
+
+func TestExecuteScanInterruptedRendersPartialReport(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	outPath := filepath.Join(t.TempDir(), "partial.json")
+	req := ScanRequest{
+		Target:     "127.0.0.1",
+		PortsFlag:  "1-1024",
+		Format:     "json",
+		OutputPath: outPath,
+		TimeoutMS:  300,
+	}
+	err := ExecuteScan(ctx, req)
+	if !errors.Is(err, ErrInterrupted) {
+		t.Fatalf("expected ErrInterrupted, got %v", err)
+	}
+	_ = readLabReport(t, outPath)
+}
+
+func TestLoadCheckpointRejectsOtherScanMode(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "udp.ckpt")
+	if err := os.WriteFile(path, []byte(`{"checkpoint_version":"1","scan_mode":"udp"}`+"\n"), 0o644); err != nil {
+		t.Fatalf("write checkpoint: %v", err)
+	}
+	_, err := loadCheckpoint(path, "connect")
+	if err == nil || !strings.Contains(err.Error(), "udp") {
+		t.Fatalf("expected scan mode mismatch error, got %v", err)
+	}
+}
+
+func TestExecuteScanResumeRejectsOtherScanType(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "ack.ckpt")
+	content := `{"checkpoint_version":"1","scan_mode":"ack"}
+{"host":"127.0.0.1","result":{"port":22,"open":false,"state":"unfiltered"}}
+`
+	if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+		t.Fatalf("write checkpoint: %v", err)
+	}
+	for _, scanType := range []string{"syn", "connect", ""} {
+		req := ScanRequest{Target: "127.0.0.1", PortsFlag: "22", ScanType: scanType, Format: "json", ResumePath: path}
+		err := ExecuteScan(context.Background(), req)
+		if err == nil || !strings.Contains(err.Error(), "ack scan") {
+			t.Fatalf("--scan-type %q: expected the ack checkpoint to be rejected, got %v", scanType, err)
+		}
+	}
+	if _, err := loadCheckpoint(path, "ack"); err != nil {
+		t.Fatalf("the same scan type should resume: %v", err)
+	}
+}
@@ -1,6 +1,7 @@
 package app
 
 import (
+	"context"
 	"encoding/json"
 	"net"
 	"os"
@@ -44,7 +45,7 @@ func TestLabIntegrationWindows(t *testing.T) {
 		AdaptiveTimeout: true,
 		BackoffMS:       40,
 	}
-	if err := ExecuteScan(req); err != nil {
+	if err := ExecuteScan(context.Background(), req); err != nil {
 		t.Fatalf("execute scan failed: %v", err)
 	}
 
@@ -79,7 +80,7 @@ func TestLabIntegrationLinux(t *testing.T) {
 		AdaptiveTimeout: true,
 		BackoffMS:       40,
 	}
-	if err := ExecuteScan(req); err != nil {
+	if err := ExecuteScan(context.Background(), req); err != nil {
 		t.Fatalf("execute scan failed: %v", err)
 	}
 
@@ -1,10 +1,17 @@
 package app
 
 import (
+	"context"
+	"errors"
 	"fmt"
+	"io"
+	"net"
+	"net/netip"
 	"os"
+	"slices"
 	"sort"
 	"strings"
+	"sync"
 	"time"
 
 	"github.com/NexusFireMan/gomap/v2/pkg/output"
@@ -14,17 +21,23 @@ import (
 // ScanRequest contains normalized scan options coming from CLI.
 type ScanRequest struct {
 	Target          string
+	TargetFile      string
+	Exclude         string
+	ExcludeFile     string
 	PortsFlag       string
 	ScanType        string
 	UDP             bool
+	IPv6            bool
 	ExcludePorts    string
 	TopPorts        int
 	Rate            int
 	MaxHosts        int
+	HostParallelism int
 	ServiceDetect   bool
 	DeepVersion     bool
 	GhostMode       bool
 	NoDiscovery     bool
+	Discovery       string
 	Format          string
 	OutputPath      string
 	TimeoutMS       int
@@ -34,16 +47,82 @@ type ScanRequest struct {
 	MaxTimeoutMS    int
 	AdaptiveTimeout bool
 	Details         bool
+	ShowClosed      bool
+	ShowFiltered    bool
 	RandomAgent     bool
 	RandomIP        bool
+	CheckpointPath  string
+	ResumePath      string
+	Stream          bool
+	RandomizeHosts  bool
+	// CheckRST warns about local firewall rules that drop outgoing TCP resets.
+	CheckRST bool
+	// OSDetect guesses each host's OS family from SYN-ACK fingerprints in
+	// SYN scans. OSPing lets connect and UDP scans, which see no fingerprint,
+	// send one ICMP echo request per host and guess from the reply's TTL.
+	OSDetect bool
+	OSPing   bool
+	// Traceroute records the TCP path to each host that has an open port.
+	Traceroute bool
+	// Interface, SourceIP and SourcePort pin where probes are sent from.
+	// Empty/zero values leave the choice to the routing table.
+	Interface  string
+	SourceIP   string
+	SourcePort int
+	// Proxy is a comma-separated SOCKS5/HTTP CONNECT proxy chain that carries
+	// every TCP connection. Hostnames are then resolved by the last proxy.
+	Proxy string
+	// Congestion paces connect scans with a congestion window and RFC 6298
+	// timeouts instead of a fixed worker count; the final window and RTT
+	// estimates are reported per host.
+	Congestion bool
+	// MaxRate caps probes per second across the whole run: discovery, every
+	// scan engine and all hosts together. 0 means no global cap. Burst is the
+	// token bucket size; 0 picks a tenth of MaxRate.
+	MaxRate int
+	Burst   int
+	// HostTimeout abandons a host once its scan, service probes, OS guess and
+	// traceroute have taken this long, keeping what was found; the host is
+	// reported as timed out. MaxScanTime bounds the whole run the same way.
+	// 0 means no bound.
+	HostTimeout time.Duration
+	MaxScanTime time.Duration
+	// CheckTarpit probes a random high port before each TCP host and checks
+	// its results for signs of a box answering every port. Suspected hosts
+	// are reported without their port list. TarpitSkipSvc also skips
+	// service detection on them, at the cost of a detection-free scan first.
+	CheckTarpit   bool
+	TarpitSkipSvc bool
 }
 
+// ErrInterrupted is returned by ExecuteScan when ctx was cancelled mid-scan.
+// Partial results have already been rendered when it is returned.
+var ErrInterrupted = errors.New("scan interrupted")
+
+// ErrTimeLimit is returned by ExecuteScan when ScanRequest.MaxScanTime ran
+// out. Partial results have already been rendered when it is returned.
+var ErrTimeLimit = errors.New("scan time limit reached")
+
+// errHostTimeout is the cause of a host context ended by HostTimeout.
+var errHostTimeout = errors.New("host timeout")
+
 // ExecuteScan runs the complete scan workflow: target expansion, host discovery, scan, and rendering.
-func ExecuteScan(req ScanRequest) error {
+// Cancelling ctx stops the scan early; completed results are still rendered and ErrInterrupted is returned.
+// Running out of MaxScanTime does the same but returns ErrTimeLimit.
+func ExecuteScan(ctx context.Context, req ScanRequest) error {
 	machineOutput := req.Format != "text"
 	if req.ScanType == "" {
 		req.ScanType = "connect"
 	}
+	switch req.ScanType {
+	case scanner.TechniqueFIN, scanner.TechniqueNULL, scanner.TechniqueXmas:
+		// Open ports stay silent under these probes, so open|filtered is the
+		// only positive answer they give.
+		req.ShowFiltered = true
+	case scanner.TechniqueACK:
+		// ACK scans map firewall rules: unfiltered (RST) is their answer.
+		req.ShowClosed = true
+	}
 	scanLabel := strings.ToUpper(req.ScanType)
 	if req.UDP {
 		scanLabel = "UDP"
@@ -51,11 +130,30 @@ func ExecuteScan(req ScanRequest) error {
 	if req.DeepVersion {
 		scanLabel += "+DV"
 	}
+	source, err := sourceConfig(req)
+	if err != nil {
+		return err
+	}
+	var limiter *scanner.RateLimiter
+	if req.MaxRate > 0 {
+		limiter = scanner.NewRateLimiter(req.MaxRate, req.Burst)
+	}
+	if req.MaxScanTime > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeoutCause(ctx, req.MaxScanTime, ErrTimeLimit)
+		defer cancel()
+	}
+	proxy, err := proxyDialer(ctx, req, source)
+	if err != nil {
+		return err
+	}
+	if proxy != nil && !machineOutput {
+		fmt.Printf("%s\n", output.Info(fmt.Sprintf("🔀 Tunneling TCP connections through %s", proxy)))
+	}
 
 	destWriter := output.DefaultWriter()
 	var outFile *os.File
 	if req.OutputPath != "" {
-		var err error
 		outFile, err = os.Create(req.OutputPath)
 		if err != nil {
 			return fmt.Errorf("cannot create output file: %w", err)
@@ -65,10 +163,7 @@ func ExecuteScan(req ScanRequest) error {
 	}
 
 	portManager := scanner.NewPortManager()
-	var (
-		portsToScan []int
-		err         error
-	)
+	var portsToScan []int
 	if req.TopPorts > 0 {
 		top := scanner.GetTop1000Ports()
 		if req.UDP {
@@ -97,25 +192,72 @@ func ExecuteScan(req ScanRequest) error {
 		}
 	}
 
-	targets, err := scanner.ParseTargets(req.Target)
+	targetSpec, targetLabel, err := collectTargetSpecs(req)
+	if err != nil {
+		return err
+	}
+	targetOpts := scanner.TargetOptions{PreferIPv6: req.IPv6, RemoteDNS: proxy != nil}
+	targetSet, err := buildTargetSet(req, targetSpec, targetOpts)
 	if err != nil {
-		return fmt.Errorf("invalid target specification: %w", err)
+		return err
+	}
+	// Hosts are pulled from the iterator as scan slots free up, so even a /8 is
+	// never held in memory as a list.
+	hosts := targetSet.Iterator(req.RandomizeHosts)
+	hostCount := targetSet.Len()
+
+	var resumed *checkpointState
+	if req.ResumePath != "" {
+		resumed, err = loadCheckpoint(req.ResumePath, scanMode(req))
+		if err != nil {
+			return err
+		}
+	}
+	checkpointPath := req.CheckpointPath
+	if checkpointPath == "" {
+		checkpointPath = req.ResumePath
+	}
+	var checkpoint *checkpointWriter
+	if checkpointPath != "" {
+		checkpoint, err = openCheckpointWriter(checkpointPath, scanMode(req), resumed, req.ResumePath)
+		if err != nil {
+			return err
+		}
+		defer func() { _ = checkpoint.Close() }()
+	}
+
+	if req.RandomIP && !scanner.IsCIDR(targetSpec) && !machineOutput {
+		fmt.Printf("%s\n", output.StatusWarn("--random-ip is most useful with CIDR targets; using local /24 (IPv4) or /64 (IPv6) approximation per host."))
+	}
+	discoveryMethods := scanner.DefaultDiscoveryMethods(targetSet, source)
+	if proxy != nil {
+		discoveryMethods = []string{scanner.DiscoveryTCP}
 	}
-	if req.RandomIP && !scanner.IsCIDR(req.Target) && !machineOutput {
-		fmt.Printf("%s\n", output.StatusWarn("--random-ip is most useful with CIDR targets; using local /24 approximation per host."))
+	if req.Discovery != "" {
+		discoveryMethods, err = scanner.ParseDiscoveryMethods(req.Discovery)
+		if err != nil {
+			return err
+		}
 	}
-	if req.UDP && !req.NoDiscovery && scanner.IsCIDR(req.Target) && len(targets) > 1 && !machineOutput {
-		fmt.Printf("%s\n", output.StatusWarn("UDP CIDR scans still use TCP host discovery. Use -nd to scan every host when UDP-only targets are expected."))
+	if req.UDP && !req.NoDiscovery && scanner.IsCIDR(targetSpec) && hostCount > 1 && !machineOutput && onlyTCPDiscovery(discoveryMethods) {
+		fmt.Printf("%s\n", output.StatusWarn("UDP CIDR scans still use TCP host discovery. Use --discovery icmp,tcp or -nd when UDP-only targets are expected."))
 	}
 
-	if !req.NoDiscovery && scanner.IsCIDR(req.Target) && len(targets) > 1 {
+	var (
+		discovered []string
+		hostInfo   map[string]output.HostInfo
+	)
+	if !req.NoDiscovery && scanner.IsCIDR(targetSpec) && hostCount > 1 {
 		if !machineOutput {
-			fmt.Printf("%s\n", output.Info(fmt.Sprintf("🔍 Discovering active hosts in %s...", output.Host(req.Target))))
+			fmt.Printf("%s\n", output.Info(fmt.Sprintf("🔍 Discovering active hosts in %s (%s)...", output.Host(targetLabel), strings.Join(discoveryMethods, ", "))))
 		}
 		discoveryOpts := scanner.DiscoveryOptions{
 			Ports:      []int{443, 80, 22, 445, 3306, 8080, 3389},
 			Timeout:    500 * time.Millisecond,
 			NumWorkers: 50,
+			Source:     source,
+			Proxy:      proxy,
+			Limiter:    limiter,
 		}
 		if req.GhostMode {
 			// Low-noise profile for CIDR discovery: fewer probe ports and lower concurrency.
@@ -123,22 +265,54 @@ func ExecuteScan(req ScanRequest) error {
 				Ports:      []int{443, 80, 22},
 				Timeout:    900 * time.Millisecond,
 				NumWorkers: 12,
+				Source:     source,
+				Proxy:      proxy,
+				Limiter:    limiter,
 			}
 			if !machineOutput {
 				fmt.Printf("%s\n", output.StatusWarn("Ghost discovery profile active: low-noise probes on 443,80,22. Use -nd to skip discovery completely."))
 			}
 		}
-		targets = scanner.DiscoverActiveHostsWithOptions(targets, discoveryOpts)
-		if len(targets) == 0 {
+		discoveryOpts.Methods = discoveryMethods
+		if proxy != nil && discoveryOpts.Timeout < proxyTimeout {
+			discoveryOpts.Timeout = proxyTimeout
+		}
+		if !req.GhostMode && !slices.Contains(discoveryMethods, scanner.DiscoveryTCP) {
+			// Raw and ICMP probes wait on shared sockets instead of holding a
+			// connection each, so many more hosts can be in flight.
+			discoveryOpts.NumWorkers = 256
+		}
+		discoveryOpts.OnUnavailable = func(method string, err error) {
+			if machineOutput {
+				return
+			}
+			if method == scanner.DiscoveryTCPSYN || method == scanner.DiscoveryTCPACK {
+				fmt.Printf("%s\n", output.StatusWarn(fmt.Sprintf("%s discovery unavailable (%v). Falling back to TCP connect discovery.", method, err)))
+				return
+			}
+			fmt.Printf("%s\n", output.StatusWarn(fmt.Sprintf("%s discovery unavailable (%v). Skipping it.", method, err)))
+		}
+		found, err := scanner.DiscoverActiveTargets(ctx, hosts, discoveryOpts)
+		if err != nil {
+			return fmt.Errorf("host discovery failed: %w", err)
+		}
+		hostInfo = make(map[string]output.HostInfo, len(found))
+		for i := range found {
+			hostInfo[found[i].Host] = output.HostInfo{Discovery: &found[i]}
+		}
+		discovered = keepResumedHosts(targetSet, scanner.DiscoveredHosts(found), resumed)
+		hosts = scanner.SliceIterator(discovered)
+		hostCount = uint64(len(discovered))
+		if hostCount == 0 && ctx.Err() == nil {
 			if machineOutput {
 				empty := map[string][]scanner.ScanResult{}
 				switch req.Format {
 				case "json":
-					_ = output.PrintJSONReport(destWriter, req.Target, portsToScan, targets, empty, req.ServiceDetect, 0)
+					_ = output.PrintJSONReport(destWriter, targetLabel, portsToScan, nil, empty, nil, req.ServiceDetect, 0, rateStats(limiter))
 				case "jsonl":
-					_ = output.PrintJSONLReport(destWriter, req.Target, targets, empty)
+					_ = output.PrintJSONLReport(destWriter, targetLabel, nil, empty)
 				case "csv":
-					_ = output.PrintCSVReport(destWriter, empty, targets)
+					_ = output.PrintCSVReport(destWriter, empty, nil)
 				}
 				if req.OutputPath != "" {
 					fmt.Printf("%s\n", output.StatusOK(fmt.Sprintf("Saved %s output to %s", strings.ToUpper(req.Format), req.OutputPath)))
@@ -150,29 +324,33 @@ func ExecuteScan(req ScanRequest) error {
 		}
 
 		if !machineOutput {
-			fmt.Printf("%s\n\n", output.Success(fmt.Sprintf("✓ Found %s active hosts, starting port scan...", output.Count(len(targets)))))
+			fmt.Printf("%s\n\n", output.Success(fmt.Sprintf("✓ Found %s active hosts, starting port scan...", output.Count(len(discovered)))))
 		}
 	}
-	if req.MaxHosts > 0 && len(targets) > req.MaxHosts {
+	if req.MaxHosts > 0 && hostCount > uint64(req.MaxHosts) {
 		if !machineOutput {
 			fmt.Printf("%s\n", output.StatusWarn(fmt.Sprintf("Limiting scan to first %d host(s) due to --max-hosts.", req.MaxHosts)))
 		}
-		targets = targets[:req.MaxHosts]
+		hostCount = uint64(req.MaxHosts)
 	}
 
-	if !machineOutput {
-		if len(targets) == 1 {
+	if !machineOutput && hostCount > 0 {
+		if hostCount == 1 {
+			single, _ := targetSet.Iterator(false).Next()
+			if discovered != nil {
+				single = discovered[0]
+			}
 			if req.GhostMode {
-				fmt.Printf("%s\n\n", output.Info(fmt.Sprintf("🎯 Scanning %s (%s ports, %s scan) - %s (low-noise)", output.Host(targets[0]), output.Count(len(portsToScan)), output.Highlight(scanLabel), output.Warning("Ghost mode"))))
+				fmt.Printf("%s\n\n", output.Info(fmt.Sprintf("🎯 Scanning %s (%s ports, %s scan) - %s (low-noise)", output.Host(single), output.Count(len(portsToScan)), output.Highlight(scanLabel), output.Warning("Ghost mode"))))
 			} else {
-				fmt.Printf("%s\n\n", output.Info(fmt.Sprintf("🎯 Scanning %s (%s ports, %s scan)", output.Host(targets[0]), output.Count(len(portsToScan)), output.Highlight(scanLabel))))
+				fmt.Printf("%s\n\n", output.Info(fmt.Sprintf("🎯 Scanning %s (%s ports, %s scan)", output.Host(single), output.Count(len(portsToScan)), output.Highlight(scanLabel))))
 			}
 		} else {
-			targetRange, _, _ := scanner.FormatCIDRInfo(req.Target)
+			targetRange := targetLabel
 			if req.GhostMode {
-				fmt.Printf("%s\n\n", output.Info(fmt.Sprintf("🎯 Scanning %s (%s active hosts, %s ports, %s scan) - %s (low-noise)", output.Highlight(targetRange), output.Count(len(targets)), output.Count(len(portsToScan)), output.Highlight(scanLabel), output.Warning("Ghost mode"))))
+				fmt.Printf("%s\n\n", output.Info(fmt.Sprintf("🎯 Scanning %s (%s active hosts, %s ports, %s scan) - %s (low-noise)", output.Highlight(targetRange), output.Count(int(hostCount)), output.Count(len(portsToScan)), output.Highlight(scanLabel), output.Warning("Ghost mode"))))
 			} else {
-				fmt.Printf("%s\n\n", output.Info(fmt.Sprintf("🎯 Scanning %s (%s active hosts, %s ports, %s scan)", output.Highlight(targetRange), output.Count(len(targets)), output.Count(len(portsToScan)), output.Highlight(scanLabel))))
+				fmt.Printf("%s\n\n", output.Info(fmt.Sprintf("🎯 Scanning %s (%s active hosts, %s ports, %s scan)", output.Highlight(targetRange), output.Count(int(hostCount)), output.Count(len(portsToScan)), output.Highlight(scanLabel))))
 			}
 		}
 	}
@@ -181,70 +359,252 @@ func ExecuteScan(req ScanRequest) error {
 	if req.DeepVersion {
 		formatter = output.NewEvidenceOutputFormatter()
 	}
+	var sink output.ResultSink
+	if req.Stream {
+		sink, err = newStreamSink(req, targetLabel, destWriter)
+		if err != nil {
+			return err
+		}
+	}
 	allResults := make(map[string][]scanner.ScanResult)
 	scanStart := time.Now()
 
 	var timeoutDuration time.Duration
 	if req.TimeoutMS > 0 {
 		timeoutDuration = time.Duration(req.TimeoutMS) * time.Millisecond
+	} else if proxy != nil {
+		timeoutDuration = proxyTimeout
 	}
 	var maxTimeoutDuration time.Duration
 	if req.MaxTimeoutMS > 0 {
 		maxTimeoutDuration = time.Duration(req.MaxTimeoutMS) * time.Millisecond
 	}
-	for _, targetIP := range targets {
-		s := scanner.NewScanner(targetIP, req.GhostMode)
-		cidrForHeaders := ""
-		if req.RandomIP && scanner.IsCIDR(req.Target) {
-			cidrForHeaders = req.Target
-		}
-		s.Configure(scanner.ScanConfig{
-			NumWorkers:      req.Workers,
-			Timeout:         timeoutDuration,
-			Retries:         req.Retries,
-			Rate:            req.Rate,
-			AdaptiveTimeout: req.AdaptiveTimeout,
-			BackoffBase:     time.Duration(req.BackoffMS) * time.Millisecond,
-			MaxTimeout:      maxTimeoutDuration,
-			RandomAgent:     req.RandomAgent,
-			RandomIP:        req.RandomIP,
-			TargetCIDR:      cidrForHeaders,
-			DeepVersion:     req.DeepVersion,
-		})
-		var openResults []scanner.ScanResult
-		if req.UDP {
-			openResults = s.ScanUDP(portsToScan, req.ServiceDetect)
-		} else if req.ScanType == "syn" {
-			synOpenPorts, synErr := scanner.DiscoverOpenPortsSYN(targetIP, portsToScan, scanner.SYNConfig{
-				Rate:      req.Rate,
-				Retries:   req.Retries,
-				GhostMode: req.GhostMode,
-			})
-			if synErr != nil {
-				if !machineOutput {
-					fmt.Printf("%s\n", output.StatusWarn(fmt.Sprintf("SYN scan unavailable on %s (%v). Falling back to connect scan.", targetIP, synErr)))
+	cidrForHeaders := ""
+	if req.RandomIP && scanner.IsCIDR(targetSpec) {
+		cidrForHeaders = targetSpec
+	}
+	scanCfg := scanner.ScanConfig{
+		NumWorkers:      req.Workers,
+		Timeout:         timeoutDuration,
+		Retries:         req.Retries,
+		Rate:            req.Rate,
+		AdaptiveTimeout: req.AdaptiveTimeout,
+		BackoffBase:     time.Duration(req.BackoffMS) * time.Millisecond,
+		MaxTimeout:      maxTimeoutDuration,
+		RandomAgent:     req.RandomAgent,
+		RandomIP:        req.RandomIP,
+		TargetCIDR:      cidrForHeaders,
+		DeepVersion:     req.DeepVersion,
+		ShowClosed:      req.ShowClosed,
+		ShowFiltered:    req.ShowFiltered,
+		Source:          source,
+		Congestion:      req.Congestion,
+		Limiter:         limiter,
+	}
+	if proxy != nil {
+		scanCfg.Dialer = proxy
+	}
+
+	hostParallelism := req.HostParallelism
+	if hostParallelism <= 0 {
+		hostParallelism = 1
+	}
+	if uint64(hostParallelism) > hostCount {
+		hostParallelism = int(hostCount)
+	}
+	if hostParallelism > 1 {
+		// Hosts scanned together draw from one dial budget so --workers stays a global cap.
+		scanCfg.Budget = scanner.NewDialBudget(globalDialBudget(req))
+	}
+
+	var (
+		resultsMu sync.Mutex
+		hostWG    sync.WaitGroup
+		ttlProber *scanner.TTLProber
+		tracer    *scanner.Tracer
+	)
+	if (req.OSDetect || req.Traceroute || req.Congestion || req.HostTimeout > 0 || req.CheckTarpit) && hostInfo == nil {
+		hostInfo = make(map[string]output.HostInfo)
+	}
+	// targets records hosts in the order they were handed to a worker; it is
+	// what the reports list, and never includes hosts an interrupt skipped.
+	var targets []string
+	hostsChan := make(chan hostJob)
+	for i := 0; i < hostParallelism; i++ {
+		hostWG.Add(1)
+		go func() {
+			defer hostWG.Done()
+			for job := range hostsChan {
+				targetIP := job.target
+				hostCfg := scanCfg
+				hook := resultHook(req, targetIP, checkpoint, sink)
+				hostCfg.OnResult = hook
+				var held *heldResults
+				if req.CheckTarpit && hook != nil {
+					// Rows of a suspected tarpit must reach neither the stream
+					// nor the checkpoint, so they wait for the verdict.
+					held = &heldResults{}
+					hostCfg.OnResult = held.add
 				}
-				openResults = s.Scan(portsToScan, req.ServiceDetect)
-			} else {
-				openResults = scanner.BuildResultsFromKnownOpenPorts(s, synOpenPorts, req.ServiceDetect)
+				priorResults := resumed.results(targetIP, req.ShowClosed, req.ShowFiltered)
+				emitPrior := func() {
+					if sink != nil {
+						for _, r := range priorResults {
+							_ = sink.Emit(targetIP, r)
+						}
+					}
+				}
+				if held == nil {
+					emitPrior()
+				}
+				hostCtx, cancelHost := hostContext(ctx, req.HostTimeout)
+				hostResults, congestion, tarpit := scanHost(hostCtx, req, job, hostCfg, machineOutput)
+				hostResults = mergeResumedResults(hostResults, priorResults)
+				if tarpit != nil && tarpit.Suspected {
+					// Thousands of fake open ports would bury every other host.
+					if !machineOutput {
+						fmt.Printf("%s\n", output.StatusWarn(fmt.Sprintf("%s looks like a tarpit (%s), collapsing its %d open ports.", targetIP, strings.Join(tarpit.Reasons, ", "), tarpit.OpenPorts)))
+					}
+					hostResults = nil
+				} else {
+					tarpit = nil
+					if held != nil {
+						emitPrior()
+						held.flush(hook)
+					}
+				}
+				var osGuess *scanner.OSGuess
+				if req.OSDetect {
+					osGuess = hostOSGuess(hostCtx, job, ttlProber, limiter)
+				}
+				route := traceHost(hostCtx, tracer, targetIP, hostResults, machineOutput)
+				timedOut := errors.Is(context.Cause(hostCtx), errHostTimeout)
+				cancelHost()
+				if timedOut && !machineOutput {
+					fmt.Printf("%s\n", output.StatusWarn(fmt.Sprintf("%s exceeded --host-timeout %s, keeping partial results.", targetIP, req.HostTimeout)))
+				}
+				resultsMu.Lock()
+				if len(hostResults) > 0 {
+					allResults[targetIP] = hostResults
+				}
+				if osGuess != nil || route != nil || congestion != nil || timedOut || tarpit != nil {
+					info := hostInfo[targetIP]
+					info.OS = osGuess
+					info.Route = route
+					info.Congestion = congestion
+					info.TimedOut = timedOut
+					info.Tarpit = tarpit
+					hostInfo[targetIP] = info
+				}
+				resultsMu.Unlock()
+			}
+		}()
+	}
+	if req.CheckRST {
+		warnRSTDropRules(ctx, machineOutput)
+	}
+	var synScanner *scanner.SYNScanner
+	if scanner.IsRawScanType(req.ScanType) && !req.UDP {
+		synScanner, err = scanner.NewSYNScanner(scanner.SYNConfig{
+			Rate:      req.Rate,
+			Retries:   req.Retries,
+			GhostMode: req.GhostMode,
+			Technique: req.ScanType,
+			Source:    source,
+			Limiter:   limiter,
+		})
+		if err != nil {
+			if !machineOutput {
+				fmt.Printf("%s\n", output.StatusWarn(fmt.Sprintf("%s scan unavailable (%v). Falling back to connect scan.", strings.ToUpper(req.ScanType), err)))
 			}
+			synScanner = nil
 		} else {
-			openResults = s.Scan(portsToScan, req.ServiceDetect)
+			defer synScanner.Close()
 		}
-		if len(openResults) > 0 {
-			allResults[targetIP] = openResults
+	}
+	switch {
+	case !req.OSDetect || synScanner != nil:
+	case !req.OSPing:
+		if !machineOutput {
+			fmt.Printf("%s\n", output.StatusWarn("-O reads SYN-ACK fingerprints of --scan-type syn scans (root/CAP_NET_RAW). Add --os-ping to guess from the TTL of an ICMP echo instead."))
+		}
+	default:
+		if ttlProber, err = scanner.NewTTLProber(source); err != nil {
+			if !machineOutput {
+				fmt.Printf("%s\n", output.StatusWarn(fmt.Sprintf("ICMP OS guess unavailable (%v). Use --scan-type syn as root for SYN-ACK fingerprints.", err)))
+			}
+			ttlProber = nil
+		} else {
+			defer ttlProber.Close()
 		}
 	}
+	if req.Traceroute && !req.UDP {
+		if tracer, err = scanner.NewTracer(scanner.TracerouteConfig{Source: source, Limiter: limiter}); err != nil {
+			if !machineOutput {
+				fmt.Printf("%s\n", output.StatusWarn(fmt.Sprintf("Traceroute unavailable (%v). Skipping it.", err)))
+			}
+			tracer = nil
+		} else {
+			defer tracer.Close()
+		}
+	}
+	if synScanner != nil {
+		feedSYNBatches(ctx, synScanner, hosts, hostCount, len(portsToScan), func(host string) []int {
+			return resumed.remaining(host, portsToScan)
+		}, hostsChan, func(host string) { targets = append(targets, host) })
+	} else {
+	feed:
+		for fed := uint64(0); fed < hostCount; fed++ {
+			targetIP, ok := hosts.Next()
+			if !ok {
+				break
+			}
+			select {
+			case hostsChan <- hostJob{target: targetIP, ports: resumed.remaining(targetIP, portsToScan)}:
+				targets = append(targets, targetIP)
+			case <-ctx.Done():
+				break feed
+			}
+		}
+	}
+	close(hostsChan)
+	hostWG.Wait()
 	scanDuration := time.Since(scanStart)
 
+	interrupted := ctx.Err() != nil
+	stopErr, stopReason, stopLabel := ErrInterrupted, "Scan interrupted", "Interrupted"
+	if errors.Is(context.Cause(ctx), ErrTimeLimit) {
+		stopErr, stopReason, stopLabel = ErrTimeLimit, fmt.Sprintf("Scan time limit of %s reached", req.MaxScanTime), "Time limit reached"
+	}
+	if interrupted {
+		msg := output.StatusWarn(stopReason + ", reporting partial results.")
+		if checkpointPath != "" {
+			msg = output.StatusWarn(fmt.Sprintf("%s, reporting partial results. Resume with --resume %s", stopReason, checkpointPath))
+		}
+		if machineOutput && req.OutputPath == "" {
+			// Keep stdout parseable for machine formats.
+			_, _ = fmt.Fprintln(os.Stderr, msg)
+		} else {
+			fmt.Printf("%s\n", msg)
+		}
+	}
+
+	if sink != nil {
+		if err := sink.Close(); err != nil {
+			return fmt.Errorf("failed to stream %s output: %w", req.Format, err)
+		}
+	}
+
 	if machineOutput {
 		var renderErr error
-		switch req.Format {
-		case "json":
-			renderErr = output.PrintJSONReport(destWriter, req.Target, portsToScan, targets, allResults, req.ServiceDetect, scanDuration)
-		case "jsonl":
-			renderErr = output.PrintJSONLReport(destWriter, req.Target, targets, allResults)
-		case "csv":
+		switch {
+		case sink != nil:
+			// Every record was already written as it was found.
+		case req.Format == "json":
+			renderErr = output.PrintJSONReport(destWriter, targetLabel, portsToScan, targets, allResults, hostInfo, req.ServiceDetect, scanDuration, rateStats(limiter))
+		case req.Format == "jsonl":
+			renderErr = output.PrintJSONLReport(destWriter, targetLabel, targets, allResults)
+		case req.Format == "csv":
 			renderErr = output.PrintCSVReport(destWriter, allResults, targets)
 		default:
 			renderErr = fmt.Errorf("unsupported output format: %s", req.Format)
@@ -255,24 +615,463 @@ func ExecuteScan(req ScanRequest) error {
 		if req.OutputPath != "" {
 			fmt.Printf("%s\n", output.StatusOK(fmt.Sprintf("Saved %s output to %s", strings.ToUpper(req.Format), req.OutputPath)))
 		}
+		if interrupted {
+			return stopErr
+		}
 		return nil
 	}
 
 	totalOpen := 0
 	for _, targetIP := range targets {
 		if results, exists := allResults[targetIP]; exists {
-			totalOpen += len(results)
+			totalOpen += scanner.OpenCount(results)
+			if sink != nil {
+				// Results were already printed live; only the summary follows.
+				continue
+			}
 			if len(targets) > 1 {
-				fmt.Printf("\n%s\n", output.Highlight(fmt.Sprintf("═══ %s ═══", output.Host(targetIP))))
+				header := output.Host(targetIP)
+				if d := hostInfo[targetIP].Discovery; d != nil && d.MAC != "" {
+					header += " " + strings.TrimSpace(fmt.Sprintf("(%s %s)", d.MAC, d.Vendor))
+				}
+				fmt.Printf("\n%s\n", output.Highlight(fmt.Sprintf("═══ %s ═══", header)))
 			}
 			formatter.PrintResults(results)
 		}
 	}
-	printHostSummaries(targets, allResults)
-	fmt.Printf("\n%s\n", output.StatusOK(fmt.Sprintf("Completed scan in %s | hosts: %d | open ports: %d", scanDuration.Round(time.Millisecond), len(targets), totalOpen)))
+	printHostSummaries(targets, allResults, hostInfo, req.Details)
+	if interrupted {
+		fmt.Printf("\n%s\n", output.StatusWarn(fmt.Sprintf("%s after %s | hosts: %d | open ports: %d%s", stopLabel, scanDuration.Round(time.Millisecond), len(targets), totalOpen, rateSummary(limiter))))
+		return stopErr
+	}
+	fmt.Printf("\n%s\n", output.StatusOK(fmt.Sprintf("Completed scan in %s | hosts: %d | open ports: %d%s", scanDuration.Round(time.Millisecond), len(targets), totalOpen, rateSummary(limiter))))
 	return nil
 }
 
+// warnRSTDropRules prints the local firewall rules that would drop outgoing
+// resets, which leaves SYN-probed ports half-open on the target. Machine
+// formats get the messages on stderr so stdout stays parseable.
+func warnRSTDropRules(ctx context.Context, machineOutput bool) {
+	out := io.Writer(os.Stdout)
+	if machineOutput {
+		out = os.Stderr
+	}
+	rules, err := scanner.RSTDropRules(ctx)
+	switch {
+	case err != nil:
+		_, _ = fmt.Fprintf(out, "%s\n", output.StatusWarn(fmt.Sprintf("Could not check firewall rules for dropped TCP resets (%v).", err)))
+	case len(rules) == 0:
+		_, _ = fmt.Fprintf(out, "%s\n", output.StatusOK("No local firewall rule drops outgoing TCP resets."))
+	default:
+		_, _ = fmt.Fprintf(out, "%s\n", output.StatusWarn("Local firewall rules drop outgoing TCP resets; SYN-probed ports may stay half-open on targets:"))
+		for _, rule := range rules {
+			_, _ = fmt.Fprintf(out, "    %s\n", rule)
+		}
+	}
+}
+
+// hostJob is one host handed to a scan worker.
+type hostJob struct {
+	target string
+	ports  []int
+	// syn holds the host's share of a batched SYN scan; nil means the host is
+	// scanned with connect or UDP probes.
+	syn *scanner.SYNHostResult
+}
+
+// A SYN batch holds at most synBatchProbes (host, port) probes and
+// synBatchMaxHosts hosts.
+const (
+	synBatchProbes   = 1 << 16
+	synBatchMaxHosts = 4096
+)
+
+// feedSYNBatches SYN-scans hosts in batches that share one raw socket, then
+// hands each host with its port states to the workers for result building and
+// service detection. Every host of a started batch is handed over, even after
+// an interrupt, so its partial results are reported.
+func feedSYNBatches(ctx context.Context, syn *scanner.SYNScanner, hosts scanner.TargetIterator, hostCount uint64, portCount int, portsFor func(string) []int, jobs chan<- hostJob, started func(string)) {
+	batchSize := synBatchMaxHosts
+	if portCount > 0 && synBatchProbes/portCount < batchSize {
+		batchSize = max(1, synBatchProbes/portCount)
+	}
+	for fed := uint64(0); fed < hostCount && ctx.Err() == nil; {
+		var batch []scanner.SYNTarget
+		for fed < hostCount && len(batch) < batchSize {
+			host, ok := hosts.Next()
+			if !ok {
+				break
+			}
+			fed++
+			batch = append(batch, scanner.SYNTarget{Host: host, Ports: portsFor(host)})
+		}
+		if len(batch) == 0 {
+			return
+		}
+		results, err := syn.Scan(ctx, batch)
+		if err != nil && ctx.Err() == nil {
+			for i := range results {
+				results[i] = scanner.SYNHostResult{Err: err}
+			}
+		}
+		for i, t := range batch {
+			jobs <- hostJob{target: t.Host, ports: t.Ports, syn: &results[i]}
+			started(t.Host)
+		}
+	}
+}
+
+// scanHost runs the selected scan engine against a single target. The
+// congestion stats are only set when the connect engine ran with them.
+func scanHost(ctx context.Context, req ScanRequest, job hostJob, cfg scanner.ScanConfig, machineOutput bool) ([]scanner.ScanResult, *scanner.CongestionStats, *scanner.TarpitReport) {
+	if len(job.ports) == 0 {
+		return nil, nil, nil
+	}
+	s := scanner.NewScanner(job.target, req.GhostMode)
+	s.Configure(cfg)
+	// Each host gets its own copy because the ghost profile shuffles ports in place.
+	ports := append([]int(nil), job.ports...)
+	if req.UDP {
+		return s.ScanUDP(ctx, ports, req.ServiceDetect), nil, nil
+	}
+	synDone := job.syn != nil && job.syn.Err == nil
+	if job.syn != nil && !synDone && ctx.Err() == nil && !machineOutput {
+		fmt.Printf("%s\n", output.StatusWarn(fmt.Sprintf("%s scan unavailable on %s (%v). Falling back to connect scan.", strings.ToUpper(req.ScanType), job.target, job.syn.Err)))
+	}
+	var canary *scanner.ScanResult
+	if req.CheckTarpit {
+		probe := s.ProbeCanary(ctx, ports)
+		canary = &probe
+	}
+	if !req.CheckTarpit || !req.ServiceDetect || !req.TarpitSkipSvc {
+		var (
+			results    []scanner.ScanResult
+			congestion *scanner.CongestionStats
+		)
+		if synDone {
+			results = scanner.BuildResultsFromPortStates(ctx, s, job.syn.States, req.ServiceDetect)
+		} else {
+			results, congestion = s.Scan(ctx, ports, req.ServiceDetect), s.CongestionStats()
+		}
+		if !req.CheckTarpit {
+			return results, congestion, nil
+		}
+		var detected []scanner.ScanResult
+		if req.ServiceDetect {
+			detected = results
+		}
+		tarpit := scanner.AssessTarpit(results, len(ports), canary, detected)
+		return results, congestion, &tarpit
+	}
+
+	// Judge the host before service detection, so a tarpit is not probed on
+	// every port. quiet scans without reporting results through OnResult.
+	quiet := scanner.NewScanner(job.target, req.GhostMode)
+	quietCfg := cfg
+	quietCfg.OnResult = nil
+	quietCfg.ShowClosed, quietCfg.ShowFiltered = true, true
+	quiet.Configure(quietCfg)
+	var (
+		found      []scanner.ScanResult
+		states     map[int]scanner.PortState
+		congestion *scanner.CongestionStats
+	)
+	if synDone {
+		states = job.syn.States
+		found = stateResults(states)
+	} else {
+		found = quiet.Scan(ctx, ports, false)
+		states = portStates(found)
+		congestion = quiet.CongestionStats()
+	}
+	tarpit := scanner.AssessTarpit(found, len(ports), canary, nil)
+	if !tarpit.Suspected && len(tarpit.Reasons) > 0 {
+		// One signal short: see whether a few open ports stay silent.
+		var sample []int
+		for _, r := range found {
+			if r.EffectiveState() == scanner.PortOpen && len(sample) < scanner.TarpitSampleSize {
+				sample = append(sample, r.Port)
+			}
+		}
+		tarpit = scanner.AssessTarpit(found, len(ports), canary, quiet.Scan(ctx, sample, true))
+	}
+	return scanner.BuildResultsFromPortStates(ctx, s, states, !tarpit.Suspected), congestion, &tarpit
+}
+
+// portStates maps each port of results to its state.
+func portStates(results []scanner.ScanResult) map[int]scanner.PortState {
+	states := make(map[int]scanner.PortState, len(results))
+	for _, r := range results {
+		states[r.Port] = r.EffectiveState()
+	}
+	return states
+}
+
+// stateResults turns raw port states into bare results for AssessTarpit.
+func stateResults(states map[int]scanner.PortState) []scanner.ScanResult {
+	results := make([]scanner.ScanResult, 0, len(states))
+	for port, state := range states {
+		results = append(results, scanner.ScanResult{Port: port, State: state})
+	}
+	sort.Slice(results, func(i, j int) bool { return results[i].Port < results[j].Port })
+	return results
+}
+
+// sourceConfig builds the scan's source binding from -e, -S and
+// --source-port and checks it against the local interfaces.
+func sourceConfig(req ScanRequest) (scanner.SourceConfig, error) {
+	src := scanner.SourceConfig{Interface: req.Interface, Port: req.SourcePort}
+	if req.SourceIP != "" {
+		if src.IP = net.ParseIP(req.SourceIP); src.IP == nil {
+			return scanner.SourceConfig{}, fmt.Errorf("invalid source address %q", req.SourceIP)
+		}
+	}
+	if err := src.Validate(); err != nil {
+		return scanner.SourceConfig{}, fmt.Errorf("invalid source binding: %w", err)
+	}
+	return src, nil
+}
+
+// proxyTimeout is the default connect timeout through a proxy chain, which
+// adds a round trip per hop to every connection.
+const proxyTimeout = 3 * time.Second
+
+// proxyDialer builds the --proxy chain and checks that it is reachable. Raw
+// and UDP packets cannot be tunneled, so the modes that send them are refused
+// instead of silently bypassing the proxy.
+func proxyDialer(ctx context.Context, req ScanRequest, source scanner.SourceConfig) (*scanner.ProxyDialer, error) {
+	if req.Proxy == "" {
+		return nil, nil
+	}
+	switch {
+	case req.UDP:
+		return nil, errors.New("-u cannot be used with --proxy: proxies only carry TCP connections")
+	case scanner.IsRawScanType(req.ScanType):
+		return nil, fmt.Errorf("--scan-type %s cannot be used with --proxy: raw packets bypass the proxy (use --scan-type connect)", req.ScanType)
+	case req.OSDetect:
+		return nil, errors.New("-O cannot be used with --proxy: its probes bypass the proxy")
+	case req.Traceroute:
+		return nil, errors.New("--traceroute cannot be used with --proxy: its probes bypass the proxy")
+	case req.SourcePort != 0:
+		return nil, errors.New("--source-port cannot be used with --proxy: every tunnel needs its own connection")
+	}
+	proxy, err := scanner.NewProxyDialer(req.Proxy)
+	if err != nil {
+		return nil, err
+	}
+	proxy.Source = source
+	if err := proxy.Check(ctx, proxyTimeout); err != nil {
+		return nil, fmt.Errorf("proxy chain unusable: %w", err)
+	}
+	return proxy, nil
+}
+
+// osProbeTimeout bounds the ICMP echo used for TTL-only OS guesses.
+const osProbeTimeout = time.Second
+
+// hostOSGuess returns the OS guess for a scanned host: the SYN engine's
+// fingerprint when the host was SYN-scanned, otherwise a TTL-only guess from
+// one ICMP echo when ttl is open.
+func hostOSGuess(ctx context.Context, job hostJob, ttl *scanner.TTLProber, limiter *scanner.RateLimiter) *scanner.OSGuess {
+	if job.syn != nil && job.syn.OS != nil {
+		return job.syn.OS
+	}
+	if ttl == nil || limiter.Wait(ctx) != nil {
+		return nil
+	}
+	guess, _ := ttl.GuessOS(ctx, job.target, osProbeTimeout)
+	return guess
+}
+
+// hostContext bounds the work on one host by timeout, whose expiry is
+// reported as errHostTimeout; timeout <= 0 leaves it bounded by ctx only.
+func hostContext(ctx context.Context, timeout time.Duration) (context.Context, context.CancelFunc) {
+	if timeout <= 0 {
+		return context.WithCancel(ctx)
+	}
+	return context.WithTimeoutCause(ctx, timeout, errHostTimeout)
+}
+
+// rateStats returns the --max-rate limiter's stats, or nil without one.
+func rateStats(limiter *scanner.RateLimiter) *scanner.RateStats {
+	if limiter == nil {
+		return nil
+	}
+	st := limiter.Stats()
+	return &st
+}
+
+// rateSummary renders the achieved global rate for the final status line,
+// or nothing without --max-rate.
+func rateSummary(limiter *scanner.RateLimiter) string {
+	if limiter == nil {
+		return ""
+	}
+	st := limiter.Stats()
+	return fmt.Sprintf(" | rate: %.1f/s of %d/s (%d probes, burst %d)", st.Rate(), st.MaxRate, st.Probes, st.Burst)
+}
+
+// traceHost traces the path to host through its lowest open port. It returns
+// nil without a tracer, without an open port or when the trace fails.
+func traceHost(ctx context.Context, tracer *scanner.Tracer, host string, results []scanner.ScanResult, machineOutput bool) *scanner.Route {
+	if tracer == nil || ctx.Err() != nil {
+		return nil
+	}
+	port := 0
+	for _, r := range results {
+		if r.EffectiveState() == scanner.PortOpen && (port == 0 || r.Port < port) {
+			port = r.Port
+		}
+	}
+	if port == 0 {
+		return nil
+	}
+	route, err := tracer.Trace(ctx, host, port)
+	if err != nil {
+		if !machineOutput && ctx.Err() == nil {
+			fmt.Printf("%s\n", output.StatusWarn(fmt.Sprintf("Traceroute to %s failed (%v).", host, err)))
+		}
+		return nil
+	}
+	return &route
+}
+
+// compactPath renders a route as "192.0.2.1 → * → 10.0.11.6 (3 hops)".
+func compactPath(route *scanner.Route) string {
+	addrs := make([]string, 0, len(route.Hops))
+	for _, hop := range route.Hops {
+		if hop.Addr == "" {
+			addrs = append(addrs, "*")
+			continue
+		}
+		addrs = append(addrs, hop.Addr)
+	}
+	if !route.Reached {
+		addrs = append(addrs, "?")
+		return fmt.Sprintf("%s (target not reached)", strings.Join(addrs, " → "))
+	}
+	unit := "hops"
+	if len(route.Hops) == 1 {
+		unit = "hop"
+	}
+	return fmt.Sprintf("%s (%d %s)", strings.Join(addrs, " → "), len(route.Hops), unit)
+}
+
+// newStreamSink builds the live result sink for --stream.
+func newStreamSink(req ScanRequest, targetLabel string, destWriter io.Writer) (output.ResultSink, error) {
+	switch req.Format {
+	case "jsonl":
+		return output.NewJSONLSink(destWriter, targetLabel), nil
+	case "text":
+		return output.NewTextSink(os.Stdout, req.ServiceDetect), nil
+	default:
+		return nil, fmt.Errorf("--stream is not supported with %s output", req.Format)
+	}
+}
+
+// resultHook returns the per-result callback for a host, feeding the checkpoint
+// with every completed probe and the stream sink with every reported result.
+func resultHook(req ScanRequest, host string, checkpoint *checkpointWriter, sink output.ResultSink) func(scanner.ScanResult) {
+	if checkpoint == nil && sink == nil {
+		return nil
+	}
+	return func(r scanner.ScanResult) {
+		checkpoint.record(host, r)
+		if sink != nil && scanner.StateVisible(r.EffectiveState(), req.ShowClosed, req.ShowFiltered) {
+			_ = sink.Emit(host, r)
+		}
+	}
+}
+
+// heldResults buffers a host's per-result callbacks until it is known they
+// should be reported.
+type heldResults struct {
+	mu      sync.Mutex
+	results []scanner.ScanResult
+}
+
+// add is an OnResult callback; workers of one host call it concurrently.
+func (h *heldResults) add(r scanner.ScanResult) {
+	h.mu.Lock()
+	h.results = append(h.results, r)
+	h.mu.Unlock()
+}
+
+// flush passes the buffered results to hook in the order they arrived.
+func (h *heldResults) flush(hook func(scanner.ScanResult)) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	for _, r := range h.results {
+		hook(r)
+	}
+	h.results = nil
+}
+
+// onlyTCPDiscovery reports whether every discovery method probes TCP ports,
+// which UDP-only hosts never answer.
+func onlyTCPDiscovery(methods []string) bool {
+	for _, m := range methods {
+		switch m {
+		case scanner.DiscoveryTCP, scanner.DiscoveryTCPSYN, scanner.DiscoveryTCPACK:
+		default:
+			return false
+		}
+	}
+	return true
+}
+
+// keepResumedHosts re-adds hosts in set with checkpointed work that discovery
+// missed this time. They are appended after the discovered hosts in address order.
+func keepResumedHosts(set *scanner.TargetSet, discovered []string, resumed *checkpointState) []string {
+	if resumed == nil {
+		return discovered
+	}
+	missing := resumed.hosts()
+	for _, host := range discovered {
+		delete(missing, host)
+	}
+	extra := make([]string, 0, len(missing))
+	for host := range missing {
+		if set.Contains(host) {
+			extra = append(extra, host)
+		}
+	}
+	sort.Slice(extra, func(i, j int) bool { return lessAddr(extra[i], extra[j]) })
+	return append(discovered, extra...)
+}
+
+// lessAddr orders IP literals numerically, falling back to string order.
+func lessAddr(a, b string) bool {
+	x, errA := netip.ParseAddr(a)
+	y, errB := netip.ParseAddr(b)
+	if errA != nil || errB != nil {
+		return a < b
+	}
+	return x.Less(y)
+}
+
+// mergeResumedResults combines fresh results with results carried over from a
+// checkpoint, sorted by port.
+func mergeResumedResults(fresh, prior []scanner.ScanResult) []scanner.ScanResult {
+	if len(prior) == 0 {
+		return fresh
+	}
+	merged := append(append([]scanner.ScanResult(nil), prior...), fresh...)
+	sort.Slice(merged, func(i, j int) bool { return merged[i].Port < merged[j].Port })
+	return merged
+}
+
+// globalDialBudget returns the total in-flight dial cap shared by parallel hosts.
+func globalDialBudget(req ScanRequest) int {
+	if req.Workers > 0 {
+		return req.Workers
+	}
+	if req.GhostMode {
+		return 4
+	}
+	return 200
+}
+
 func filterExcludedPorts(pm *scanner.PortManager, ports []int, excludeSpec string) ([]int, error) {
 	excluded, err := pm.ParsePorts(excludeSpec)
 	if err != nil {
@@ -293,11 +1092,11 @@ func filterExcludedPorts(pm *scanner.PortManager, ports []int, excludeSpec strin
 	return filtered, nil
 }
 
-func printHostSummaries(targets []string, allResults map[string][]scanner.ScanResult) {
+func printHostSummaries(targets []string, allResults map[string][]scanner.ScanResult, hostInfo map[string]output.HostInfo, details bool) {
 	fmt.Printf("\n%s\n", output.Bold("Host Exposure Summary"))
 	for _, host := range targets {
 		results := allResults[host]
-		open := len(results)
+		open := scanner.OpenCount(results)
 		critical := criticalServices(results)
 		exposure := exposureLevel(open, len(critical))
 
@@ -305,15 +1104,39 @@ func printHostSummaries(targets []string, allResults map[string][]scanner.ScanRe
 		if len(critical) > 0 {
 			criticalStr = strings.Join(critical, ", ")
 		}
-		fmt.Printf("- %s | open ports: %d | critical: %s | exposure: %s\n",
+		line := fmt.Sprintf("- %s | open ports: %d | critical: %s | exposure: %s",
 			host,
 			open,
 			criticalStr,
 			exposure,
 		)
+		if g := hostInfo[host].OS; g != nil {
+			line += fmt.Sprintf(" | os: %s (%s)", g.Name, g.Confidence)
+		}
+		if hostInfo[host].TimedOut {
+			line += " | timed out"
+		}
+		if t := hostInfo[host].Tarpit; t != nil {
+			line += fmt.Sprintf(" | suspected tarpit (%d/%d ports answered, not listed)", t.OpenPorts, t.ScannedPorts)
+		}
+		fmt.Println(line)
+		if route := hostInfo[host].Route; route != nil {
+			fmt.Printf("  path: %s\n", compactPath(route))
+		}
+		if c := hostInfo[host].Congestion; c != nil && details {
+			fmt.Printf("  congestion: %s\n", congestionSummary(c))
+		}
 	}
 }
 
+// congestionSummary renders the final window and RTT estimates of a host.
+func congestionSummary(c *scanner.CongestionStats) string {
+	return fmt.Sprintf("window %d (peak %d, range %d-%d) | srtt %s | rttvar %s | timeout %s | samples %d | cuts %d",
+		c.Window, c.PeakWindow, c.MinWindow, c.MaxWindow,
+		c.SRTT.Round(10*time.Microsecond), c.RTTVar.Round(10*time.Microsecond), c.RTO.Round(time.Millisecond),
+		c.Samples, c.Cuts)
+}
+
 func criticalServices(results []scanner.ScanResult) []string {
 	criticalSet := map[string]struct{}{
 		"ssh":           {},
@@ -332,6 +1155,9 @@ func criticalServices(results []scanner.ScanResult) []string {
 
 	found := make(map[string]struct{})
 	for _, r := range results {
+		if r.EffectiveState() != scanner.PortOpen {
+			continue
+		}
 		if _, ok := criticalSet[r.ServiceName]; ok {
 			found[r.ServiceName] = struct{}{}
 		}
@@ -1,9 +1,21 @@
 package app
 
 import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
 	"testing"
+	"time"
 
 	"github.com/NexusFireMan/gomap/v2/pkg/scanner"
+
+	"github.com/NexusFireMan/gomap/v2/internal/testutil"
 )
 
 func TestFilterExcludedPorts(t *testing.T) {
@@ -48,3 +60,707 @@ func TestCriticalServices(t *testing.T) {
 		t.Fatalf("unexpected critical services: %v", critical)
 	}
 }
+
+func TestExecuteScanParallelHostsKeepTargetOrder(t *testing.T) {
+	// Listen on every address so 127.0.0.2 and 127.0.0.3 reach the port too.
+	listener, err := net.Listen("tcp", ":0")
+	if err != nil {
+		t.Fatalf("listen: %v", err)
+	}
+	port := testutil.ServeListener(t, listener, nil)
+	outPath := filepath.Join(t.TempDir(), "parallel.json")
+	req := ScanRequest{
+		Target:          "127.0.0.3,127.0.0.1,127.0.0.2",
+		PortsFlag:       strconv.Itoa(port),
+		Format:          "json",
+		OutputPath:      outPath,
+		TimeoutMS:       300,
+		HostParallelism: 3,
+		AdaptiveTimeout: true,
+	}
+	if err := ExecuteScan(context.Background(), req); err != nil {
+		t.Fatalf("execute scan failed: %v", err)
+	}
+
+	report := readLabReport(t, outPath)
+	want := []string{"127.0.0.3", "127.0.0.1", "127.0.0.2"}
+	if len(report.Hosts) != len(want) {
+		t.Fatalf("expected %d hosts, got %d", len(want), len(report.Hosts))
+	}
+	for i, host := range report.Hosts {
+		if host.Host != want[i] {
+			t.Fatalf("host %d: expected %s, got %s", i, want[i], host.Host)
+		}
+		if len(host.Results) != 1 || host.Results[0].Port != port {
+			t.Fatalf("unexpected results for %s: %+v", host.Host, host.Results)
+		}
+	}
+}
+
+func TestExecuteScanStreamsJSONL(t *testing.T) {
+	port := testutil.StartService(t, nil)
+	outPath := filepath.Join(t.TempDir(), "stream.jsonl")
+	req := ScanRequest{
+		Target:          "127.0.0.1",
+		PortsFlag:       strconv.Itoa(port),
+		Format:          "jsonl",
+		OutputPath:      outPath,
+		TimeoutMS:       300,
+		AdaptiveTimeout: true,
+		Stream:          true,
+	}
+	if err := ExecuteScan(context.Background(), req); err != nil {
+		t.Fatalf("execute scan failed: %v", err)
+	}
+
+	data, err := os.ReadFile(outPath)
+	if err != nil {
+		t.Fatalf("read output: %v", err)
+	}
+	lines := strings.Split(strings.TrimSpace(string(data)), "\n")
+	if len(lines) != 1 {
+		t.Fatalf("expected exactly one streamed record (no batch duplicate), got %d: %q", len(lines), data)
+	}
+	var rec struct {
+		Host string `json:"host"`
+		Port int    `json:"port"`
+	}
+	if err := json.Unmarshal([]byte(lines[0]), &rec); err != nil {
+		t.Fatalf("invalid jsonl record: %v", err)
+	}
+	if rec.Host != "127.0.0.1" || rec.Port != port {
+		t.Fatalf("unexpected record: %+v", rec)
+	}
+}
+
+func TestExecuteScanStreamRejectsCSV(t *testing.T) {
+	req := ScanRequest{Target: "127.0.0.1", PortsFlag: "80", Format: "csv", OutputPath: filepath.Join(t.TempDir(), "x.csv"), Stream: true}
+	if err := ExecuteScan(context.Background(), req); err == nil {
+		t.Fatal("expected error for --stream with csv output")
+	}
+}
+
+func TestExecuteScanIPv6Loopback(t *testing.T) {
+	listener, err := net.Listen("tcp6", "[::1]:0")
+	if err != nil {
+		t.Skipf("ipv6 loopback unavailable: %v", err)
+	}
+	port := testutil.ServeListener(t, listener, nil)
+	outPath := filepath.Join(t.TempDir(), "v6.json")
+	req := ScanRequest{
+		Target:          "::1",
+		PortsFlag:       strconv.Itoa(port),
+		Format:          "json",
+		OutputPath:      outPath,
+		TimeoutMS:       300,
+		AdaptiveTimeout: true,
+	}
+	if err := ExecuteScan(context.Background(), req); err != nil {
+		t.Fatalf("execute scan failed: %v", err)
+	}
+	report := readLabReport(t, outPath)
+	if len(report.Hosts) != 1 || report.Hosts[0].Host != "::1" || len(report.Hosts[0].Results) != 1 {
+		t.Fatalf("unexpected ipv6 report: %+v", report.Hosts)
+	}
+}
+
+func TestExecuteScanTargetFileWithExclusions(t *testing.T) {
+	port := testutil.StartService(t, nil)
+	dir := t.TempDir()
+	scope := filepath.Join(dir, "scope.txt")
+	if err := os.WriteFile(scope, []byte("# lab scope\n127.0.0.1\n127.0.0.0/30 # overlaps\n127.0.0.3\n"), 0o644); err != nil {
+		t.Fatalf("write scope: %v", err)
+	}
+	outPath := filepath.Join(dir, "scope.json")
+	req := ScanRequest{
+		TargetFile:      scope,
+		Exclude:         "127.0.0.2",
+		PortsFlag:       strconv.Itoa(port),
+		Format:          "json",
+		OutputPath:      outPath,
+		TimeoutMS:       300,
+		AdaptiveTimeout: true,
+		NoDiscovery:     true,
+	}
+	if err := ExecuteScan(context.Background(), req); err != nil {
+		t.Fatalf("execute scan failed: %v", err)
+	}
+
+	report := readLabReport(t, outPath)
+	var hosts []string
+	for _, h := range report.Hosts {
+		hosts = append(hosts, h.Host)
+	}
+	if strings.Join(hosts, ",") != "127.0.0.1,127.0.0.3" {
+		t.Fatalf("expected deduped hosts without exclusions, got %v", hosts)
+	}
+}
+
+func TestExecuteScanAllTargetsExcluded(t *testing.T) {
+	req := ScanRequest{Target: "127.0.0.1", Exclude: "127.0.0.0/8", PortsFlag: "80", Format: "json", OutputPath: filepath.Join(t.TempDir(), "x.json")}
+	if err := ExecuteScan(context.Background(), req); err == nil {
+		t.Fatal("expected error when every target is excluded")
+	}
+}
+
+func TestExecuteScanRandomizedHostsHonourMaxHosts(t *testing.T) {
+	outPath := filepath.Join(t.TempDir(), "random.json")
+	req := ScanRequest{
+		Target:          "127.0.0.1-127.0.0.20",
+		PortsFlag:       "1",
+		Format:          "json",
+		OutputPath:      outPath,
+		TimeoutMS:       200,
+		AdaptiveTimeout: true,
+		NoDiscovery:     true,
+		RandomizeHosts:  true,
+		MaxHosts:        5,
+		HostParallelism: 3,
+	}
+	if err := ExecuteScan(context.Background(), req); err != nil {
+		t.Fatalf("execute scan failed: %v", err)
+	}
+
+	report := readLabReport(t, outPath)
+	if len(report.Hosts) != 5 {
+		t.Fatalf("expected 5 hosts, got %d", len(report.Hosts))
+	}
+	seen := make(map[string]struct{})
+	for _, h := range report.Hosts {
+		ip := net.ParseIP(h.Host).To4()
+		if ip == nil || ip[0] != 127 || ip[3] < 1 || ip[3] > 20 {
+			t.Fatalf("host %s outside target range", h.Host)
+		}
+		if _, dup := seen[h.Host]; dup {
+			t.Fatalf("host %s scanned twice", h.Host)
+		}
+		seen[h.Host] = struct{}{}
+	}
+}
+
+func TestExecuteScanReportsDiscoveryReason(t *testing.T) {
+	outPath := filepath.Join(t.TempDir(), "discovery.json")
+	// Nothing listens on loopback port 1, so every host answers the probes with RST.
+	req := ScanRequest{
+		Target:          "127.0.0.1-127.0.0.3",
+		PortsFlag:       "1",
+		Format:          "json",
+		OutputPath:      outPath,
+		TimeoutMS:       200,
+		AdaptiveTimeout: true,
+	}
+	if err := ExecuteScan(context.Background(), req); err != nil {
+		t.Fatalf("execute scan failed: %v", err)
+	}
+
+	data, err := os.ReadFile(outPath)
+	if err != nil {
+		t.Fatalf("read report: %v", err)
+	}
+	var report struct {
+		Hosts []struct {
+			Host      string `json:"host"`
+			Discovery *struct {
+				Reason string `json:"reason"`
+				Port   int    `json:"port"`
+			} `json:"discovery"`
+		} `json:"hosts"`
+	}
+	if err := json.Unmarshal(data, &report); err != nil {
+		t.Fatalf("invalid report: %v", err)
+	}
+	if len(report.Hosts) != 3 {
+		t.Fatalf("expected refused hosts to count as alive, got %d hosts", len(report.Hosts))
+	}
+	for _, h := range report.Hosts {
+		if h.Discovery == nil || h.Discovery.Reason == "" || h.Discovery.Port == 0 {
+			t.Fatalf("missing discovery reason for %s", h.Host)
+		}
+	}
+}
+
+func TestExecuteScanRejectsUnknownDiscoveryMethod(t *testing.T) {
+	err := ExecuteScan(context.Background(), ScanRequest{
+		Target:    "127.0.0.1-127.0.0.3",
+		PortsFlag: "1",
+		Format:    "json",
+		Discovery: "icmp,smoke-signal",
+	})
+	if err == nil || !strings.Contains(err.Error(), "smoke-signal") {
+		t.Fatalf("expected unknown method error, got %v", err)
+	}
+}
+
+func TestExecuteScanSYNBatchesHosts(t *testing.T) {
+	port := testutil.StartService(t, nil)
+	outPath := filepath.Join(t.TempDir(), "syn.json")
+	// Without raw socket privileges this exercises the connect fallback instead.
+	req := ScanRequest{
+		Target:          "127.0.0.1-127.0.0.4",
+		PortsFlag:       strconv.Itoa(port),
+		ScanType:        "syn",
+		Format:          "json",
+		OutputPath:      outPath,
+		TimeoutMS:       300,
+		AdaptiveTimeout: true,
+		NoDiscovery:     true,
+		HostParallelism: 2,
+	}
+	if err := ExecuteScan(context.Background(), req); err != nil {
+		t.Fatalf("execute scan failed: %v", err)
+	}
+
+	report := readLabReport(t, outPath)
+	if len(report.Hosts) != 4 {
+		t.Fatalf("expected 4 hosts, got %+v", report.Hosts)
+	}
+	for i, h := range report.Hosts {
+		if want := "127.0.0." + strconv.Itoa(i+1); h.Host != want {
+			t.Fatalf("host %d: expected %s, got %s", i, want, h.Host)
+		}
+		open := len(h.Results)
+		if (i == 0 && (open != 1 || h.Results[0].Port != port)) || (i > 0 && open != 0) {
+			t.Fatalf("unexpected results for %s: %+v", h.Host, h.Results)
+		}
+	}
+}
+
+func TestCompactPath(t *testing.T) {
+	reached := &scanner.Route{Reached: true, Hops: []scanner.Hop{{TTL: 1, Addr: "192.0.2.1"}, {TTL: 2}, {TTL: 3, Addr: "10.0.11.6"}}}
+	if got, want := compactPath(reached), "192.0.2.1 → * → 10.0.11.6 (3 hops)"; got != want {
+		t.Fatalf("got %q, want %q", got, want)
+	}
+	single := &scanner.Route{Reached: true, Hops: []scanner.Hop{{TTL: 1, Addr: "10.0.11.6"}}}
+	if got, want := compactPath(single), "10.0.11.6 (1 hop)"; got != want {
+		t.Fatalf("got %q, want %q", got, want)
+	}
+	lost := &scanner.Route{Hops: []scanner.Hop{{TTL: 1, Addr: "192.0.2.1"}}}
+	if got, want := compactPath(lost), "192.0.2.1 → ? (target not reached)"; got != want {
+		t.Fatalf("got %q, want %q", got, want)
+	}
+}
+
+func TestExecuteScanTraceroute(t *testing.T) {
+	port := testutil.StartService(t, nil)
+
+	outPath := filepath.Join(t.TempDir(), "route.json")
+	req := ScanRequest{
+		Target:          "127.0.0.1",
+		PortsFlag:       strconv.Itoa(port),
+		Format:          "json",
+		OutputPath:      outPath,
+		TimeoutMS:       200,
+		AdaptiveTimeout: true,
+		Traceroute:      true,
+	}
+	if err := ExecuteScan(context.Background(), req); err != nil {
+		t.Fatalf("execute scan failed: %v", err)
+	}
+	data, err := os.ReadFile(outPath)
+	if err != nil {
+		t.Fatalf("read report: %v", err)
+	}
+	var report struct {
+		Hosts []struct {
+			HopDistance int `json:"hop_distance"`
+			Hops        []struct {
+				TTL  int    `json:"ttl"`
+				Addr string `json:"addr"`
+			} `json:"hops"`
+		} `json:"hosts"`
+	}
+	if err := json.Unmarshal(data, &report); err != nil {
+		t.Fatalf("invalid report: %v", err)
+	}
+	if len(report.Hosts) != 1 {
+		t.Fatalf("expected one host, got %d", len(report.Hosts))
+	}
+	h := report.Hosts[0]
+	if len(h.Hops) == 0 {
+		// Without raw socket privileges the traceroute is skipped with a warning.
+		t.Logf("no route recorded (traceroute unavailable)")
+		return
+	}
+	if h.HopDistance != 1 || len(h.Hops) != 1 || h.Hops[0].Addr != "127.0.0.1" {
+		t.Fatalf("unexpected route: %+v", h)
+	}
+}
+
+func TestExecuteScanOSPing(t *testing.T) {
+	port := testutil.StartService(t, nil)
+
+	guess := func(ping bool) string {
+		t.Helper()
+		outPath := filepath.Join(t.TempDir(), "os.json")
+		req := ScanRequest{
+			Target:     "127.0.0.1",
+			PortsFlag:  strconv.Itoa(port),
+			Format:     "json",
+			OutputPath: outPath,
+			TimeoutMS:  200,
+			OSDetect:   true,
+			OSPing:     ping,
+		}
+		if err := ExecuteScan(context.Background(), req); err != nil {
+			t.Fatalf("execute scan failed: %v", err)
+		}
+		data, err := os.ReadFile(outPath)
+		if err != nil {
+			t.Fatalf("read report: %v", err)
+		}
+		var report struct {
+			Hosts []struct {
+				OSGuess string `json:"os_guess"`
+			} `json:"hosts"`
+		}
+		if err := json.Unmarshal(data, &report); err != nil || len(report.Hosts) != 1 {
+			t.Fatalf("invalid report: %s", data)
+		}
+		return report.Hosts[0].OSGuess
+	}
+	// A connect scan sends nothing beyond its dials unless asked to ping.
+	if got := guess(false); got != "" {
+		t.Fatalf("-O without --os-ping should not guess from a connect scan, got %q", got)
+	}
+	if got := guess(true); got == "" {
+		// Without ICMP sockets the ping guess is skipped with a warning.
+		t.Logf("no OS guess recorded (ICMP unavailable)")
+	}
+}
+
+func TestExecuteScanRejectsForeignSourceAddress(t *testing.T) {
+	req := ScanRequest{
+		Target:    "127.0.0.1",
+		PortsFlag: "80",
+		Format:    "json",
+		SourceIP:  "192.0.2.254",
+	}
+	err := ExecuteScan(context.Background(), req)
+	if err == nil || !strings.Contains(err.Error(), "invalid source binding") {
+		t.Fatalf("expected source binding error, got %v", err)
+	}
+}
+
+func TestExecuteScanProxyRefusesRawAndUDP(t *testing.T) {
+	for _, req := range []ScanRequest{
+		{Target: "127.0.0.1", PortsFlag: "80", Format: "json", Proxy: "socks5://127.0.0.1:1080", ScanType: "syn"},
+		{Target: "127.0.0.1", PortsFlag: "53", Format: "json", Proxy: "socks5://127.0.0.1:1080", UDP: true},
+	} {
+		err := ExecuteScan(context.Background(), req)
+		if err == nil || !strings.Contains(err.Error(), "--proxy") {
+			t.Fatalf("expected proxy conflict error, got %v", err)
+		}
+	}
+}
+
+func TestExecuteScanReportsDeadProxy(t *testing.T) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("listen: %v", err)
+	}
+	dead := listener.Addr().String()
+	_ = listener.Close()
+
+	req := ScanRequest{Target: "127.0.0.1", PortsFlag: "80", Format: "json", Proxy: "socks5://" + dead}
+	err = ExecuteScan(context.Background(), req)
+	if err == nil || !strings.Contains(err.Error(), "proxy chain unusable") {
+		t.Fatalf("expected dead proxy error, got %v", err)
+	}
+}
+
+func TestExecuteScanCongestion(t *testing.T) {
+	port := testutil.StartService(t, nil)
+
+	outPath := filepath.Join(t.TempDir(), "congestion.json")
+	req := ScanRequest{
+		Target:     "127.0.0.1",
+		PortsFlag:  strconv.Itoa(port),
+		Format:     "json",
+		OutputPath: outPath,
+		TimeoutMS:  200,
+		Workers:    32,
+		Congestion: true,
+	}
+	if err := ExecuteScan(context.Background(), req); err != nil {
+		t.Fatalf("execute scan failed: %v", err)
+	}
+	data, err := os.ReadFile(outPath)
+	if err != nil {
+		t.Fatalf("read report: %v", err)
+	}
+	var report struct {
+		Hosts []struct {
+			Congestion *struct {
+				Window    int     `json:"window"`
+				MaxWindow int     `json:"max_window"`
+				TimeoutMs float64 `json:"timeout_ms"`
+				Samples   int     `json:"samples"`
+			} `json:"congestion"`
+		} `json:"hosts"`
+	}
+	if err := json.Unmarshal(data, &report); err != nil {
+		t.Fatalf("invalid report: %v", err)
+	}
+	if len(report.Hosts) != 1 || report.Hosts[0].Congestion == nil {
+		t.Fatalf("expected congestion stats in the report:\n%s", data)
+	}
+	c := report.Hosts[0].Congestion
+	if c.MaxWindow != 32 || c.Window < 1 || c.Samples != 1 || c.TimeoutMs < 200 {
+		t.Fatalf("unexpected congestion stats: %+v", c)
+	}
+}
+
+func TestCongestionSummary(t *testing.T) {
+	c := &scanner.CongestionStats{Window: 48, PeakWindow: 96, MinWindow: 25, MaxWindow: 200, SRTT: 12345 * time.Microsecond, RTTVar: 3 * time.Millisecond, RTO: 500 * time.Millisecond, Samples: 900, Cuts: 2}
+	want := "window 48 (peak 96, range 25-200) | srtt 12.35ms | rttvar 3ms | timeout 500ms | samples 900 | cuts 2"
+	if got := congestionSummary(c); got != want {
+		t.Fatalf("got %q, want %q", got, want)
+	}
+}
+
+func TestExecuteScanMaxRate(t *testing.T) {
+	port := testutil.StartService(t, nil)
+
+	outPath := filepath.Join(t.TempDir(), "rate.json")
+	req := ScanRequest{
+		Target:          "127.0.0.1-4",
+		PortsFlag:       "1,2,3,4," + strconv.Itoa(port),
+		Format:          "json",
+		OutputPath:      outPath,
+		TimeoutMS:       200,
+		HostParallelism: 4,
+		MaxRate:         100,
+		Burst:           1,
+	}
+	start := time.Now()
+	if err := ExecuteScan(context.Background(), req); err != nil {
+		t.Fatalf("execute scan failed: %v", err)
+	}
+	data, err := os.ReadFile(outPath)
+	if err != nil {
+		t.Fatalf("read report: %v", err)
+	}
+	var report struct {
+		RateLimit *struct {
+			MaxRate      int     `json:"max_rate"`
+			Burst        int     `json:"burst"`
+			Probes       int     `json:"probes"`
+			AchievedRate float64 `json:"achieved_rate"`
+		} `json:"rate_limit"`
+	}
+	if err := json.Unmarshal(data, &report); err != nil {
+		t.Fatalf("invalid report: %v", err)
+	}
+	r := report.RateLimit
+	// Discovery probes each of the four hosts, then each host's five ports are
+	// scanned. The first probe is free, so a handful of probes would overshoot.
+	if r == nil || r.MaxRate != 100 || r.Burst != 1 || r.Probes < 24 || r.AchievedRate <= 0 || r.AchievedRate > 110 {
+		t.Fatalf("unexpected rate_limit: %s", data)
+	}
+	if elapsed := time.Since(start); elapsed < time.Duration(r.Probes-1)*10*time.Millisecond {
+		t.Fatalf("%d probes at 100/s finished in %v", r.Probes, elapsed)
+	}
+}
+
+func TestRateSummary(t *testing.T) {
+	if got := rateSummary(nil); got != "" {
+		t.Fatalf("expected no summary without a limiter, got %q", got)
+	}
+	limiter := scanner.NewRateLimiter(300, 20)
+	_ = limiter.Wait(context.Background())
+	if got := rateSummary(limiter); !strings.Contains(got, "of 300/s (1 probes, burst 20)") {
+		t.Fatalf("unexpected summary %q", got)
+	}
+}
+
+// startStallingService accepts connections and never answers them, like a
+// tarpit, so service detection only ends when a deadline stops it.
+func startStallingService(t *testing.T) int {
+	t.Helper()
+	return testutil.StartService(t, func(conn net.Conn) { _, _ = io.Copy(io.Discard, conn) })
+}
+
+func TestExecuteScanHostTimeout(t *testing.T) {
+	port := startStallingService(t)
+	outPath := filepath.Join(t.TempDir(), "host-timeout.json")
+	req := ScanRequest{
+		Target:        "127.0.0.1",
+		PortsFlag:     strconv.Itoa(port),
+		Format:        "json",
+		OutputPath:    outPath,
+		TimeoutMS:     5000,
+		ServiceDetect: true,
+		DeepVersion:   true,
+		HostTimeout:   300 * time.Millisecond,
+	}
+	start := time.Now()
+	if err := ExecuteScan(context.Background(), req); err != nil {
+		t.Fatalf("execute scan failed: %v", err)
+	}
+	if elapsed := time.Since(start); elapsed > 3*time.Second {
+		t.Fatalf("service probes outlived --host-timeout: %v", elapsed)
+	}
+	data, err := os.ReadFile(outPath)
+	if err != nil {
+		t.Fatalf("read report: %v", err)
+	}
+	var report struct {
+		Hosts []struct {
+			OpenPorts int  `json:"open_ports"`
+			TimedOut  bool `json:"timed_out"`
+		} `json:"hosts"`
+	}
+	if err := json.Unmarshal(data, &report); err != nil {
+		t.Fatalf("invalid report: %v", err)
+	}
+	if len(report.Hosts) != 1 || !report.Hosts[0].TimedOut || report.Hosts[0].OpenPorts != 1 {
+		t.Fatalf("expected a timed out host with its open port kept:\n%s", data)
+	}
+}
+
+func TestExecuteScanMaxScanTime(t *testing.T) {
+	port := startStallingService(t)
+	outPath := filepath.Join(t.TempDir(), "max-scan-time.json")
+	req := ScanRequest{
+		Target:        "127.0.0.1",
+		PortsFlag:     strconv.Itoa(port),
+		Format:        "json",
+		OutputPath:    outPath,
+		TimeoutMS:     5000,
+		ServiceDetect: true,
+		DeepVersion:   true,
+		MaxScanTime:   300 * time.Millisecond,
+	}
+	start := time.Now()
+	if err := ExecuteScan(context.Background(), req); !errors.Is(err, ErrTimeLimit) {
+		t.Fatalf("expected ErrTimeLimit, got %v", err)
+	}
+	if elapsed := time.Since(start); elapsed > 3*time.Second {
+		t.Fatalf("scan outlived --max-scan-time: %v", elapsed)
+	}
+	data, err := os.ReadFile(outPath)
+	if err != nil {
+		t.Fatalf("read report: %v", err)
+	}
+	var report struct {
+		Hosts []struct {
+			OpenPorts int  `json:"open_ports"`
+			TimedOut  bool `json:"timed_out"`
+		} `json:"hosts"`
+	}
+	if err := json.Unmarshal(data, &report); err != nil {
+		t.Fatalf("invalid report: %v", err)
+	}
+	// The run's deadline is not a host timeout.
+	if len(report.Hosts) != 1 || report.Hosts[0].TimedOut || report.Hosts[0].OpenPorts != 1 {
+		t.Fatalf("expected the open port in a partial report:\n%s", data)
+	}
+}
+
+func TestExecuteScanCheckTarpit(t *testing.T) {
+	// Sixty ports that accept and hang up at once: all open, none talks.
+	var ports []string
+	for i := 0; i < 60; i++ {
+		ports = append(ports, strconv.Itoa(testutil.StartService(t, nil)))
+	}
+
+	type tarpitReport struct {
+		Hosts []struct {
+			OpenPorts int               `json:"open_ports"`
+			Suspected bool              `json:"suspected_tarpit"`
+			Results   []json.RawMessage `json:"results"`
+			Tarpit    *struct {
+				OpenPorts    int      `json:"open_ports"`
+				ScannedPorts int      `json:"scanned_ports"`
+				CanaryPort   int      `json:"canary_port"`
+				Reasons      []string `json:"reasons"`
+			} `json:"tarpit"`
+		} `json:"hosts"`
+	}
+	scan := func(portsFlag string, skip bool) tarpitReport {
+		t.Helper()
+		outPath := filepath.Join(t.TempDir(), "tarpit.json")
+		req := ScanRequest{
+			Target:        "127.0.0.1",
+			PortsFlag:     portsFlag,
+			Format:        "json",
+			OutputPath:    outPath,
+			TimeoutMS:     500,
+			Workers:       64,
+			ServiceDetect: true,
+			CheckTarpit:   true,
+			TarpitSkipSvc: skip,
+		}
+		if err := ExecuteScan(context.Background(), req); err != nil {
+			t.Fatalf("execute scan failed: %v", err)
+		}
+		data, err := os.ReadFile(outPath)
+		if err != nil {
+			t.Fatalf("read report: %v", err)
+		}
+		var report tarpitReport
+		if err := json.Unmarshal(data, &report); err != nil {
+			t.Fatalf("invalid report: %v", err)
+		}
+		if len(report.Hosts) != 1 {
+			t.Fatalf("expected one host:\n%s", data)
+		}
+		return report
+	}
+
+	for _, skip := range []bool{false, true} {
+		h := scan(strings.Join(ports, ","), skip).Hosts[0]
+		if !h.Suspected || h.Tarpit == nil || len(h.Results) != 0 || h.OpenPorts != 0 {
+			t.Fatalf("skip=%v: expected a collapsed tarpit, got %+v", skip, h)
+		}
+		if h.Tarpit.OpenPorts != 60 || h.Tarpit.ScannedPorts != 60 || h.Tarpit.CanaryPort == 0 || len(h.Tarpit.Reasons) < 2 {
+			t.Fatalf("skip=%v: unexpected tarpit evidence %+v", skip, h.Tarpit)
+		}
+	}
+
+	// A few open ports are just a host.
+	h := scan(strings.Join(ports[:3], ","), true).Hosts[0]
+	if h.Suspected || h.Tarpit != nil || h.OpenPorts != 3 {
+		t.Fatalf("expected a normal host, got %+v", h)
+	}
+
+	// Streamed and checkpointed rows wait for the verdict.
+	stream := func(portsFlag string) (records, checkpointed int) {
+		t.Helper()
+		dir := t.TempDir()
+		req := ScanRequest{
+			Target:         "127.0.0.1",
+			PortsFlag:      portsFlag,
+			Format:         "jsonl",
+			OutputPath:     filepath.Join(dir, "tarpit.jsonl"),
+			TimeoutMS:      500,
+			Workers:        64,
+			ServiceDetect:  true,
+			Stream:         true,
+			CheckpointPath: filepath.Join(dir, "tarpit.ckpt"),
+			CheckTarpit:    true,
+		}
+		if err := ExecuteScan(context.Background(), req); err != nil {
+			t.Fatalf("execute scan failed: %v", err)
+		}
+		for i, path := range []string{req.OutputPath, req.CheckpointPath} {
+			data, err := os.ReadFile(path)
+			if err != nil {
+				t.Fatalf("read %s: %v", path, err)
+			}
+			n := strings.Count(string(data), "\n")
+			if i == 0 {
+				records = n
+			} else {
+				checkpointed = n - 1 // header
+			}
+		}
+		return records, checkpointed
+	}
+	if records, checkpointed := stream(strings.Join(ports, ",")); records != 0 || checkpointed != 0 {
+		t.Fatalf("a suspected tarpit leaked %d streamed and %d checkpointed rows", records, checkpointed)
+	}
+	if records, checkpointed := stream(strings.Join(ports[:3], ",")); records != 3 || checkpointed != 3 {
+		t.Fatalf("a normal host should stream and checkpoint its 3 ports, got %d and %d", records, checkpointed)
+	}
+}
@@ -0,0 +1,89 @@
+package app
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/NexusFireMan/gomap/v2/pkg/scanner"
+)
+
+// collectTargetSpecs merges the positional target with -iL entries into one
+// comma-separated spec, and returns the label used in reports and banners.
+func collectTargetSpecs(req ScanRequest) (spec string, label string, err error) {
+	var specs, labels []string
+	if strings.TrimSpace(req.Target) != "" {
+		specs = append(specs, req.Target)
+		labels = append(labels, req.Target)
+	}
+	if req.TargetFile != "" {
+		fileSpecs, err := readSpecFile(req.TargetFile)
+		if err != nil {
+			return "", "", fmt.Errorf("cannot read target list: %w", err)
+		}
+		specs = append(specs, fileSpecs...)
+		if req.TargetFile == "-" {
+			labels = append(labels, "stdin")
+		} else {
+			labels = append(labels, req.TargetFile)
+		}
+	}
+	if len(specs) == 0 {
+		return "", "", errors.New("no targets specified")
+	}
+	return strings.Join(specs, ","), strings.Join(labels, ","), nil
+}
+
+// exclusions parses --exclude and --exclude-file entries into the address
+// ranges NewTargetSet subtracts from the targets. Exclusions match addresses,
+// so ParseExclusions resolves their hostnames locally even with a proxy.
+func exclusions(req ScanRequest, opts scanner.TargetOptions) (*scanner.Exclusions, error) {
+	var specs []string
+	if req.Exclude != "" {
+		specs = append(specs, strings.Split(req.Exclude, ",")...)
+	}
+	if req.ExcludeFile != "" {
+		fileSpecs, err := readSpecFile(req.ExcludeFile)
+		if err != nil {
+			return nil, fmt.Errorf("cannot read exclude file: %w", err)
+		}
+		specs = append(specs, fileSpecs...)
+	}
+	if len(specs) == 0 {
+		return nil, nil
+	}
+	return scanner.ParseExclusions(specs, opts)
+}
+
+// buildTargetSet expands the target spec lazily, minus any exclusions.
+func buildTargetSet(req ScanRequest, spec string, opts scanner.TargetOptions) (*scanner.TargetSet, error) {
+	excluded, err := exclusions(req, opts)
+	if err != nil {
+		return nil, err
+	}
+	opts.Exclude = excluded
+	set, err := scanner.NewTargetSet(spec, opts)
+	if err != nil {
+		return nil, fmt.Errorf("invalid target specification: %w", err)
+	}
+	if set.Len() == 0 {
+		return nil, errors.New("no targets left to scan after applying exclusions")
+	}
+	return set, nil
+}
+
+// readSpecFile reads a target list file; "-" reads from stdin.
+func readSpecFile(path string) ([]string, error) {
+	var r io.Reader = os.Stdin
+	if path != "-" {
+		f, err := os.Open(path)
+		if err != nil {
+			return nil, err
+		}
+		defer func() { _ = f.Close() }()
+		r = f
+	}
+	return scanner.ReadTargetList(r)
+}
@@ -84,9 +84,18 @@ func Version(version string) string {
 	return fmt.Sprintf("%s%s%s", ColorBrightYellow, version, ColorReset)
 }
 
-// State returns a green colored "open" state
+// State returns a port state colored by meaning: green open, red closed, yellow for filtered variants
 func State(state string) string {
-	return fmt.Sprintf("%s%s%s", ColorGreen, state, ColorReset)
+	color := ColorGreen
+	switch state {
+	case "closed":
+		color = ColorRed
+	case "filtered", "open|filtered":
+		color = ColorYellow
+	case "unfiltered":
+		color = ColorCyan
+	}
+	return fmt.Sprintf("%s%s%s", color, state, ColorReset)
 }
 
 // Host returns a bright blue colored hostname/IP
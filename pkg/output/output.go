@@ -64,11 +64,23 @@ func (of *OutputFormatter) PrintResults(results []scanner.ScanResult) {
 	}
 }
 
+// stateWidth returns the STATE column width needed for the given results.
+func stateWidth(results []scanner.ScanResult) int {
+	width := stateColWidth
+	for _, result := range results {
+		if n := len(result.EffectiveState()); n > width {
+			width = n
+		}
+	}
+	return width
+}
+
 // printBasic prints results without service information
 func (of *OutputFormatter) printBasic(results []scanner.ScanResult) {
-	fmt.Printf("%s%s%s\n", ColorBold, fmt.Sprintf("%-*s %-*s", portColWidth, "PORT", stateColWidth, "STATE"), ColorReset)
+	stateWidth := stateWidth(results)
+	fmt.Printf("%s%s%s\n", ColorBold, fmt.Sprintf("%-*s %-*s", portColWidth, "PORT", stateWidth, "STATE"), ColorReset)
 	for _, result := range results {
-		fmt.Printf("%s %s\n", padANSI(Port(result.Port), portColWidth), padANSI(State("open"), stateColWidth))
+		fmt.Printf("%s %s\n", padANSI(Port(result.Port), portColWidth), padANSI(State(string(result.EffectiveState())), stateWidth))
 	}
 }
 
@@ -77,13 +89,14 @@ func (of *OutputFormatter) printWithServices(results []scanner.ScanResult) {
 	if hostnames := detectedHostnames(results); len(hostnames) > 0 {
 		fmt.Printf("%s%s%s\n", ColorBold, "Detected Hostname: "+strings.Join(hostnames, ", "), ColorReset)
 	}
+	stateWidth := stateWidth(results)
 
 	if of.IncludeEvidence {
-		fmt.Printf("%s%s%s\n", ColorBold, fmt.Sprintf("%-*s %-*s %-*s %-36s %s", portColWidth, "PORT", stateColWidth, "STATE", serviceColWidth, "SERVICE", "VERSION", "EVIDENCE"), ColorReset)
+		fmt.Printf("%s%s%s\n", ColorBold, fmt.Sprintf("%-*s %-*s %-*s %-36s %s", portColWidth, "PORT", stateWidth, "STATE", serviceColWidth, "SERVICE", "VERSION", "EVIDENCE"), ColorReset)
 		for _, result := range results {
 			fmt.Printf("%s %s %s %-36s %s\n",
 				padANSI(Port(result.Port), portColWidth),
-				padANSI(State("open"), stateColWidth),
+				padANSI(State(string(result.EffectiveState())), stateWidth),
 				padANSI(Service(result.ServiceName), serviceColWidth),
 				padANSI(Version(result.Version), 36),
 				result.Evidence,
@@ -93,11 +106,11 @@ func (of *OutputFormatter) printWithServices(results []scanner.ScanResult) {
 	}
 
 	if of.IncludeDetails {
-		fmt.Printf("%s%s%s\n", ColorBold, fmt.Sprintf("%-*s %-*s %-*s %-36s %-7s %-8s %s", portColWidth, "PORT", stateColWidth, "STATE", serviceColWidth, "SERVICE", "VERSION", "LAT(ms)", "CONF", "EVIDENCE"), ColorReset)
+		fmt.Printf("%s%s%s\n", ColorBold, fmt.Sprintf("%-*s %-*s %-*s %-36s %-7s %-8s %s", portColWidth, "PORT", stateWidth, "STATE", serviceColWidth, "SERVICE", "VERSION", "LAT(ms)", "CONF", "EVIDENCE"), ColorReset)
 		for _, result := range results {
 			fmt.Printf("%s %s %s %-36s %-7d %-8s %s\n",
 				padANSI(Port(result.Port), portColWidth),
-				padANSI(State("open"), stateColWidth),
+				padANSI(State(string(result.EffectiveState())), stateWidth),
 				padANSI(Service(result.ServiceName), serviceColWidth),
 				Version(result.Version),
 				result.LatencyMs,
@@ -108,11 +121,11 @@ func (of *OutputFormatter) printWithServices(results []scanner.ScanResult) {
 		return
 	}
 
-	fmt.Printf("%s%s%s\n", ColorBold, fmt.Sprintf("%-*s %-*s %-*s %s", portColWidth, "PORT", stateColWidth, "STATE", serviceColWidth, "SERVICE", "VERSION"), ColorReset)
+	fmt.Printf("%s%s%s\n", ColorBold, fmt.Sprintf("%-*s %-*s %-*s %s", portColWidth, "PORT", stateWidth, "STATE", serviceColWidth, "SERVICE", "VERSION"), ColorReset)
 	for _, result := range results {
 		fmt.Printf("%s %s %s %s\n",
 			padANSI(Port(result.Port), portColWidth),
-			padANSI(State("open"), stateColWidth),
+			padANSI(State(string(result.EffectiveState())), stateWidth),
 			padANSI(Service(result.ServiceName), serviceColWidth),
 			Version(result.Version),
 		)
@@ -4,6 +4,7 @@ import (
 	"encoding/csv"
 	"encoding/json"
 	"io"
+	"math"
 	"os"
 	"strconv"
 	"time"
@@ -12,9 +13,71 @@ import (
 )
 
 type hostReport struct {
-	Host      string               `json:"host"`
-	OpenPorts int                  `json:"open_ports"`
-	Results   []scanner.ScanResult `json:"results"`
+	Host         string               `json:"host"`
+	OpenPorts    int                  `json:"open_ports"`
+	MAC          string               `json:"mac,omitempty"`
+	Vendor       string               `json:"vendor,omitempty"`
+	Discovery    *discoveryReport     `json:"discovery,omitempty"`
+	OSGuess      string               `json:"os_guess,omitempty"`
+	OSConfidence string               `json:"os_confidence,omitempty"`
+	OSEvidence   string               `json:"os_evidence,omitempty"`
+	HopDistance  int                  `json:"hop_distance,omitempty"`
+	Hops         []hopReport          `json:"hops,omitempty"`
+	Congestion   *congestionReport    `json:"congestion,omitempty"`
+	TimedOut     bool                 `json:"timed_out,omitempty"`
+	Tarpit       bool                 `json:"suspected_tarpit,omitempty"`
+	TarpitInfo   *tarpitReport        `json:"tarpit,omitempty"`
+	Results      []scanner.ScanResult `json:"results"`
+}
+
+type discoveryReport struct {
+	Reason string  `json:"reason"`
+	Port   int     `json:"port,omitempty"`
+	RTTMs  float64 `json:"rtt_ms"`
+}
+
+type hopReport struct {
+	TTL   int     `json:"ttl"`
+	Addr  string  `json:"addr,omitempty"`
+	RTTMs float64 `json:"rtt_ms,omitempty"`
+}
+
+type congestionReport struct {
+	Window     int     `json:"window"`
+	PeakWindow int     `json:"peak_window"`
+	MinWindow  int     `json:"min_window"`
+	MaxWindow  int     `json:"max_window"`
+	SRTTMs     float64 `json:"srtt_ms"`
+	RTTVarMs   float64 `json:"rttvar_ms"`
+	TimeoutMs  float64 `json:"timeout_ms"`
+	Samples    int     `json:"samples"`
+	Cuts       int     `json:"cuts"`
+}
+
+type tarpitReport struct {
+	OpenPorts    int      `json:"open_ports"`
+	ScannedPorts int      `json:"scanned_ports"`
+	CanaryPort   int      `json:"canary_port,omitempty"`
+	CanaryOpen   bool     `json:"canary_open"`
+	Reasons      []string `json:"reasons"`
+}
+
+// HostInfo carries per-host facts gathered outside the port scan itself.
+type HostInfo struct {
+	// Discovery is set when the host was found by host discovery.
+	Discovery *scanner.DiscoveryResult
+	// OS is set when OS detection produced a guess.
+	OS *scanner.OSGuess
+	// Route is set when --traceroute traced the host.
+	Route *scanner.Route
+	// Congestion is set when the host was connect-scanned with --congestion.
+	Congestion *scanner.CongestionStats
+	// TimedOut is set when --host-timeout abandoned the host; its results
+	// are whatever was found before that.
+	TimedOut bool
+	// Tarpit is set when --check-tarpit suspects the host answers every
+	// port; its port results were dropped.
+	Tarpit *scanner.TarpitReport
 }
 
 type scanReport struct {
@@ -26,9 +89,17 @@ type scanReport struct {
 	PortsRequested int          `json:"ports_requested"`
 	TotalOpenPorts int          `json:"total_open_ports"`
 	DurationMs     int64        `json:"duration_ms"`
+	RateLimit      *rateReport  `json:"rate_limit,omitempty"`
 	Hosts          []hostReport `json:"hosts"`
 }
 
+type rateReport struct {
+	MaxRate      int     `json:"max_rate"`
+	Burst        int     `json:"burst"`
+	Probes       uint64  `json:"probes"`
+	AchievedRate float64 `json:"achieved_rate"`
+}
+
 type jsonlRecord struct {
 	SchemaVersion string `json:"schema_version"`
 	GeneratedAt   string `json:"generated_at"`
@@ -54,7 +125,9 @@ type jsonlRecord struct {
 const reportSchemaVersion = "1.0.0"
 
 // PrintJSONReport prints the scan results in a machine-friendly JSON document.
-func PrintJSONReport(w io.Writer, target string, ports []int, targets []string, allResults map[string][]scanner.ScanResult, serviceScan bool, duration time.Duration) error {
+// hostInfo may be nil; entries add per-host sections such as discovery evidence.
+// rate, when set, adds the global --max-rate limits and the achieved rate.
+func PrintJSONReport(w io.Writer, target string, ports []int, targets []string, allResults map[string][]scanner.ScanResult, hostInfo map[string]HostInfo, serviceScan bool, duration time.Duration, rate *scanner.RateStats) error {
 	report := scanReport{
 		SchemaVersion:  reportSchemaVersion,
 		GeneratedAt:    time.Now().UTC().Format(time.RFC3339),
@@ -65,15 +138,68 @@ func PrintJSONReport(w io.Writer, target string, ports []int, targets []string,
 		DurationMs:     duration.Milliseconds(),
 		Hosts:          make([]hostReport, 0, len(targets)),
 	}
+	if rate != nil {
+		report.RateLimit = &rateReport{
+			MaxRate:      rate.MaxRate,
+			Burst:        rate.Burst,
+			Probes:       rate.Probes,
+			AchievedRate: math.Round(rate.Rate()*10) / 10,
+		}
+	}
 
 	for _, host := range targets {
 		results := allResults[host]
-		report.TotalOpenPorts += len(results)
-		report.Hosts = append(report.Hosts, hostReport{
+		openPorts := scanner.OpenCount(results)
+		report.TotalOpenPorts += openPorts
+		entry := hostReport{
 			Host:      host,
-			OpenPorts: len(results),
+			OpenPorts: openPorts,
 			Results:   results,
-		})
+		}
+		if d := hostInfo[host].Discovery; d != nil {
+			entry.MAC, entry.Vendor = d.MAC, d.Vendor
+			entry.Discovery = &discoveryReport{
+				Reason: d.Reason,
+				Port:   d.Port,
+				RTTMs:  float64(d.RTT.Microseconds()) / 1000,
+			}
+		}
+		if g := hostInfo[host].OS; g != nil {
+			entry.OSGuess, entry.OSConfidence, entry.OSEvidence = g.Name, g.Confidence, g.Evidence
+		}
+		if route := hostInfo[host].Route; route != nil {
+			if route.Reached {
+				entry.HopDistance = len(route.Hops)
+			}
+			for _, hop := range route.Hops {
+				entry.Hops = append(entry.Hops, hopReport{TTL: hop.TTL, Addr: hop.Addr, RTTMs: float64(hop.RTT.Microseconds()) / 1000})
+			}
+		}
+		if c := hostInfo[host].Congestion; c != nil {
+			entry.Congestion = &congestionReport{
+				Window:     c.Window,
+				PeakWindow: c.PeakWindow,
+				MinWindow:  c.MinWindow,
+				MaxWindow:  c.MaxWindow,
+				SRTTMs:     float64(c.SRTT.Microseconds()) / 1000,
+				RTTVarMs:   float64(c.RTTVar.Microseconds()) / 1000,
+				TimeoutMs:  float64(c.RTO.Microseconds()) / 1000,
+				Samples:    c.Samples,
+				Cuts:       c.Cuts,
+			}
+		}
+		entry.TimedOut = hostInfo[host].TimedOut
+		if t := hostInfo[host].Tarpit; t != nil {
+			entry.Tarpit = true
+			entry.TarpitInfo = &tarpitReport{
+				OpenPorts:    t.OpenPorts,
+				ScannedPorts: t.ScannedPorts,
+				CanaryPort:   t.CanaryPort,
+				CanaryOpen:   t.CanaryOpen,
+				Reasons:      t.Reasons,
+			}
+		}
+		report.Hosts = append(report.Hosts, entry)
 	}
 
 	enc := json.NewEncoder(w)
@@ -81,7 +207,7 @@ func PrintJSONReport(w io.Writer, target string, ports []int, targets []string,
 	return enc.Encode(report)
 }
 
-// PrintCSVReport prints one row per open port.
+// PrintCSVReport prints one row per reported port (open, plus closed/filtered when requested).
 func PrintCSVReport(writer io.Writer, allResults map[string][]scanner.ScanResult, targets []string) error {
 	w := csv.NewWriter(writer)
 	defer w.Flush()
@@ -97,7 +223,7 @@ func PrintCSVReport(writer io.Writer, allResults map[string][]scanner.ScanResult
 			row := []string{
 				host,
 				strconv.Itoa(r.Port),
-				"open",
+				string(r.EffectiveState()),
 				r.ServiceName,
 				r.Version,
 				r.Hostname,
@@ -121,33 +247,13 @@ func PrintCSVReport(writer io.Writer, allResults map[string][]scanner.ScanResult
 	return w.Error()
 }
 
-// PrintJSONLReport prints one JSON object per open port.
+// PrintJSONLReport prints one JSON object per reported port.
 func PrintJSONLReport(w io.Writer, target string, targets []string, allResults map[string][]scanner.ScanResult) error {
 	enc := json.NewEncoder(w)
 	for _, host := range targets {
 		results := allResults[host]
 		for _, r := range results {
-			rec := jsonlRecord{
-				SchemaVersion: reportSchemaVersion,
-				GeneratedAt:   time.Now().UTC().Format(time.RFC3339),
-				Target:        target,
-				Host:          host,
-				Port:          r.Port,
-				State:         "open",
-				Service:       r.ServiceName,
-				Version:       r.Version,
-				Hostname:      r.Hostname,
-				TLS:           r.TLS,
-				TLSVersion:    r.TLSVersion,
-				TLSCipher:     r.TLSCipher,
-				TLSALPN:       r.TLSALPN,
-				TLSServerName: r.TLSServerName,
-				TLSIssuer:     r.TLSIssuer,
-				LatencyMs:     r.LatencyMs,
-				Confidence:    r.Confidence,
-				Evidence:      r.Evidence,
-				DetectionPath: r.DetectionPath,
-			}
+			rec := newJSONLRecord(target, host, r)
 			if err := enc.Encode(rec); err != nil {
 				return err
 			}
@@ -156,6 +262,30 @@ func PrintJSONLReport(w io.Writer, target string, targets []string, allResults m
 	return nil
 }
 
+func newJSONLRecord(target, host string, r scanner.ScanResult) jsonlRecord {
+	return jsonlRecord{
+		SchemaVersion: reportSchemaVersion,
+		GeneratedAt:   time.Now().UTC().Format(time.RFC3339),
+		Target:        target,
+		Host:          host,
+		Port:          r.Port,
+		State:         string(r.EffectiveState()),
+		Service:       r.ServiceName,
+		Version:       r.Version,
+		Hostname:      r.Hostname,
+		TLS:           r.TLS,
+		TLSVersion:    r.TLSVersion,
+		TLSCipher:     r.TLSCipher,
+		TLSALPN:       r.TLSALPN,
+		TLSServerName: r.TLSServerName,
+		TLSIssuer:     r.TLSIssuer,
+		LatencyMs:     r.LatencyMs,
+		Confidence:    r.Confidence,
+		Evidence:      r.Evidence,
+		DetectionPath: r.DetectionPath,
+	}
+}
+
 // DefaultWriter returns stdout for output rendering.
 func DefaultWriter() io.Writer {
 	return os.Stdout
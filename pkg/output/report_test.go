@@ -52,7 +52,7 @@ func sampleResults() ([]string, map[string][]scanner.ScanResult) {
 func TestPrintJSONReport(t *testing.T) {
 	targets, results := sampleResults()
 	var buf bytes.Buffer
-	if err := PrintJSONReport(&buf, "10.0.11.6", []int{80, 445}, targets, results, true, 150*time.Millisecond); err != nil {
+	if err := PrintJSONReport(&buf, "10.0.11.6", []int{80, 445}, targets, results, nil, true, 150*time.Millisecond, nil); err != nil {
 		t.Fatalf("unexpected error: %v", err)
 	}
 
@@ -99,7 +99,7 @@ func TestPrintJSONReportEmptyResults(t *testing.T) {
 	targets := []string{"10.0.11.6", "10.0.11.7"}
 	results := map[string][]scanner.ScanResult{}
 	var buf bytes.Buffer
-	if err := PrintJSONReport(&buf, "10.0.11.0/24", []int{80, 443}, targets, results, false, 42*time.Millisecond); err != nil {
+	if err := PrintJSONReport(&buf, "10.0.11.0/24", []int{80, 443}, targets, results, nil, false, 42*time.Millisecond, nil); err != nil {
 		t.Fatalf("unexpected error: %v", err)
 	}
 
@@ -126,6 +126,178 @@ func TestPrintJSONReportEmptyResults(t *testing.T) {
 	}
 }
 
+func TestPrintJSONReportDiscoveryReason(t *testing.T) {
+	targets := []string{"10.0.11.6", "10.0.11.7"}
+	info := map[string]HostInfo{
+		"10.0.11.6": {Discovery: &scanner.DiscoveryResult{Host: "10.0.11.6", Reason: scanner.ReasonTCPRefused, Port: 443, RTT: 1500 * time.Microsecond}},
+		"10.0.11.7": {Discovery: &scanner.DiscoveryResult{Host: "10.0.11.7", Reason: scanner.ReasonARPResponse, MAC: "00:50:56:aa:bb:cc", Vendor: "VMware"}},
+	}
+	var buf bytes.Buffer
+	if err := PrintJSONReport(&buf, "10.0.11.0/24", []int{80}, targets, map[string][]scanner.ScanResult{}, info, false, time.Millisecond, nil); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	var report scanReport
+	if err := json.Unmarshal(buf.Bytes(), &report); err != nil {
+		t.Fatalf("invalid json report: %v\n%s", err, buf.String())
+	}
+	d := report.Hosts[0].Discovery
+	if d == nil || d.Reason != "tcp-refused" || d.Port != 443 || d.RTTMs != 1.5 {
+		t.Fatalf("unexpected discovery section: %+v", d)
+	}
+	if report.Hosts[0].MAC != "" {
+		t.Fatalf("expected no mac for a TCP-discovered host, got %q", report.Hosts[0].MAC)
+	}
+	arp := report.Hosts[1]
+	if arp.MAC != "00:50:56:aa:bb:cc" || arp.Vendor != "VMware" || arp.Discovery == nil || arp.Discovery.Reason != "arp-response" || arp.Discovery.Port != 0 {
+		t.Fatalf("unexpected arp host entry: %+v", arp)
+	}
+}
+
+func TestPrintJSONReportOSGuess(t *testing.T) {
+	targets := []string{"10.0.11.6", "10.0.11.7"}
+	info := map[string]HostInfo{
+		"10.0.11.6": {OS: &scanner.OSGuess{Name: "Linux 3.x-6.x", Confidence: "high", Evidence: "ttl=63 win=65160 mss=1460 ws=7 opts=M,S,T,N,W"}},
+	}
+	var buf bytes.Buffer
+	if err := PrintJSONReport(&buf, "10.0.11.0/24", []int{22}, targets, map[string][]scanner.ScanResult{}, info, false, time.Millisecond, nil); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	var report scanReport
+	if err := json.Unmarshal(buf.Bytes(), &report); err != nil {
+		t.Fatalf("invalid json report: %v\n%s", err, buf.String())
+	}
+	h := report.Hosts[0]
+	if h.OSGuess != "Linux 3.x-6.x" || h.OSConfidence != "high" || !strings.Contains(h.OSEvidence, "opts=M,S,T,N,W") {
+		t.Fatalf("unexpected os fields: %+v", h)
+	}
+	if strings.Count(buf.String(), `"os_guess"`) != 1 {
+		t.Fatalf("expected no os fields without a guess:\n%s", buf.String())
+	}
+}
+
+func TestPrintJSONReportRoute(t *testing.T) {
+	targets := []string{"10.0.11.6", "10.0.12.9"}
+	info := map[string]HostInfo{
+		"10.0.11.6": {Route: &scanner.Route{Reached: true, Hops: []scanner.Hop{
+			{TTL: 1, Addr: "192.0.2.1", RTT: 500 * time.Microsecond},
+			{TTL: 2},
+			{TTL: 3, Addr: "10.0.11.6", RTT: 2 * time.Millisecond},
+		}}},
+		"10.0.12.9": {Route: &scanner.Route{Hops: []scanner.Hop{{TTL: 1, Addr: "192.0.2.1"}}}},
+	}
+	var buf bytes.Buffer
+	if err := PrintJSONReport(&buf, "10.0.11.6,10.0.12.9", []int{22}, targets, map[string][]scanner.ScanResult{}, info, false, time.Millisecond, nil); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	var report scanReport
+	if err := json.Unmarshal(buf.Bytes(), &report); err != nil {
+		t.Fatalf("invalid json report: %v\n%s", err, buf.String())
+	}
+	reached := report.Hosts[0]
+	want := []hopReport{{TTL: 1, Addr: "192.0.2.1", RTTMs: 0.5}, {TTL: 2}, {TTL: 3, Addr: "10.0.11.6", RTTMs: 2}}
+	if reached.HopDistance != 3 || !reflect.DeepEqual(reached.Hops, want) {
+		t.Fatalf("unexpected route fields: %+v", reached)
+	}
+	if unreached := report.Hosts[1]; unreached.HopDistance != 0 || len(unreached.Hops) != 1 {
+		t.Fatalf("expected hops without a distance for an unreached host: %+v", unreached)
+	}
+}
+
+func TestPrintJSONReportCongestion(t *testing.T) {
+	targets := []string{"10.0.11.6", "10.0.11.7"}
+	info := map[string]HostInfo{
+		"10.0.11.6": {Congestion: &scanner.CongestionStats{Window: 48, PeakWindow: 96, MinWindow: 25, MaxWindow: 200, SRTT: 12500 * time.Microsecond, RTTVar: 3 * time.Millisecond, RTO: 500 * time.Millisecond, Samples: 900, Cuts: 2}},
+	}
+	var buf bytes.Buffer
+	if err := PrintJSONReport(&buf, "10.0.11.6-7", []int{22}, targets, map[string][]scanner.ScanResult{}, info, false, time.Millisecond, nil); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	var report scanReport
+	if err := json.Unmarshal(buf.Bytes(), &report); err != nil {
+		t.Fatalf("invalid json report: %v\n%s", err, buf.String())
+	}
+	want := &congestionReport{Window: 48, PeakWindow: 96, MinWindow: 25, MaxWindow: 200, SRTTMs: 12.5, RTTVarMs: 3, TimeoutMs: 500, Samples: 900, Cuts: 2}
+	if got := report.Hosts[0].Congestion; !reflect.DeepEqual(got, want) {
+		t.Fatalf("unexpected congestion fields: %+v", got)
+	}
+	if strings.Count(buf.String(), `"congestion"`) != 1 {
+		t.Fatalf("expected no congestion fields without stats:\n%s", buf.String())
+	}
+}
+
+func TestPrintJSONReportRateLimit(t *testing.T) {
+	rate := &scanner.RateStats{MaxRate: 300, Burst: 30, Probes: 1200, Elapsed: 4 * time.Second}
+	var buf bytes.Buffer
+	if err := PrintJSONReport(&buf, "10.0.11.0/24", []int{22}, nil, map[string][]scanner.ScanResult{}, nil, false, 4*time.Second, rate); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	var report scanReport
+	if err := json.Unmarshal(buf.Bytes(), &report); err != nil {
+		t.Fatalf("invalid json report: %v\n%s", err, buf.String())
+	}
+	want := &rateReport{MaxRate: 300, Burst: 30, Probes: 1200, AchievedRate: 300}
+	if !reflect.DeepEqual(report.RateLimit, want) {
+		t.Fatalf("unexpected rate_limit: %+v", report.RateLimit)
+	}
+
+	buf.Reset()
+	if err := PrintJSONReport(&buf, "10.0.11.0/24", []int{22}, nil, map[string][]scanner.ScanResult{}, nil, false, time.Second, nil); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if strings.Contains(buf.String(), "rate_limit") {
+		t.Fatalf("expected no rate_limit without --max-rate:\n%s", buf.String())
+	}
+}
+
+func TestPrintJSONReportTimedOut(t *testing.T) {
+	targets, results := sampleResults()
+	targets = append(targets, "10.0.11.7")
+	info := map[string]HostInfo{targets[0]: {TimedOut: true}}
+	var buf bytes.Buffer
+	if err := PrintJSONReport(&buf, targets[0], []int{22, 80}, targets, results, info, true, time.Second, nil); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	var report scanReport
+	if err := json.Unmarshal(buf.Bytes(), &report); err != nil {
+		t.Fatalf("invalid json report: %v\n%s", err, buf.String())
+	}
+	if len(report.Hosts) != 2 || !report.Hosts[0].TimedOut || len(report.Hosts[0].Results) == 0 || report.Hosts[1].TimedOut {
+		t.Fatalf("expected a timed out host with its results: %+v", report.Hosts[0])
+	}
+	if strings.Count(buf.String(), "timed_out") != 1 {
+		t.Fatalf("timed_out should only appear on the abandoned host:\n%s", buf.String())
+	}
+}
+
+func TestPrintJSONReportTarpit(t *testing.T) {
+	targets := []string{"10.0.11.6"}
+	info := map[string]HostInfo{"10.0.11.6": {Tarpit: &scanner.TarpitReport{
+		Suspected:    true,
+		OpenPorts:    998,
+		ScannedPorts: 1000,
+		CanaryPort:   48211,
+		CanaryOpen:   true,
+		Reasons:      []string{"canary port 48211 open", "998/1000 ports open"},
+	}}}
+	var buf bytes.Buffer
+	if err := PrintJSONReport(&buf, targets[0], nil, targets, map[string][]scanner.ScanResult{}, info, false, time.Second, nil); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	var report scanReport
+	if err := json.Unmarshal(buf.Bytes(), &report); err != nil {
+		t.Fatalf("invalid json report: %v\n%s", err, buf.String())
+	}
+	h := report.Hosts[0]
+	want := &tarpitReport{OpenPorts: 998, ScannedPorts: 1000, CanaryPort: 48211, CanaryOpen: true, Reasons: []string{"canary port 48211 open", "998/1000 ports open"}}
+	if !h.Tarpit || !reflect.DeepEqual(h.TarpitInfo, want) || h.OpenPorts != 0 {
+		t.Fatalf("unexpected tarpit host entry: %+v", h)
+	}
+}
+
 func TestPrintCSVReport(t *testing.T) {
 	targets, results := sampleResults()
 	var buf bytes.Buffer
@@ -236,3 +408,54 @@ func TestPrintJSONLReportEmptyResults(t *testing.T) {
 		t.Fatalf("expected empty jsonl stream to decode as EOF, got %v", err)
 	}
 }
+
+func TestReportsCarryNonOpenStates(t *testing.T) {
+	targets := []string{"10.0.11.6"}
+	results := map[string][]scanner.ScanResult{
+		"10.0.11.6": {
+			{Port: 22, IsOpen: true, State: scanner.PortOpen, ServiceName: "ssh"},
+			{Port: 23, State: scanner.PortClosed},
+			{Port: 161, State: scanner.PortOpenFiltered},
+		},
+	}
+
+	var jsonBuf bytes.Buffer
+	if err := PrintJSONReport(&jsonBuf, "10.0.11.6", []int{22, 23, 161}, targets, results, nil, false, time.Millisecond, nil); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	var report scanReport
+	if err := json.Unmarshal(jsonBuf.Bytes(), &report); err != nil {
+		t.Fatalf("invalid json report: %v", err)
+	}
+	if report.TotalOpenPorts != 1 || report.Hosts[0].OpenPorts != 1 || len(report.Hosts[0].Results) != 3 {
+		t.Fatalf("expected only open ports counted, got %+v", report)
+	}
+	if report.Hosts[0].Results[1].State != scanner.PortClosed {
+		t.Fatalf("expected closed state in json results, got %+v", report.Hosts[0].Results[1])
+	}
+
+	var csvBuf bytes.Buffer
+	if err := PrintCSVReport(&csvBuf, results, targets); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	rows, err := csv.NewReader(strings.NewReader(csvBuf.String())).ReadAll()
+	if err != nil {
+		t.Fatalf("invalid csv output: %v", err)
+	}
+	if rows[2][2] != "closed" || rows[3][2] != "open|filtered" {
+		t.Fatalf("unexpected csv states: %v", rows)
+	}
+
+	var jsonlBuf bytes.Buffer
+	if err := PrintJSONLReport(&jsonlBuf, "10.0.11.6", targets, results); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	lines := strings.Split(strings.TrimSpace(jsonlBuf.String()), "\n")
+	var rec jsonlRecord
+	if err := json.Unmarshal([]byte(lines[1]), &rec); err != nil {
+		t.Fatalf("invalid jsonl record: %v", err)
+	}
+	if rec.State != "closed" {
+		t.Fatalf("expected closed jsonl state, got %+v", rec)
+	}
+}
@@ -0,0 +1,89 @@
+package output
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"sync"
+
+	"github.com/NexusFireMan/gomap/v2/pkg/scanner"
+)
+
+// ResultSink receives scan results one at a time as they are confirmed.
+// Emit may be called concurrently from several scan workers.
+type ResultSink interface {
+	Emit(host string, result scanner.ScanResult) error
+	Close() error
+}
+
+// JSONLSink writes one JSONL record per result the moment it is emitted.
+// Records use the same schema as PrintJSONLReport.
+type JSONLSink struct {
+	mu     sync.Mutex
+	enc    *json.Encoder
+	target string
+	err    error
+}
+
+// NewJSONLSink creates a streaming JSONL sink writing to w.
+func NewJSONLSink(w io.Writer, target string) *JSONLSink {
+	return &JSONLSink{enc: json.NewEncoder(w), target: target}
+}
+
+// Emit writes a single record. After the first write error, later records are dropped.
+func (s *JSONLSink) Emit(host string, result scanner.ScanResult) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.err != nil {
+		return s.err
+	}
+	s.err = s.enc.Encode(newJSONLRecord(s.target, host, result))
+	return s.err
+}
+
+// Close returns the first write error, if any.
+func (s *JSONLSink) Close() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.err
+}
+
+// TextSink prints one colored line per result the moment it is emitted.
+type TextSink struct {
+	mu              sync.Mutex
+	w               io.Writer
+	includeServices bool
+	err             error
+}
+
+// NewTextSink creates a live text sink writing to w.
+func NewTextSink(w io.Writer, includeServices bool) *TextSink {
+	return &TextSink{w: w, includeServices: includeServices}
+}
+
+// Emit prints a single result line prefixed with its host.
+func (s *TextSink) Emit(host string, result scanner.ScanResult) error {
+	line := fmt.Sprintf("%s %s %s",
+		padANSI(Host(host), 16),
+		padANSI(Port(result.Port), portColWidth),
+		padANSI(State(string(result.EffectiveState())), len(scanner.PortOpenFiltered)),
+	)
+	if s.includeServices {
+		line += " " + padANSI(Service(result.ServiceName), serviceColWidth) + " " + Version(result.Version)
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.err != nil {
+		return s.err
+	}
+	_, s.err = fmt.Fprintln(s.w, line)
+	return s.err
+}
+
+// Close returns the first write error, if any.
+func (s *TextSink) Close() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.err
+}
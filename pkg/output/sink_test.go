@@ -0,0 +1,73 @@
+package output
+
+import (
+	"bytes"
+	"encoding/json"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/NexusFireMan/gomap/v2/pkg/scanner"
+)
+
+func TestJSONLSinkMatchesReportSchema(t *testing.T) {
+	targets, results := sampleResults()
+	var streamed, batch bytes.Buffer
+
+	sink := NewJSONLSink(&streamed, "10.0.11.6")
+	for _, r := range results[targets[0]] {
+		if err := sink.Emit(targets[0], r); err != nil {
+			t.Fatalf("emit failed: %v", err)
+		}
+	}
+	if err := sink.Close(); err != nil {
+		t.Fatalf("close failed: %v", err)
+	}
+	if err := PrintJSONLReport(&batch, "10.0.11.6", targets, results); err != nil {
+		t.Fatalf("batch report failed: %v", err)
+	}
+
+	streamLines := strings.Split(strings.TrimSpace(streamed.String()), "\n")
+	batchLines := strings.Split(strings.TrimSpace(batch.String()), "\n")
+	if len(streamLines) != len(batchLines) {
+		t.Fatalf("expected %d streamed records, got %d", len(batchLines), len(streamLines))
+	}
+	for i := range streamLines {
+		var got, want jsonlRecord
+		if err := json.Unmarshal([]byte(streamLines[i]), &got); err != nil {
+			t.Fatalf("invalid streamed record: %v", err)
+		}
+		if err := json.Unmarshal([]byte(batchLines[i]), &want); err != nil {
+			t.Fatalf("invalid batch record: %v", err)
+		}
+		got.GeneratedAt, want.GeneratedAt = "", ""
+		if got != want {
+			t.Fatalf("record %d differs:\nstream: %+v\nbatch:  %+v", i, got, want)
+		}
+	}
+}
+
+func TestTextSinkConcurrentEmitKeepsLinesWhole(t *testing.T) {
+	var buf bytes.Buffer
+	sink := NewTextSink(&buf, true)
+	var wg sync.WaitGroup
+	for port := 1; port <= 50; port++ {
+		wg.Add(1)
+		go func(port int) {
+			defer wg.Done()
+			_ = sink.Emit("10.0.11.6", scanner.ScanResult{Port: port, IsOpen: true, State: scanner.PortOpen, ServiceName: "http"})
+		}(port)
+	}
+	wg.Wait()
+
+	lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	if len(lines) != 50 {
+		t.Fatalf("expected 50 lines, got %d", len(lines))
+	}
+	for _, line := range lines {
+		clean := ansiPattern.ReplaceAllString(line, "")
+		if !strings.HasPrefix(clean, "10.0.11.6") || !strings.Contains(clean, "open") || !strings.Contains(clean, "http") {
+			t.Fatalf("unexpected stream line: %q", clean)
+		}
+	}
+}
@@ -0,0 +1,159 @@
+package scanner
+
+import (
+	"bufio"
+	_ "embed"
+	"encoding/binary"
+	"net"
+	"net/netip"
+	"strings"
+	"sync"
+)
+
+const (
+	etherTypeARP   = 0x0806
+	arpFrameLen    = 60 // minimum Ethernet frame without FCS
+	arpOpRequest   = 1
+	arpOpReply     = 2
+	arpHeaderStart = 14
+)
+
+//go:embed oui.txt
+var ouiTable string
+
+var (
+	ouiOnce    sync.Once
+	ouiVendors map[string]string
+)
+
+// LookupVendor returns the vendor registered for mac's OUI in the embedded
+// table, or "" when the prefix is unknown.
+func LookupVendor(mac net.HardwareAddr) string {
+	if len(mac) < 3 {
+		return ""
+	}
+	ouiOnce.Do(func() {
+		ouiVendors = make(map[string]string)
+		sc := bufio.NewScanner(strings.NewReader(ouiTable))
+		for sc.Scan() {
+			line := strings.TrimSpace(sc.Text())
+			if line == "" || strings.HasPrefix(line, "#") {
+				continue
+			}
+			prefix, vendor, ok := strings.Cut(line, "\t")
+			if !ok {
+				continue
+			}
+			ouiVendors[strings.ToLower(prefix)] = strings.TrimSpace(vendor)
+		}
+	})
+	return ouiVendors[mac[:3].String()]
+}
+
+// buildARPRequest returns a broadcast Ethernet frame asking who has dst.
+func buildARPRequest(srcMAC net.HardwareAddr, src, dst netip.Addr) []byte {
+	frame := make([]byte, arpFrameLen)
+	copy(frame[0:6], net.HardwareAddr{0xff, 0xff, 0xff, 0xff, 0xff, 0xff})
+	copy(frame[6:12], srcMAC)
+	binary.BigEndian.PutUint16(frame[12:14], etherTypeARP)
+
+	arp := frame[arpHeaderStart:]
+	binary.BigEndian.PutUint16(arp[0:2], 1)      // hardware type: Ethernet
+	binary.BigEndian.PutUint16(arp[2:4], 0x0800) // protocol type: IPv4
+	arp[4], arp[5] = 6, 4
+	binary.BigEndian.PutUint16(arp[6:8], arpOpRequest)
+	copy(arp[8:14], srcMAC)
+	src4, dst4 := src.As4(), dst.As4()
+	copy(arp[14:18], src4[:])
+	// Target hardware address stays zero.
+	copy(arp[24:28], dst4[:])
+	return frame
+}
+
+// parseARPReply extracts the sender of an Ethernet ARP reply.
+func parseARPReply(frame []byte) (netip.Addr, net.HardwareAddr, bool) {
+	if len(frame) < arpHeaderStart+28 || binary.BigEndian.Uint16(frame[12:14]) != etherTypeARP {
+		return netip.Addr{}, nil, false
+	}
+	arp := frame[arpHeaderStart:]
+	if binary.BigEndian.Uint16(arp[2:4]) != 0x0800 || arp[4] != 6 || arp[5] != 4 || binary.BigEndian.Uint16(arp[6:8]) != arpOpReply {
+		return netip.Addr{}, nil, false
+	}
+	mac := make(net.HardwareAddr, 6)
+	copy(mac, arp[8:14])
+	return netip.AddrFrom4([4]byte(arp[14:18])), mac, true
+}
+
+// arpLink is a directly attached Ethernet interface with its IPv4 subnets.
+// Prefixes keep the interface address, which is the ARP sender address.
+type arpLink struct {
+	iface    net.Interface
+	prefixes []netip.Prefix
+}
+
+// localARPLinks lists up, non-loopback Ethernet interfaces that carry IPv4
+// subnets. A pinned source keeps only its interface and the subnets of its
+// address.
+func localARPLinks(src SourceConfig) []arpLink {
+	ifaces, err := net.Interfaces()
+	if err != nil {
+		return nil
+	}
+	var links []arpLink
+	for _, iface := range ifaces {
+		if iface.Flags&net.FlagUp == 0 || iface.Flags&net.FlagLoopback != 0 || len(iface.HardwareAddr) != 6 {
+			continue
+		}
+		if src.Interface != "" && iface.Name != src.Interface {
+			continue
+		}
+		addrs, err := iface.Addrs()
+		if err != nil {
+			continue
+		}
+		link := arpLink{iface: iface}
+		for _, a := range addrs {
+			ipNet, ok := a.(*net.IPNet)
+			if !ok {
+				continue
+			}
+			ip, ok := netip.AddrFromSlice(ipNet.IP)
+			ones, _ := ipNet.Mask.Size()
+			if !ok || !ip.Unmap().Is4() || ones >= 32 {
+				continue
+			}
+			if src.IP != nil && !ipNet.IP.Equal(src.IP) {
+				continue
+			}
+			link.prefixes = append(link.prefixes, netip.PrefixFrom(ip.Unmap(), ones))
+		}
+		if len(link.prefixes) > 0 {
+			links = append(links, link)
+		}
+	}
+	return links
+}
+
+// source returns the interface address to send from when asking for addr.
+func (l arpLink) source(addr netip.Addr) (netip.Addr, bool) {
+	for _, p := range l.prefixes {
+		if p.Contains(addr) {
+			return p.Addr(), true
+		}
+	}
+	return netip.Addr{}, false
+}
+
+// OnLocalSubnet reports whether any target in ts lies on a directly attached
+// Ethernet IPv4 subnet, where ARP discovery can reach it. With a pinned
+// source, only the subnets of its interface and address count.
+func OnLocalSubnet(ts *TargetSet, src SourceConfig) bool {
+	for _, link := range localARPLinks(src) {
+		for _, p := range link.prefixes {
+			if ts.overlaps(prefixInterval(p)) {
+				return true
+			}
+		}
+	}
+	return false
+}
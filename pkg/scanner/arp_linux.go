@@ -0,0 +1,157 @@
+package scanner
+
+import (
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"net"
+	"net/netip"
+	"os"
+	"sync"
+	"syscall"
+	"time"
+)
+
+// arpProber sends ARP requests on every directly attached Ethernet interface
+// through AF_PACKET sockets and matches replies by sender address.
+type arpProber struct {
+	links []*arpSocket
+}
+
+type arpSocket struct {
+	arpLink
+	file *os.File
+
+	mu      sync.Mutex
+	waiting map[netip.Addr]chan net.HardwareAddr
+}
+
+func openARPProber(src SourceConfig) (*arpProber, error) {
+	links := localARPLinks(src)
+	if len(links) == 0 {
+		if !src.IsZero() {
+			return nil, errors.New("no Ethernet interface with an IPv4 subnet matches the source binding")
+		}
+		return nil, errors.New("no Ethernet interface with an IPv4 subnet")
+	}
+	p := &arpProber{}
+	for _, link := range links {
+		sock, err := openARPSocket(link)
+		if err != nil {
+			p.Close()
+			return nil, err
+		}
+		p.links = append(p.links, sock)
+	}
+	return p, nil
+}
+
+func openARPSocket(link arpLink) (*arpSocket, error) {
+	proto := htons(etherTypeARP)
+	fd, err := syscall.Socket(syscall.AF_PACKET, syscall.SOCK_RAW|syscall.SOCK_NONBLOCK|syscall.SOCK_CLOEXEC, int(proto))
+	if err != nil {
+		if errors.Is(err, syscall.EPERM) || errors.Is(err, syscall.EACCES) {
+			return nil, errors.New("insufficient privileges for ARP discovery (needs root/CAP_NET_RAW)")
+		}
+		return nil, fmt.Errorf("failed to open packet socket: %w", err)
+	}
+	if err := syscall.Bind(fd, &syscall.SockaddrLinklayer{Protocol: proto, Ifindex: link.iface.Index}); err != nil {
+		_ = syscall.Close(fd)
+		return nil, fmt.Errorf("failed to bind packet socket to %s: %w", link.iface.Name, err)
+	}
+	// A non-blocking fd goes through the runtime poller, so Close unblocks the reader.
+	sock := &arpSocket{
+		arpLink: link,
+		file:    os.NewFile(uintptr(fd), "arp-"+link.iface.Name),
+		waiting: make(map[netip.Addr]chan net.HardwareAddr),
+	}
+	go sock.readLoop()
+	return sock, nil
+}
+
+// probe asks for host on the interface whose subnet contains it. Hosts that
+// are not on a local subnet are skipped so later methods can try them.
+func (p *arpProber) probe(ctx context.Context, host string, timeout time.Duration) (DiscoveryResult, bool) {
+	addr, err := netip.ParseAddr(host)
+	if err != nil || !addr.Unmap().Is4() {
+		return DiscoveryResult{}, false
+	}
+	addr = addr.Unmap()
+	for _, sock := range p.links {
+		src, ok := sock.source(addr)
+		if !ok || src == addr {
+			continue
+		}
+		mac, rtt, ok := sock.resolve(ctx, src, addr, timeout)
+		if !ok {
+			return DiscoveryResult{}, false
+		}
+		return DiscoveryResult{Host: host, Reason: ReasonARPResponse, RTT: rtt, MAC: mac.String(), Vendor: LookupVendor(mac)}, true
+	}
+	return DiscoveryResult{}, false
+}
+
+func (p *arpProber) Close() {
+	for _, sock := range p.links {
+		_ = sock.file.Close()
+	}
+}
+
+func (s *arpSocket) resolve(ctx context.Context, src, dst netip.Addr, timeout time.Duration) (net.HardwareAddr, time.Duration, bool) {
+	replied := make(chan net.HardwareAddr, 1)
+	s.mu.Lock()
+	s.waiting[dst] = replied
+	s.mu.Unlock()
+	defer func() {
+		s.mu.Lock()
+		delete(s.waiting, dst)
+		s.mu.Unlock()
+	}()
+
+	start := time.Now()
+	if _, err := s.file.Write(buildARPRequest(s.iface.HardwareAddr, src, dst)); err != nil {
+		return nil, 0, false
+	}
+	timer := time.NewTimer(timeout)
+	defer timer.Stop()
+	select {
+	case mac := <-replied:
+		return mac, time.Since(start), true
+	case <-timer.C:
+	case <-ctx.Done():
+	}
+	return nil, 0, false
+}
+
+func (s *arpSocket) readLoop() {
+	buf := make([]byte, 1514)
+	for {
+		n, err := s.file.Read(buf)
+		if err != nil {
+			if errors.Is(err, os.ErrClosed) {
+				return
+			}
+			continue
+		}
+		sender, mac, ok := parseARPReply(buf[:n])
+		if !ok {
+			continue
+		}
+		s.mu.Lock()
+		if replied, waiting := s.waiting[sender]; waiting {
+			select {
+			case replied <- mac:
+			default:
+			}
+		}
+		s.mu.Unlock()
+	}
+}
+
+// htons converts v to network byte order as the packet socket API expects.
+func htons(v uint16) uint16 {
+	var b [2]byte
+	binary.BigEndian.PutUint16(b[:], v)
+	return binary.NativeEndian.Uint16(b[:])
+}
@@ -0,0 +1,22 @@
+//go:build !linux
+
+package scanner
+
+import (
+	"context"
+	"errors"
+	"time"
+)
+
+// arpProber is only implemented on Linux, where AF_PACKET sockets are available.
+type arpProber struct{}
+
+func openARPProber(SourceConfig) (*arpProber, error) {
+	return nil, errors.New("ARP discovery is only supported on Linux")
+}
+
+func (p *arpProber) probe(context.Context, string, time.Duration) (DiscoveryResult, bool) {
+	return DiscoveryResult{}, false
+}
+
+func (p *arpProber) Close() {}
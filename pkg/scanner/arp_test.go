@@ -0,0 +1,61 @@
+package scanner
+
+import (
+	"net"
+	"net/netip"
+	"testing"
+)
+
+func TestARPRequestAndReplyFrames(t *testing.T) {
+	srcMAC := net.HardwareAddr{0x00, 0x0c, 0x29, 0x11, 0x22, 0x33}
+	req := buildARPRequest(srcMAC, netip.MustParseAddr("10.0.11.1"), netip.MustParseAddr("10.0.11.6"))
+	if len(req) != arpFrameLen || req[0] != 0xff || req[12] != 0x08 || req[13] != 0x06 {
+		t.Fatalf("unexpected request header: % x", req[:14])
+	}
+	if _, _, ok := parseARPReply(req); ok {
+		t.Fatal("a request must not parse as a reply")
+	}
+
+	// Turn the request into the reply 10.0.11.6 would send back.
+	reply := append([]byte(nil), req...)
+	reply[arpHeaderStart+7] = arpOpReply
+	copy(reply[arpHeaderStart+8:], []byte{0xb8, 0x27, 0xeb, 0x44, 0x55, 0x66})
+	copy(reply[arpHeaderStart+14:], []byte{10, 0, 11, 6})
+	sender, mac, ok := parseARPReply(reply)
+	if !ok || sender != netip.MustParseAddr("10.0.11.6") || mac.String() != "b8:27:eb:44:55:66" {
+		t.Fatalf("unexpected reply parse: %v %v %v", sender, mac, ok)
+	}
+	if _, _, ok := parseARPReply(reply[:30]); ok {
+		t.Fatal("expected truncated frame to be rejected")
+	}
+}
+
+func TestLookupVendor(t *testing.T) {
+	cases := map[string]string{
+		"00:50:56:aa:bb:cc": "VMware",
+		"B8:27:EB:01:02:03": "Raspberry Pi Foundation",
+		"02:fc:00:00:00:05": "",
+	}
+	for addr, want := range cases {
+		mac, err := net.ParseMAC(addr)
+		if err != nil {
+			t.Fatalf("parse %s: %v", addr, err)
+		}
+		if got := LookupVendor(mac); got != want {
+			t.Fatalf("%s: expected %q, got %q", addr, want, got)
+		}
+	}
+}
+
+func TestTargetSetOverlaps(t *testing.T) {
+	ts, err := NewTargetSet("10.0.11.200-10.0.12.5", TargetOptions{})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !ts.overlaps(prefixInterval(netip.MustParsePrefix("10.0.12.0/24"))) {
+		t.Fatal("expected overlap with 10.0.12.0/24")
+	}
+	if ts.overlaps(prefixInterval(netip.MustParsePrefix("10.0.13.0/24"))) {
+		t.Fatal("unexpected overlap with 10.0.13.0/24")
+	}
+}
@@ -1,6 +1,10 @@
 package scanner
 
-import "testing"
+import (
+	"net/netip"
+	"strings"
+	"testing"
+)
 
 func TestParseHTTPCUPSAsIPP(t *testing.T) {
 	banner := "HTTP/1.1 200 OK\r\nServer: CUPS/1.7 IPP/2.1\r\nConnection: close\r\n\r\n"
@@ -23,3 +27,33 @@ func TestParseHTTPTomcatFromTitle(t *testing.T) {
 		t.Fatalf("unexpected version %q", version)
 	}
 }
+
+func TestBuildHTTPRequestBracketsIPv6Host(t *testing.T) {
+	s := NewScanner("fd00:11::6", false)
+	req := s.buildHTTPRequest("GET", "/")
+	if !strings.Contains(req, "Host: [fd00:11::6]\r\n") {
+		t.Fatalf("expected bracketed ipv6 host header, got %q", req)
+	}
+}
+
+func TestRandomHeaderIPStaysInIPv6Prefix(t *testing.T) {
+	s := NewScanner("fd00:11::6", false)
+	s.Configure(ScanConfig{RandomIP: true, TargetCIDR: "fd00:11::/120"})
+	prefix := netip.MustParsePrefix("fd00:11::/120")
+	for i := 0; i < 50; i++ {
+		ip := s.randomHeaderIP()
+		addr, err := netip.ParseAddr(ip)
+		if err != nil || !prefix.Contains(addr) || addr == prefix.Addr() {
+			t.Fatalf("random header ip %q outside %s", ip, prefix)
+		}
+	}
+}
+
+func TestRandomHeaderIPSingleIPv6HostUsesSlash64(t *testing.T) {
+	s := NewScanner("fd00:11::6", false)
+	s.Configure(ScanConfig{RandomIP: true})
+	addr, err := netip.ParseAddr(s.randomHeaderIP())
+	if err != nil || !netip.MustParsePrefix("fd00:11::/64").Contains(addr) {
+		t.Fatalf("expected header ip inside fd00:11::/64, got %v (%v)", addr, err)
+	}
+}
@@ -0,0 +1,48 @@
+package scanner
+
+import "context"
+
+// DialBudget bounds the number of in-flight connection attempts shared by
+// every Scanner that references it, so several hosts can be scanned at once
+// without multiplying the total dial concurrency.
+type DialBudget struct {
+	slots chan struct{}
+}
+
+// NewDialBudget creates a shared budget allowing up to n concurrent dials.
+func NewDialBudget(n int) *DialBudget {
+	if n <= 0 {
+		n = 1
+	}
+	return &DialBudget{slots: make(chan struct{}, n)}
+}
+
+// Size returns the maximum number of concurrent dials allowed by the budget.
+func (b *DialBudget) Size() int {
+	if b == nil {
+		return 0
+	}
+	return cap(b.slots)
+}
+
+// acquire blocks until a dial slot is available or ctx is done, and reports
+// whether a slot was taken. A nil budget is unbounded.
+func (b *DialBudget) acquire(ctx context.Context) bool {
+	if b == nil {
+		return ctx.Err() == nil
+	}
+	select {
+	case b.slots <- struct{}{}:
+		return true
+	case <-ctx.Done():
+		return false
+	}
+}
+
+// release returns a dial slot to the budget.
+func (b *DialBudget) release() {
+	if b == nil {
+		return
+	}
+	<-b.slots
+}
@@ -0,0 +1,121 @@
+package scanner
+
+import (
+	"context"
+	"net"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/NexusFireMan/gomap/v2/internal/testutil"
+)
+
+func TestDialBudgetBoundsConcurrency(t *testing.T) {
+	budget := NewDialBudget(2)
+	if budget.Size() != 2 {
+		t.Fatalf("expected budget size 2, got %d", budget.Size())
+	}
+
+	var inFlight, peak int32
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			budget.acquire(context.Background())
+			defer budget.release()
+			cur := atomic.AddInt32(&inFlight, 1)
+			for {
+				prev := atomic.LoadInt32(&peak)
+				if cur <= prev || atomic.CompareAndSwapInt32(&peak, prev, cur) {
+					break
+				}
+			}
+			time.Sleep(10 * time.Millisecond)
+			atomic.AddInt32(&inFlight, -1)
+		}()
+	}
+	wg.Wait()
+	if peak > 2 {
+		t.Fatalf("expected at most 2 concurrent holders, saw %d", peak)
+	}
+}
+
+func TestNilDialBudgetIsUnbounded(t *testing.T) {
+	var budget *DialBudget
+	if !budget.acquire(context.Background()) {
+		t.Fatal("a nil budget should always grant a slot")
+	}
+	budget.release()
+	if budget.Size() != 0 {
+		t.Fatalf("expected nil budget size 0, got %d", budget.Size())
+	}
+}
+
+func TestDialBudgetAcquireHonoursContext(t *testing.T) {
+	budget := NewDialBudget(1)
+	budget.acquire(context.Background())
+	defer budget.release()
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	start := time.Now()
+	if budget.acquire(ctx) {
+		t.Fatal("a full budget granted a slot")
+	}
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Fatalf("acquire outlived its context: %v", elapsed)
+	}
+}
+
+func TestScanBudgetWaitIsNotRTT(t *testing.T) {
+	port := testutil.StartService(t, nil)
+	budget := NewDialBudget(1)
+	budget.acquire(context.Background())
+	time.AfterFunc(300*time.Millisecond, budget.release)
+
+	s := NewScanner("127.0.0.1", false)
+	s.Configure(ScanConfig{Timeout: time.Second, NumWorkers: 1, Budget: budget, Congestion: true})
+	results := s.Scan(context.Background(), []int{port}, false)
+	if len(results) != 1 {
+		t.Fatalf("unexpected results: %+v", results)
+	}
+	// The dial waited 300ms for a slot but took well under that on loopback.
+	if st := s.CongestionStats(); st == nil || st.Samples != 1 || st.SRTT >= 100*time.Millisecond {
+		t.Fatalf("budget wait counted as RTT: %+v", st)
+	}
+}
+
+func TestScanWithSharedBudget(t *testing.T) {
+	port := testutil.StartService(t, nil)
+	s := NewScanner("127.0.0.1", false)
+	s.Configure(ScanConfig{Timeout: 200 * time.Millisecond, NumWorkers: 4, Budget: NewDialBudget(1)})
+
+	results := s.Scan(context.Background(), []int{port}, false)
+	if len(results) != 1 || results[0].Port != port {
+		t.Fatalf("unexpected results with shared budget: %+v", results)
+	}
+}
+
+func TestServiceDialsHoldBudget(t *testing.T) {
+	address := net.JoinHostPort("127.0.0.1", strconv.Itoa(testutil.StartService(t, nil)))
+	budget := NewDialBudget(1)
+	s := NewScanner("127.0.0.1", false)
+	s.Configure(ScanConfig{Budget: budget})
+
+	budget.acquire(context.Background())
+	time.AfterFunc(200*time.Millisecond, budget.release)
+	start := time.Now()
+	conn, err := s.dial("tcp", address, time.Second)
+	if err != nil {
+		t.Fatalf("dial: %v", err)
+	}
+	_ = conn.Close()
+	if elapsed := time.Since(start); elapsed < 150*time.Millisecond {
+		t.Fatalf("service dial did not wait for the full budget (%v)", elapsed)
+	}
+	if len(budget.slots) != 0 {
+		t.Fatal("service dial kept its budget slot")
+	}
+}
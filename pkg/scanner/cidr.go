@@ -3,119 +3,141 @@ package scanner
 import (
 	"fmt"
 	"net"
+	"net/netip"
 	"strings"
-	"sync"
-	"time"
 )
 
-// DiscoveryOptions controls CIDR host discovery behavior.
-type DiscoveryOptions struct {
-	Ports      []int
-	Timeout    time.Duration
-	NumWorkers int
+// TargetOptions controls how target specifications are resolved.
+type TargetOptions struct {
+	// PreferIPv6 resolves hostnames to their AAAA records instead of A records.
+	PreferIPv6 bool
+	// Exclude removes these addresses from the expanded target set.
+	Exclude *Exclusions
+	// RemoteDNS keeps hostnames unresolved so a proxy resolves them on the
+	// far side. Exclusions never match such hosts.
+	RemoteDNS bool
 }
 
+// maxExpandedHosts caps how many addresses the slice-returning helpers
+// (ExpandCIDR, ParseTargets) will materialize. Use NewTargetSet for larger ranges.
+const maxExpandedHosts = 65536 // 65K hosts max (256^2)
+
 // ExpandCIDR expands a CIDR notation to a list of IPs
 func ExpandCIDR(cidr string) ([]string, error) {
+	return ExpandCIDRWithOptions(cidr, TargetOptions{})
+}
+
+// ExpandCIDRWithOptions expands a CIDR, octet or dash range, literal address, or
+// hostname to a list of IPs. IPv4 and IPv6 ranges are both accepted as long as they
+// fit the expansion cap.
+func ExpandCIDRWithOptions(cidr string, opts TargetOptions) ([]string, error) {
+	ts, err := NewTargetSet(cidr, opts)
+	if err != nil {
+		return nil, err
+	}
+	return ts.Collect(cidr, maxExpandedHosts)
+}
+
+// parseSpecIntervals turns one target spec (address, hostname, CIDR, octet or dash
+// range) into address intervals without expanding it.
+func parseSpecIntervals(spec string, opts TargetOptions) ([]addrInterval, error) {
 	// Check if it's a single IP address
-	if !strings.Contains(cidr, "/") {
+	if !strings.Contains(spec, "/") {
 		// If literal IP is provided, keep it as-is (avoid DNS lookup side-effects).
-		if ip := net.ParseIP(cidr); ip != nil {
-			return []string{ip.String()}, nil
+		if ip := net.ParseIP(spec); ip != nil {
+			addr, _ := netip.AddrFromSlice(ip)
+			return []addrInterval{singleInterval(addr.Unmap())}, nil
 		}
-
-		// Try to resolve as hostname/IP
-		ips, err := net.LookupIP(cidr)
-		if err != nil || len(ips) == 0 {
-			return nil, fmt.Errorf("invalid IP address or hostname: %s", cidr)
+		// Scoped IPv6 literals such as fe80::1%eth0 need netip to keep the zone.
+		if addr, err := netip.ParseAddr(spec); err == nil {
+			return []addrInterval{singleInterval(addr)}, nil
 		}
-		// Prefer IPv4 for consistency with many local lab/network setups.
-		for _, ip := range ips {
-			if v4 := ip.To4(); v4 != nil {
-				return []string{v4.String()}, nil
-			}
+		if isRangeSpec(spec) {
+			return rangeIntervals(spec)
+		}
+		if opts.RemoteDNS {
+			return []addrInterval{{name: spec}}, nil
+		}
+
+		addr, err := resolveHostname(spec, opts)
+		if err != nil {
+			return nil, err
 		}
-		// Fall back to first resolved address (likely IPv6-only host).
-		return []string{ips[0].String()}, nil
+		return []addrInterval{singleInterval(addr)}, nil
 	}
 
 	// Parse CIDR notation
-	ip, ipnet, err := net.ParseCIDR(cidr)
+	ip, ipnet, err := net.ParseCIDR(spec)
 	if err != nil {
-		return nil, fmt.Errorf("invalid CIDR notation: %s - %v", cidr, err)
+		return nil, fmt.Errorf("invalid CIDR notation: %s - %v", spec, err)
 	}
-
-	// If it's a /32 (single host), just return it
-	if ipnet.IP.String() == ip.String() {
-		ones, bits := ipnet.Mask.Size()
-		if ones == bits {
-			return []string{ip.String()}, nil
-		}
+	ones, bits := ipnet.Mask.Size()
+	// If it's a /32 or /128 (single host), just return it
+	if ones == bits {
+		addr, _ := netip.AddrFromSlice(ip)
+		return []addrInterval{singleInterval(addr.Unmap())}, nil
 	}
 
-	// Calculate number of hosts
-	ones, bits := ipnet.Mask.Size()
 	hostBits := bits - ones
-	numHosts := 1 << uint(hostBits)
+	// IPv6 prefixes can have up to 128 host bits; check before shifting.
+	if hostBits > 32 {
+		return nil, rangeTooLargeError(spec, maxIteratedHosts)
+	}
+	base, _ := netip.AddrFromSlice(ipnet.IP)
+	iv := prefixInterval(netip.PrefixFrom(base.Unmap(), base.Unmap().BitLen()-hostBits))
+	// Skip IPv4 network and broadcast addresses for non-/31 and non-/32 networks.
+	// IPv6 has no broadcast address, so every address in the prefix is a host.
+	if bits == 32 && hostBits > 1 {
+		iv.start = iv.start.next()
+		iv.end = iv.end.prev()
+	}
+	return []addrInterval{iv}, nil
+}
 
-	// For large CIDR blocks, limit the expansion
-	maxHosts := 65536 // 65K hosts max (256^2)
-	if numHosts > maxHosts {
-		return nil, fmt.Errorf("CIDR range too large (%d hosts). Maximum: %d hosts. Use a smaller range", numHosts, maxHosts)
+func resolveHostname(host string, opts TargetOptions) (netip.Addr, error) {
+	ips, err := net.LookupIP(host)
+	if err != nil || len(ips) == 0 {
+		return netip.Addr{}, fmt.Errorf("invalid IP address or hostname: %s", host)
 	}
-
-	var ips []string
-	ip = ipnet.IP.Mask(ipnet.Mask)
-
-	for i := 0; i < numHosts; i++ {
-		// Skip network address and broadcast address for non-/31 and non-/32 networks
-		if hostBits > 1 && (i == 0 || i == numHosts-1) {
-			incrementIP(ip)
-			continue
+	pick := func(ip net.IP) netip.Addr {
+		addr, _ := netip.AddrFromSlice(ip)
+		return addr.Unmap()
+	}
+	if opts.PreferIPv6 {
+		for _, ip := range ips {
+			if ip.To4() == nil {
+				return pick(ip), nil
+			}
 		}
-
-		ips = append(ips, ip.String())
-		incrementIP(ip)
+		return netip.Addr{}, fmt.Errorf("no IPv6 address found for %s", host)
 	}
-
-	return ips, nil
-}
-
-// incrementIP increments an IP address by 1
-func incrementIP(ip net.IP) {
-	for j := len(ip) - 1; j >= 0; j-- {
-		ip[j]++
-		if ip[j] > 0 {
-			break
+	// Prefer IPv4 for consistency with many local lab/network setups.
+	for _, ip := range ips {
+		if v4 := ip.To4(); v4 != nil {
+			return pick(v4), nil
 		}
 	}
+	// Fall back to first resolved address (likely IPv6-only host).
+	return pick(ips[0]), nil
 }
 
 // ParseTargets parses target(s) which can be single IP, multiple IPs, or CIDR notation
 // Format: "192.168.1.1" or "192.168.1.0/24" or "192.168.1.1,192.168.1.5"
 func ParseTargets(target string) ([]string, error) {
-	targets := strings.Split(target, ",")
-	var allIPs []string
-
-	for _, t := range targets {
-		t = strings.TrimSpace(t)
-		if t == "" {
-			continue
-		}
-
-		ips, err := ExpandCIDR(t)
-		if err != nil {
-			return nil, err
-		}
+	return ParseTargetsWithOptions(target, TargetOptions{})
+}
 
-		allIPs = append(allIPs, ips...)
+// ParseTargetsWithOptions is ParseTargets with explicit resolution options.
+// Overlapping entries are deduplicated, keeping the first occurrence.
+func ParseTargetsWithOptions(target string, opts TargetOptions) ([]string, error) {
+	ts, err := NewTargetSet(target, opts)
+	if err != nil {
+		return nil, err
 	}
-
-	if len(allIPs) == 0 {
+	if ts.Len() == 0 {
 		return nil, fmt.Errorf("no valid targets found")
 	}
-
-	return allIPs, nil
+	return ts.Collect(target, maxExpandedHosts)
 }
 
 // FormatCIDRInfo returns a human-readable description of what will be scanned
@@ -133,85 +155,13 @@ func FormatCIDRInfo(target string) (string, int, error) {
 	return fmt.Sprintf("%s-%s", ips[0], ips[len(ips)-1]), len(ips), nil
 }
 
-// IsCIDR checks if a target is a CIDR range or single IP
+// IsCIDR checks if a target contains a range (CIDR, octet or dash range) rather than only single hosts
 func IsCIDR(target string) bool {
 	// Remove commas if multiple targets
 	targets := strings.Split(target, ",")
 	for _, t := range targets {
 		t = strings.TrimSpace(t)
-		if strings.Contains(t, "/") {
-			return true
-		}
-	}
-	return false
-}
-
-// DiscoverActiveHosts performs a quick host discovery on a CIDR range
-// It attempts to connect to common ports (443, 80, 22, 445, 3306) to determine if hosts are active
-func DiscoverActiveHosts(hosts []string, timeout time.Duration, numWorkers int) []string {
-	return DiscoverActiveHostsWithOptions(hosts, DiscoveryOptions{
-		Ports:      []int{443, 80, 22, 445, 3306, 8080, 3389},
-		Timeout:    timeout,
-		NumWorkers: numWorkers,
-	})
-}
-
-// DiscoverActiveHostsWithOptions performs host discovery using configurable probe ports and concurrency.
-func DiscoverActiveHostsWithOptions(hosts []string, opts DiscoveryOptions) []string {
-	if len(hosts) <= 1 {
-		// Skip discovery for single IPs or empty lists
-		return hosts
-	}
-
-	commonPorts := opts.Ports
-	if len(commonPorts) == 0 {
-		commonPorts = []int{443, 80, 22}
-	}
-	timeout := opts.Timeout
-	if timeout <= 0 {
-		timeout = 500 * time.Millisecond
-	}
-	numWorkers := opts.NumWorkers
-	if numWorkers <= 0 {
-		numWorkers = 25
-	}
-	activeChan := make(chan string, len(hosts))
-	var wg sync.WaitGroup
-	semaphore := make(chan struct{}, numWorkers)
-
-	for _, host := range hosts {
-		wg.Add(1)
-		go func(h string) {
-			defer wg.Done()
-			semaphore <- struct{}{}        // Acquire slot
-			defer func() { <-semaphore }() // Release slot
-
-			if isHostActive(h, commonPorts, timeout) {
-				activeChan <- h
-			}
-		}(host)
-	}
-
-	go func() {
-		wg.Wait()
-		close(activeChan)
-	}()
-
-	var activeHosts []string
-	for host := range activeChan {
-		activeHosts = append(activeHosts, host)
-	}
-
-	return activeHosts
-}
-
-// isHostActive checks if a host is reachable by attempting connections to common ports
-func isHostActive(host string, ports []int, timeout time.Duration) bool {
-	for _, port := range ports {
-		address := net.JoinHostPort(host, fmt.Sprintf("%d", port))
-		conn, err := net.DialTimeout("tcp", address, timeout)
-		if err == nil {
-			_ = conn.Close()
+		if strings.Contains(t, "/") || isRangeSpec(t) {
 			return true
 		}
 	}
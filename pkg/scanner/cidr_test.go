@@ -11,3 +11,53 @@ func TestExpandCIDRLiteralIPv4(t *testing.T) {
 		t.Fatalf("unexpected ips: %#v", ips)
 	}
 }
+
+func TestExpandCIDRLiteralIPv6(t *testing.T) {
+	ips, err := ExpandCIDR("fd00:11::6")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(ips) != 1 || ips[0] != "fd00:11::6" {
+		t.Fatalf("unexpected ips: %#v", ips)
+	}
+}
+
+func TestExpandCIDRScopedIPv6KeepsZone(t *testing.T) {
+	ips, err := ExpandCIDR("fe80::1%eth0")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(ips) != 1 || ips[0] != "fe80::1%eth0" {
+		t.Fatalf("unexpected ips: %#v", ips)
+	}
+}
+
+func TestExpandCIDRSmallIPv6Prefix(t *testing.T) {
+	ips, err := ExpandCIDR("fd00:11::/120")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	// IPv6 has no broadcast address, so all 256 addresses are kept.
+	if len(ips) != 256 {
+		t.Fatalf("expected 256 hosts, got %d", len(ips))
+	}
+	if ips[0] != "fd00:11::" || ips[255] != "fd00:11::ff" {
+		t.Fatalf("unexpected range: %s - %s", ips[0], ips[255])
+	}
+}
+
+func TestExpandCIDRLargeIPv6PrefixRejected(t *testing.T) {
+	if _, err := ExpandCIDR("fd00:11::/64"); err == nil {
+		t.Fatal("expected /64 to exceed the expansion cap")
+	}
+}
+
+func TestExpandCIDRIPv4SkipsNetworkAndBroadcast(t *testing.T) {
+	ips, err := ExpandCIDR("10.0.11.0/30")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(ips) != 2 || ips[0] != "10.0.11.1" || ips[1] != "10.0.11.2" {
+		t.Fatalf("unexpected ips: %#v", ips)
+	}
+}
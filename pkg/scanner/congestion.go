@@ -0,0 +1,222 @@
+package scanner
+
+import (
+	"context"
+	"math"
+	"sync"
+	"time"
+)
+
+const (
+	// initialWindow is the number of dials allowed in flight before the
+	// first answer comes back.
+	initialWindow = 10
+	// timeoutBurst is how many consecutive timeouts count as a loss event.
+	// Isolated timeouts are what filtered ports look like and are ignored.
+	timeoutBurst = 3
+	// rtoGranularity is the clock granularity G of RFC 6298.
+	rtoGranularity = time.Millisecond
+	// minRTO is the floor of measured timeouts. RFC 6298 asks for 1s; like
+	// Linux, a 200ms floor lets fast networks time out well below --timeout.
+	minRTO = 200 * time.Millisecond
+)
+
+// CongestionStats summarizes the congestion window and RTT estimates of a
+// connect scan run with ScanConfig.Congestion.
+type CongestionStats struct {
+	// Window is the congestion window when the scan ended.
+	Window int
+	// PeakWindow is the largest window reached.
+	PeakWindow int
+	// MinWindow and MaxWindow bound the window; MaxWindow is NumWorkers.
+	MinWindow int
+	MaxWindow int
+	// SRTT, RTTVar and RTO are the RFC 6298 estimates; RTO is the dial
+	// timeout after clamping.
+	SRTT   time.Duration
+	RTTVar time.Duration
+	RTO    time.Duration
+	// Samples counts answered dials (open or refused) that fed the estimates.
+	Samples int
+	// Cuts counts the times a timeout burst halved the window.
+	Cuts int
+}
+
+// congestionWindow limits in-flight dials to a window that grows on
+// answered dials (slow start, then additive increase) and halves on bursts
+// of timeouts, at most once per round of probes.
+type congestionWindow struct {
+	mu       sync.Mutex
+	wake     chan struct{} // closed and replaced when a slot may have opened
+	cwnd     float64
+	ssthresh float64
+	minWin   float64
+	maxWin   float64
+	peak     float64
+	inFlight int
+	sent     uint64 // dials started so far
+	cutAt    uint64 // value of sent at the last cut
+	streak   int
+
+	srtt    time.Duration
+	rttvar  time.Duration
+	initRTO time.Duration
+	minRTO  time.Duration
+	maxRTO  time.Duration
+	samples int
+	cuts    int
+}
+
+// newCongestionWindow creates a window of up to maxWindow dials. Dials time
+// out after initRTO until the first answer, then after the measured RTO,
+// which stays within [min(minRTO, initRTO), maxRTO]. The window never drops
+// below an eighth of maxWindow so heavily filtered hosts still finish.
+func newCongestionWindow(maxWindow int, initRTO, maxRTO time.Duration) *congestionWindow {
+	if maxWindow < 1 {
+		maxWindow = 1
+	}
+	c := &congestionWindow{
+		wake:    make(chan struct{}),
+		minWin:  math.Max(1, float64(maxWindow/8)),
+		maxWin:  float64(maxWindow),
+		initRTO: initRTO,
+		minRTO:  min(minRTO, initRTO),
+		maxRTO:  maxRTO,
+	}
+	c.cwnd = math.Min(math.Max(initialWindow, c.minWin), c.maxWin)
+	c.ssthresh = c.maxWin
+	c.peak = c.cwnd
+	return c
+}
+
+// acquire blocks until the window has room for one more dial and returns
+// the dial's sequence number. It returns false if ctx is cancelled first.
+// A nil window is unbounded.
+func (c *congestionWindow) acquire(ctx context.Context) (uint64, bool) {
+	if c == nil {
+		return 0, ctx.Err() == nil
+	}
+	for {
+		c.mu.Lock()
+		if c.inFlight < int(c.cwnd) {
+			c.inFlight++
+			c.sent++
+			seq := c.sent
+			c.mu.Unlock()
+			return seq, true
+		}
+		wake := c.wake
+		c.mu.Unlock()
+		select {
+		case <-wake:
+		case <-ctx.Done():
+			return 0, false
+		}
+	}
+}
+
+// release frees the slot of a dial whose outcome says nothing about the
+// path, e.g. one cut short by cancellation.
+func (c *congestionWindow) release() {
+	if c == nil {
+		return
+	}
+	c.mu.Lock()
+	c.inFlight--
+	c.signal()
+	c.mu.Unlock()
+}
+
+// observe frees the slot of dial seq and adjusts the window and RTT
+// estimates for its outcome: open and refused ports are answers, timeouts
+// may be loss, anything else (e.g. unreachable) is neutral.
+func (c *congestionWindow) observe(seq uint64, err error, rtt time.Duration) {
+	if c == nil {
+		return
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.inFlight--
+	defer c.signal()
+
+	switch {
+	case err == nil || connectErrorState(err) == PortClosed:
+		c.streak = 0
+		c.sample(rtt)
+		if c.cwnd < c.ssthresh {
+			c.cwnd++
+		} else {
+			c.cwnd += 1 / c.cwnd
+		}
+		c.cwnd = math.Min(c.cwnd, c.maxWin)
+		c.peak = math.Max(c.peak, c.cwnd)
+	case isDialTimeoutError(err):
+		c.streak++
+		// Dials started before the last cut belong to the burst that
+		// caused it, so one burst only halves the window once.
+		if c.streak >= timeoutBurst && seq > c.cutAt {
+			c.ssthresh = math.Max(c.cwnd/2, c.minWin)
+			c.cwnd = c.ssthresh
+			c.cutAt = c.sent
+			c.streak = 0
+			c.cuts++
+		}
+	}
+}
+
+// sample folds one RTT measurement into SRTT and RTTVAR (RFC 6298, 2.2-2.3).
+func (c *congestionWindow) sample(rtt time.Duration) {
+	if rtt <= 0 {
+		rtt = time.Microsecond
+	}
+	if c.samples == 0 {
+		c.srtt = rtt
+		c.rttvar = rtt / 2
+	} else {
+		delta := c.srtt - rtt
+		if delta < 0 {
+			delta = -delta
+		}
+		c.rttvar = (3*c.rttvar + delta) / 4
+		c.srtt = (7*c.srtt + rtt) / 8
+	}
+	c.samples++
+}
+
+// signal wakes every goroutine waiting in acquire. c.mu must be held.
+func (c *congestionWindow) signal() {
+	close(c.wake)
+	c.wake = make(chan struct{})
+}
+
+// timeout returns RTO = SRTT + max(G, 4*RTTVAR) clamped to the window's
+// bounds, or the initial timeout until the first answer.
+func (c *congestionWindow) timeout() time.Duration {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.rto()
+}
+
+func (c *congestionWindow) rto() time.Duration {
+	if c.samples == 0 {
+		return min(c.initRTO, c.maxRTO)
+	}
+	rto := c.srtt + max(rtoGranularity, 4*c.rttvar)
+	return min(max(rto, c.minRTO), c.maxRTO)
+}
+
+func (c *congestionWindow) stats() CongestionStats {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return CongestionStats{
+		Window:     int(c.cwnd),
+		PeakWindow: int(c.peak),
+		MinWindow:  int(c.minWin),
+		MaxWindow:  int(c.maxWin),
+		SRTT:       c.srtt,
+		RTTVar:     c.rttvar,
+		RTO:        c.rto(),
+		Samples:    c.samples,
+		Cuts:       c.cuts,
+	}
+}
@@ -0,0 +1,192 @@
+package scanner
+
+import (
+	"context"
+	"net"
+	"os"
+	"sync"
+	"syscall"
+	"testing"
+	"time"
+)
+
+var (
+	errDialTimeout = &net.OpError{Op: "dial", Net: "tcp", Err: os.ErrDeadlineExceeded}
+	errDialRefused = &net.OpError{Op: "dial", Net: "tcp", Err: os.NewSyscallError("connect", syscall.ECONNREFUSED)}
+)
+
+// dialOnce runs one dial through the window with the given outcome.
+func dialOnce(t *testing.T, c *congestionWindow, err error, rtt time.Duration) {
+	t.Helper()
+	seq, ok := c.acquire(context.Background())
+	if !ok {
+		t.Fatal("acquire failed")
+	}
+	c.observe(seq, err, rtt)
+}
+
+func TestCongestionWindowGrowsOnAnswers(t *testing.T) {
+	c := newCongestionWindow(40, 100*time.Millisecond, time.Second)
+	if got := c.stats().Window; got != initialWindow {
+		t.Fatalf("expected initial window %d, got %d", initialWindow, got)
+	}
+	// Slow start: one slot per answer, refused ports included.
+	for i := 0; i < 5; i++ {
+		dialOnce(t, c, nil, 10*time.Millisecond)
+		dialOnce(t, c, errDialRefused, 10*time.Millisecond)
+	}
+	if got := c.stats().Window; got != initialWindow+10 {
+		t.Fatalf("expected window %d after slow start, got %d", initialWindow+10, got)
+	}
+	for i := 0; i < 100; i++ {
+		dialOnce(t, c, nil, 10*time.Millisecond)
+	}
+	if st := c.stats(); st.Window != 40 || st.PeakWindow != 40 {
+		t.Fatalf("window should stop at the maximum, got %+v", st)
+	}
+	// Errors that are neither answers nor timeouts leave the window alone.
+	dialOnce(t, c, &net.OpError{Op: "dial", Net: "tcp", Err: os.NewSyscallError("connect", syscall.EHOSTUNREACH)}, time.Millisecond)
+	if st := c.stats(); st.Window != 40 || st.Samples != 110 {
+		t.Fatalf("unreachable should be neutral, got %+v", st)
+	}
+}
+
+func TestCongestionWindowHalvesOncePerBurst(t *testing.T) {
+	c := newCongestionWindow(64, 100*time.Millisecond, time.Second)
+	for i := 0; i < 30; i++ {
+		dialOnce(t, c, nil, 10*time.Millisecond)
+	}
+
+	// Isolated timeouts look like filtered ports and must not shrink it.
+	dialOnce(t, c, errDialTimeout, time.Second)
+	dialOnce(t, c, nil, 10*time.Millisecond)
+	dialOnce(t, c, errDialTimeout, time.Second)
+	if st := c.stats(); st.Cuts != 0 {
+		t.Fatalf("isolated timeouts cut the window: %+v", st)
+	}
+	before := c.stats().Window
+
+	// A whole window of dials times out together: one cut, not one per
+	// timeout.
+	seqs := make([]uint64, before)
+	for i := range seqs {
+		seqs[i], _ = c.acquire(context.Background())
+	}
+	for _, seq := range seqs {
+		c.observe(seq, errDialTimeout, time.Second)
+	}
+	st := c.stats()
+	if st.Cuts != 1 || st.Window != before/2 {
+		t.Fatalf("expected one cut to %d, got %+v", before/2, st)
+	}
+
+	// Later bursts keep halving down to the floor.
+	for i := 0; i < 10; i++ {
+		for j := 0; j < timeoutBurst; j++ {
+			dialOnce(t, c, errDialTimeout, time.Second)
+		}
+	}
+	if st := c.stats(); st.Window != 64/8 || st.MinWindow != 64/8 {
+		t.Fatalf("window should stop at the floor, got %+v", st)
+	}
+}
+
+func TestCongestionWindowRTO(t *testing.T) {
+	c := newCongestionWindow(10, 50*time.Millisecond, 2*time.Second)
+	if got := c.timeout(); got != 50*time.Millisecond {
+		t.Fatalf("expected the initial timeout before any sample, got %v", got)
+	}
+	// RFC 6298: SRTT=R, RTTVAR=R/2, RTO=SRTT+4*RTTVAR.
+	dialOnce(t, c, nil, 100*time.Millisecond)
+	if got := c.timeout(); got != 300*time.Millisecond {
+		t.Fatalf("expected RTO 300ms after first sample, got %v", got)
+	}
+	// RTTVAR = 3/4*50 + 1/4*|100-300| = 87.5ms, SRTT = 7/8*100 + 1/8*300 = 125ms.
+	dialOnce(t, c, nil, 300*time.Millisecond)
+	st := c.stats()
+	if st.SRTT != 125*time.Millisecond || st.RTTVar != 87500*time.Microsecond || st.RTO != 475*time.Millisecond {
+		t.Fatalf("unexpected estimates %+v", st)
+	}
+	for i := 0; i < 5; i++ {
+		dialOnce(t, c, nil, 5*time.Second)
+	}
+	if got := c.timeout(); got != 2*time.Second {
+		t.Fatalf("expected RTO clamped to 2s, got %v", got)
+	}
+
+	// Fast answers shorten a 500ms --timeout down to the 200ms floor.
+	c = newCongestionWindow(10, 500*time.Millisecond, 2*time.Second)
+	for i := 0; i < 5; i++ {
+		dialOnce(t, c, nil, 10*time.Millisecond)
+	}
+	if got := c.timeout(); got != minRTO {
+		t.Fatalf("expected RTO at the %v floor, got %v", minRTO, got)
+	}
+}
+
+func TestCongestionWindowBlocksAtWindow(t *testing.T) {
+	c := newCongestionWindow(2, time.Millisecond, time.Second)
+	for i := 0; i < 2; i++ {
+		if _, ok := c.acquire(context.Background()); !ok {
+			t.Fatal("acquire failed")
+		}
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	if _, ok := c.acquire(ctx); ok {
+		t.Fatal("acquire should block while the window is full")
+	}
+
+	got := make(chan bool)
+	go func() {
+		_, ok := c.acquire(context.Background())
+		got <- ok
+	}()
+	c.release()
+	if !<-got {
+		t.Fatal("release should wake a waiting dial")
+	}
+}
+
+func TestScanWithCongestion(t *testing.T) {
+	var (
+		mu             sync.Mutex
+		inFlight, peak int
+	)
+	serve := func(port int, conn net.Conn) bool {
+		mu.Lock()
+		inFlight++
+		peak = max(peak, inFlight)
+		mu.Unlock()
+		time.Sleep(time.Millisecond)
+		mu.Lock()
+		inFlight--
+		mu.Unlock()
+		return fakeServices(port, conn)
+	}
+
+	s := NewScanner("10.0.11.6", false)
+	s.Configure(ScanConfig{Timeout: time.Second, NumWorkers: 64, ShowClosed: true, Congestion: true, Dialer: pipeDialer{serve: serve}})
+	ports := make([]int, 0, 200)
+	for p := 1; p <= 200; p++ {
+		ports = append(ports, p)
+	}
+	results := s.Scan(context.Background(), ports, false)
+	if len(results) != 200 {
+		t.Fatalf("expected 200 results, got %d", len(results))
+	}
+	st := s.CongestionStats()
+	if st == nil || st.Samples != 200 || st.Cuts != 0 || st.Window != 64 || st.SRTT <= 0 {
+		t.Fatalf("unexpected congestion stats %+v", st)
+	}
+	mu.Lock()
+	defer mu.Unlock()
+	if peak > 64 {
+		t.Fatalf("%d dials in flight, window allows 64", peak)
+	}
+
+	s.Configure(ScanConfig{Timeout: time.Second})
+	if s.CongestionStats() != nil {
+		t.Fatal("stats should be nil with congestion off")
+	}
+}
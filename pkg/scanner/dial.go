@@ -0,0 +1,310 @@
+package scanner
+
+import (
+	"context"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"net"
+	"strings"
+	"syscall"
+	"time"
+)
+
+// SourceConfig pins the egress of scan traffic on multi-homed hosts. Zero
+// fields leave the choice to the kernel's routing table.
+type SourceConfig struct {
+	// Interface binds every socket to a network device (SO_BINDTODEVICE,
+	// Linux only).
+	Interface string
+	// IP is the local address probes are sent from.
+	IP net.IP
+	// Port is the local port of the probes that decide port states (connect,
+	// raw and UDP). Sockets sharing it set SO_REUSEADDR, and TCP connections
+	// are closed with RST so no TIME_WAIT blocks the next connection to the
+	// same port.
+	Port int
+}
+
+// IsZero reports whether no source is pinned.
+func (c SourceConfig) IsZero() bool {
+	return c.Interface == "" && c.IP == nil && c.Port == 0
+}
+
+// Validate checks that the interface exists and that the source IP is
+// assigned locally (to the interface, when both are set). Binding to a
+// foreign address would make every probe fail like a filtered port.
+func (c SourceConfig) Validate() error {
+	if c.Port < 0 || c.Port > 65535 {
+		return fmt.Errorf("invalid source port %d", c.Port)
+	}
+	var addrs []net.Addr
+	if c.Interface != "" {
+		iface, err := net.InterfaceByName(c.Interface)
+		if err != nil {
+			return fmt.Errorf("interface %q: %w", c.Interface, err)
+		}
+		if iface.Flags&net.FlagUp == 0 {
+			return fmt.Errorf("interface %s is down", c.Interface)
+		}
+		if addrs, err = iface.Addrs(); err != nil {
+			return fmt.Errorf("interface %s: %w", c.Interface, err)
+		}
+	} else if c.IP != nil {
+		var err error
+		if addrs, err = net.InterfaceAddrs(); err != nil {
+			return fmt.Errorf("list local addresses: %w", err)
+		}
+	}
+	if c.IP == nil {
+		return nil
+	}
+	for _, a := range addrs {
+		if ipNet, ok := a.(*net.IPNet); ok && ipNet.IP.Equal(c.IP) {
+			return nil
+		}
+	}
+	if c.Interface != "" {
+		return fmt.Errorf("source address %s is not assigned to %s", c.IP, c.Interface)
+	}
+	return fmt.Errorf("source address %s is not assigned to a local interface", c.IP)
+}
+
+// dialer returns a net.Dialer for network ("tcp" or "udp") bound to the source.
+func (c SourceConfig) dialer(network string, timeout time.Duration) *net.Dialer {
+	d := &net.Dialer{Timeout: timeout}
+	if c.IP != nil || c.Port != 0 {
+		if strings.HasPrefix(network, "udp") {
+			d.LocalAddr = &net.UDPAddr{IP: c.IP, Port: c.Port}
+		} else {
+			d.LocalAddr = &net.TCPAddr{IP: c.IP, Port: c.Port}
+		}
+	}
+	if c.Interface != "" || c.Port != 0 {
+		d.Control = c.control
+	}
+	return d
+}
+
+// control sets the socket options for the source before the socket is bound.
+func (c SourceConfig) control(network, _ string, rc syscall.RawConn) error {
+	var opErr error
+	err := rc.Control(func(fd uintptr) {
+		if c.Interface != "" {
+			if opErr = bindToDevice(fd, c.Interface); opErr != nil {
+				return
+			}
+		}
+		if c.Port != 0 {
+			opErr = shareSourcePort(fd, network)
+		}
+	})
+	if err != nil {
+		return err
+	}
+	return opErr
+}
+
+// sourceIP returns the address raw probes to dst are sent from: the pinned
+// IP, else the address the kernel routes dst from, through the pinned
+// interface when one is set.
+func (c SourceConfig) sourceIP(dst net.IP) (net.IP, error) {
+	v4 := dst.To4() != nil
+	if c.IP != nil {
+		if (c.IP.To4() != nil) != v4 {
+			return nil, fmt.Errorf("source address %s cannot reach %s (address family mismatch)", c.IP, dst)
+		}
+		if v4 {
+			return c.IP.To4(), nil
+		}
+		return c.IP.To16(), nil
+	}
+	return routeSourceIP(SourceConfig{Interface: c.Interface}.dialer("udp", 0), dst)
+}
+
+// bindPacketConn binds a raw socket to the source interface, if any.
+func (c SourceConfig) bindPacketConn(conn net.PacketConn) error {
+	if c.Interface == "" {
+		return nil
+	}
+	sc, ok := conn.(syscall.Conn)
+	if !ok {
+		return errors.New("socket cannot be bound to an interface")
+	}
+	rc, err := sc.SyscallConn()
+	if err != nil {
+		return err
+	}
+	var opErr error
+	if err := rc.Control(func(fd uintptr) { opErr = bindToDevice(fd, c.Interface) }); err != nil {
+		return err
+	}
+	return opErr
+}
+
+// listenAddr returns the local address raw sockets of one family bind to: the
+// pinned IP, which the kernel then sends from, or the wildcard address.
+func (c SourceConfig) listenAddr(v6 bool) (string, error) {
+	if c.IP == nil {
+		if v6 {
+			return "::", nil
+		}
+		return "0.0.0.0", nil
+	}
+	if (c.IP.To4() == nil) != v6 {
+		family := "IPv4"
+		if v6 {
+			family = "IPv6"
+		}
+		return "", fmt.Errorf("source address %s cannot send %s probes", c.IP, family)
+	}
+	return c.IP.String(), nil
+}
+
+// ifIndex returns the index of the source interface, 0 if none is pinned.
+func (c SourceConfig) ifIndex() (int, error) {
+	if c.Interface == "" {
+		return 0, nil
+	}
+	iface, err := net.InterfaceByName(c.Interface)
+	if err != nil {
+		return 0, fmt.Errorf("interface %q: %w", c.Interface, err)
+	}
+	return iface.Index, nil
+}
+
+// Dialer opens the connections a Scanner makes: port checks, banner grabs
+// and every service probe. Implementations can trace, proxy or fake them;
+// tests can return one end of a net.Pipe. Timeouts arrive as ctx deadlines.
+type Dialer interface {
+	// DialContext opens a "tcp" or "udp" connection (or a 4/6 variant).
+	DialContext(ctx context.Context, network, address string) (net.Conn, error)
+	// DialTLSContext opens a TCP connection and completes a TLS handshake on
+	// it with cfg. An empty cfg.ServerName is taken from address.
+	DialTLSContext(ctx context.Context, network, address string, cfg *tls.Config) (*tls.Conn, error)
+}
+
+// NetDialer is the default Dialer: direct connections from Source.
+type NetDialer struct {
+	Source SourceConfig
+}
+
+// DialContext implements Dialer.
+func (d NetDialer) DialContext(ctx context.Context, network, address string) (net.Conn, error) {
+	return d.Source.dialer(network, 0).DialContext(ctx, network, address)
+}
+
+// DialTLSContext implements Dialer.
+func (d NetDialer) DialTLSContext(ctx context.Context, network, address string, cfg *tls.Config) (*tls.Conn, error) {
+	td := &tls.Dialer{NetDialer: d.Source.dialer(network, 0), Config: cfg}
+	conn, err := td.DialContext(ctx, network, address)
+	if err != nil {
+		return nil, err
+	}
+	return conn.(*tls.Conn), nil
+}
+
+// tlsClient runs a client handshake over conn, as tls.Dialer would.
+func tlsClient(ctx context.Context, conn net.Conn, address string, cfg *tls.Config) (*tls.Conn, error) {
+	if cfg == nil {
+		cfg = &tls.Config{}
+	}
+	if cfg.ServerName == "" {
+		host, _, err := net.SplitHostPort(address)
+		if err != nil {
+			host = address
+		}
+		cfg = cfg.Clone()
+		cfg.ServerName = host
+	}
+	tlsConn := tls.Client(conn, cfg)
+	if err := tlsConn.HandshakeContext(ctx); err != nil {
+		_ = conn.Close()
+		return nil, err
+	}
+	return tlsConn, nil
+}
+
+// dialContext opens the connection that decides a port's state.
+func (s *Scanner) dialContext(ctx context.Context, network, address string, timeout time.Duration) (net.Conn, error) {
+	return dialWithin(ctx, s.dialer, network, address, timeout)
+}
+
+// dial opens a service detection connection. With the default dialer these
+// keep the source interface and address but not a pinned source port: they
+// run while the scan connection to the same port is still open, so its
+// 4-tuple is taken. The dial holds a slot of the shared dial budget. Waiting
+// for the rate limiter or the budget does not count against timeout. The
+// connection is closed when the running scan's context ends.
+func (s *Scanner) dial(network, address string, timeout time.Duration) (net.Conn, error) {
+	ctx := s.probeContext()
+	if err := s.limiter.Wait(ctx); err != nil {
+		return nil, err
+	}
+	if !s.budget.acquire(ctx) {
+		return nil, ctx.Err()
+	}
+	conn, err := dialWithin(ctx, s.probeDialer, network, address, timeout)
+	s.budget.release()
+	if err != nil {
+		return nil, err
+	}
+	return watchConn(ctx, conn), nil
+}
+
+// dialTLS is dial for TLS service probes; timeout covers the handshake too,
+// which also holds the budget slot, and is cut short by the deadline of the
+// running scan.
+func (s *Scanner) dialTLS(address string, timeout time.Duration, cfg *tls.Config) (*tls.Conn, error) {
+	scanCtx := s.probeContext()
+	if err := s.limiter.Wait(scanCtx); err != nil {
+		return nil, err
+	}
+	if !s.budget.acquire(scanCtx) {
+		return nil, scanCtx.Err()
+	}
+	defer s.budget.release()
+	ctx, cancel := context.WithTimeout(scanCtx, timeout)
+	defer cancel()
+	return s.probeDialer.DialTLSContext(ctx, "tcp", address, cfg)
+}
+
+// watchConn closes conn as soon as ctx is done, so a probe blocked on a
+// stalling service returns at once instead of at its own timeout.
+func watchConn(ctx context.Context, conn net.Conn) net.Conn {
+	if ctx.Done() == nil {
+		return conn
+	}
+	stop := context.AfterFunc(ctx, func() { _ = conn.Close() })
+	return &watchedConn{Conn: conn, stop: stop}
+}
+
+type watchedConn struct {
+	net.Conn
+	stop func() bool
+}
+
+func (c *watchedConn) Close() error {
+	c.stop()
+	return c.Conn.Close()
+}
+
+// setDialer installs d, or the default NetDialer from src when d is nil.
+func (s *Scanner) setDialer(d Dialer, src SourceConfig) {
+	if d != nil {
+		s.dialer, s.probeDialer = d, d
+		return
+	}
+	s.dialer = NetDialer{Source: src}
+	s.probeDialer = NetDialer{Source: SourceConfig{Interface: src.Interface, IP: src.IP}}
+}
+
+// dialWithin dials through d, giving up after timeout.
+func dialWithin(ctx context.Context, d Dialer, network, address string, timeout time.Duration) (net.Conn, error) {
+	if timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, timeout)
+		defer cancel()
+	}
+	return d.DialContext(ctx, network, address)
+}
@@ -0,0 +1,23 @@
+package scanner
+
+import (
+	"strings"
+	"syscall"
+)
+
+func bindToDevice(fd uintptr, iface string) error {
+	return syscall.SetsockoptString(int(fd), syscall.SOL_SOCKET, syscall.SO_BINDTODEVICE, iface)
+}
+
+// shareSourcePort lets several sockets bind the same local port. TCP sockets
+// also get a zero linger time, so closing sends RST instead of entering
+// TIME_WAIT, which would block reconnecting to the same target port.
+func shareSourcePort(fd uintptr, network string) error {
+	if err := syscall.SetsockoptInt(int(fd), syscall.SOL_SOCKET, syscall.SO_REUSEADDR, 1); err != nil {
+		return err
+	}
+	if strings.HasPrefix(network, "tcp") {
+		return syscall.SetsockoptLinger(int(fd), syscall.SOL_SOCKET, syscall.SO_LINGER, &syscall.Linger{Onoff: 1, Linger: 0})
+	}
+	return nil
+}
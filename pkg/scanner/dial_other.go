@@ -0,0 +1,15 @@
+//go:build !linux
+
+package scanner
+
+import "errors"
+
+func bindToDevice(uintptr, string) error {
+	return errors.New("binding to an interface is only supported on Linux")
+}
+
+// shareSourcePort is a no-op outside Linux: concurrent probes from one
+// source port may fail to bind there.
+func shareSourcePort(uintptr, string) error {
+	return nil
+}
@@ -0,0 +1,269 @@
+package scanner
+
+import (
+	"bufio"
+	"context"
+	"crypto/tls"
+	"errors"
+	"io"
+	"net"
+	"net/http"
+	"os"
+	"runtime"
+	"strconv"
+	"strings"
+	"sync"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/NexusFireMan/gomap/v2/internal/testutil"
+)
+
+func TestSourceConfigDialerBindsSourcePort(t *testing.T) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("listen: %v", err)
+	}
+	defer func() { _ = listener.Close() }()
+
+	free, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("listen: %v", err)
+	}
+	srcPort := free.Addr().(*net.TCPAddr).Port
+	_ = free.Close()
+
+	src := SourceConfig{IP: net.ParseIP("127.0.0.1"), Port: srcPort}
+	// Two connections in a row from the same port: the RST on close must
+	// leave no TIME_WAIT behind that blocks the second.
+	for i := 0; i < 2; i++ {
+		conn, err := src.dialer("tcp", time.Second).Dial("tcp", listener.Addr().String())
+		if err != nil {
+			t.Fatalf("dial %d: %v", i, err)
+		}
+		accepted, err := listener.Accept()
+		if err != nil {
+			t.Fatalf("accept: %v", err)
+		}
+		if got := accepted.RemoteAddr().(*net.TCPAddr).Port; got != srcPort {
+			t.Fatalf("expected source port %d, got %d", srcPort, got)
+		}
+		_ = conn.Close()
+		_ = accepted.Close()
+	}
+}
+
+func TestSourceConfigValidate(t *testing.T) {
+	if err := (SourceConfig{}).Validate(); err != nil {
+		t.Fatalf("empty source should be valid: %v", err)
+	}
+	if err := (SourceConfig{IP: net.ParseIP("127.0.0.1")}).Validate(); err != nil {
+		t.Fatalf("loopback source should be valid: %v", err)
+	}
+	if err := (SourceConfig{IP: net.ParseIP("192.0.2.254")}).Validate(); err == nil || !strings.Contains(err.Error(), "not assigned") {
+		t.Fatalf("expected foreign address to be rejected, got %v", err)
+	}
+	if err := (SourceConfig{Interface: "gomap-missing0"}).Validate(); err == nil {
+		t.Fatal("expected unknown interface to be rejected")
+	}
+	if err := (SourceConfig{Port: 70000}).Validate(); err == nil {
+		t.Fatal("expected out of range port to be rejected")
+	}
+}
+
+func TestSourceConfigListenAddr(t *testing.T) {
+	for _, tc := range []struct {
+		src  SourceConfig
+		v6   bool
+		want string
+	}{
+		{SourceConfig{}, false, "0.0.0.0"},
+		{SourceConfig{Interface: "lo"}, true, "::"},
+		{SourceConfig{IP: net.ParseIP("127.0.0.1")}, false, "127.0.0.1"},
+		{SourceConfig{IP: net.ParseIP("::1")}, true, "::1"},
+	} {
+		if got, err := tc.src.listenAddr(tc.v6); err != nil || got != tc.want {
+			t.Fatalf("%+v (v6 %v): got %q, %v; want %q", tc.src, tc.v6, got, err, tc.want)
+		}
+	}
+	if _, err := (SourceConfig{IP: net.ParseIP("127.0.0.1")}).listenAddr(true); err == nil {
+		t.Fatal("an IPv4 source should not open an IPv6 socket")
+	}
+}
+
+func TestSourceConfigSourceIP(t *testing.T) {
+	src := SourceConfig{IP: net.ParseIP("127.0.0.1")}
+	ip, err := src.sourceIP(net.ParseIP("127.0.0.2"))
+	if err != nil || !ip.Equal(net.ParseIP("127.0.0.1")) || len(ip) != net.IPv4len {
+		t.Fatalf("unexpected source ip %v (%v)", ip, err)
+	}
+	if _, err := src.sourceIP(net.ParseIP("::1")); err == nil {
+		t.Fatal("expected address family mismatch to be rejected")
+	}
+}
+
+func TestScannerDialsThroughInterface(t *testing.T) {
+	if runtime.GOOS != "linux" {
+		t.Skip("interface binding is Linux only")
+	}
+	addr := net.JoinHostPort("127.0.0.1", strconv.Itoa(testutil.StartService(t, nil)))
+
+	s := NewScanner("127.0.0.1", false)
+	s.Configure(ScanConfig{Source: SourceConfig{Interface: "lo"}})
+	conn, err := s.dial("tcp", addr, time.Second)
+	if err != nil {
+		t.Skipf("cannot bind to lo: %v", err)
+	}
+	_ = conn.Close()
+
+	// Loopback addresses are unreachable through any other interface.
+	var other string
+	ifaces, _ := net.Interfaces()
+	for _, iface := range ifaces {
+		if iface.Flags&net.FlagLoopback == 0 && iface.Flags&net.FlagUp != 0 {
+			other = iface.Name
+			break
+		}
+	}
+	if other == "" {
+		return
+	}
+	s.Configure(ScanConfig{Source: SourceConfig{Interface: other}})
+	if conn, err := s.dial("tcp", addr, 300*time.Millisecond); err == nil {
+		_ = conn.Close()
+		t.Fatalf("expected dial through %s to miss the loopback listener", other)
+	}
+}
+
+// pipeDialer is a Dialer that serves every connection in memory: serve gets
+// the far end of a net.Pipe, or refuses the port by returning false.
+type pipeDialer struct {
+	serve func(port int, conn net.Conn) bool
+}
+
+func (d pipeDialer) DialContext(_ context.Context, network, address string) (net.Conn, error) {
+	_, portStr, err := net.SplitHostPort(address)
+	if err != nil {
+		return nil, err
+	}
+	port, _ := strconv.Atoi(portStr)
+	client, server := net.Pipe()
+	ok := make(chan bool, 1)
+	go func() {
+		defer func() { _ = server.Close() }()
+		d.serve(port, &acceptedPipe{Conn: server, accepted: ok})
+	}()
+	if !<-ok {
+		_ = client.Close()
+		return nil, &net.OpError{Op: "dial", Net: network, Err: os.NewSyscallError("connect", syscall.ECONNREFUSED)}
+	}
+	return client, nil
+}
+
+func (d pipeDialer) DialTLSContext(context.Context, string, string, *tls.Config) (*tls.Conn, error) {
+	return nil, errors.New("no TLS in memory")
+}
+
+// acceptedPipe reports whether the fake service accepted the connection:
+// refusing means returning before any I/O.
+type acceptedPipe struct {
+	net.Conn
+	accepted chan bool
+	once     sync.Once
+}
+
+func (c *acceptedPipe) accept() { c.once.Do(func() { c.accepted <- true }) }
+
+func (c *acceptedPipe) Read(b []byte) (int, error)  { c.accept(); return c.Conn.Read(b) }
+func (c *acceptedPipe) Write(b []byte) (int, error) { c.accept(); return c.Conn.Write(b) }
+func (c *acceptedPipe) Close() error {
+	c.once.Do(func() { c.accepted <- false })
+	return c.Conn.Close()
+}
+
+func fakeServices(port int, conn net.Conn) bool {
+	defer func() { _ = conn.Close() }()
+	switch port {
+	case 22:
+		_, _ = io.WriteString(conn, "SSH-2.0-OpenSSH_9.6p1 Ubuntu-3ubuntu13\r\n")
+		_, _ = io.Copy(io.Discard, conn)
+	case 80:
+		req, err := http.ReadRequest(bufio.NewReader(conn))
+		if err != nil {
+			return true
+		}
+		_ = req.Body.Close()
+		_, _ = io.WriteString(conn, "HTTP/1.1 200 OK\r\nServer: nginx/1.24.0\r\nContent-Length: 0\r\nConnection: close\r\n\r\n")
+	default:
+		return false
+	}
+	return true
+}
+
+func TestGrabBannerThroughDialer(t *testing.T) {
+	s := NewScanner("10.0.11.6", false)
+	s.Configure(ScanConfig{Timeout: time.Second, Dialer: pipeDialer{serve: fakeServices}})
+
+	for _, tc := range []struct {
+		port    int
+		service string
+		version string
+	}{
+		{22, "ssh", "OpenSSH 9.6p1"},
+		{80, "http", "1.24.0"},
+	} {
+		conn, err := s.dial("tcp", net.JoinHostPort(s.Host, strconv.Itoa(tc.port)), time.Second)
+		if err != nil {
+			t.Fatalf("dial %d: %v", tc.port, err)
+		}
+		var result ScanResult
+		s.grabBanner(conn, tc.port, &result)
+		_ = conn.Close()
+		if result.ServiceName != tc.service || !strings.Contains(result.Version, tc.version) {
+			t.Fatalf("port %d: unexpected detection %+v", tc.port, result)
+		}
+	}
+}
+
+func TestScanThroughDialer(t *testing.T) {
+	s := NewScanner("10.0.11.6", false)
+	s.Configure(ScanConfig{Timeout: time.Second, NumWorkers: 4, ShowClosed: true, Dialer: pipeDialer{serve: fakeServices}})
+	results := s.Scan(context.Background(), []int{22, 23, 80}, false)
+	states := map[int]PortState{}
+	for _, r := range results {
+		states[r.Port] = r.State
+	}
+	if states[22] != PortOpen || states[80] != PortOpen || states[23] != PortClosed {
+		t.Fatalf("unexpected states: %v", states)
+	}
+}
+
+func TestScanDeadlineStopsServiceProbes(t *testing.T) {
+	// Ports 80 and 445 accept and then never answer, like a tarpit.
+	stall := func(port int, conn net.Conn) bool {
+		if port != 80 && port != 445 {
+			return fakeServices(port, conn)
+		}
+		defer func() { _ = conn.Close() }()
+		_, _ = io.Copy(io.Discard, conn)
+		return true
+	}
+	s := NewScanner("10.0.11.6", false)
+	s.Configure(ScanConfig{Timeout: 10 * time.Second, NumWorkers: 4, Dialer: pipeDialer{serve: stall}})
+
+	ctx, cancel := context.WithTimeout(context.Background(), 300*time.Millisecond)
+	defer cancel()
+	start := time.Now()
+	results := s.Scan(ctx, []int{22, 80, 445}, true)
+	if elapsed := time.Since(start); elapsed > 2*time.Second {
+		t.Fatalf("service probes outlived the deadline: %v", elapsed)
+	}
+	open := map[int]bool{}
+	for _, r := range results {
+		open[r.Port] = r.State == PortOpen
+	}
+	if !open[22] || !open[80] || !open[445] {
+		t.Fatalf("open ports found before the deadline should be kept: %+v", results)
+	}
+}
@@ -0,0 +1,391 @@
+package scanner
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"runtime"
+	"slices"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// DiscoveryOptions controls CIDR host discovery behavior.
+type DiscoveryOptions struct {
+	Ports      []int
+	Timeout    time.Duration
+	NumWorkers int
+	// Methods lists the probes tried per host, in order, until one answers.
+	// Empty means TCP only.
+	Methods []string
+	// OnUnavailable is called for each selected method that cannot run, for
+	// example ICMP without privileges. Discovery continues with the others.
+	OnUnavailable func(method string, err error)
+	// Source pins the interface and address of every probe, and the port of
+	// raw tcp-syn and tcp-ack probes. ARP asks only on subnets of the source.
+	Source SourceConfig
+	// Proxy, when set, carries the TCP connect probes. The other methods are
+	// unavailable with it, since their packets would bypass the proxy.
+	Proxy *ProxyDialer
+	// Limiter, when set, caps the probe rate across discovery and the scan
+	// engines sharing it. Each probe packet or connect takes a token.
+	Limiter *RateLimiter
+}
+
+// Host discovery methods accepted in DiscoveryOptions.Methods.
+const (
+	DiscoveryTCP           = "tcp"
+	DiscoveryICMPEcho      = "icmp"
+	DiscoveryICMPTimestamp = "icmp-ts"
+	DiscoveryARP           = "arp"
+	DiscoveryTCPSYN        = "tcp-syn"
+	DiscoveryTCPACK        = "tcp-ack"
+)
+
+// Discovery reasons recorded in DiscoveryResult.
+const (
+	// ReasonTCPConnect means a probe port completed the TCP handshake.
+	ReasonTCPConnect = "tcp-connect"
+	// ReasonTCPRefused means a probe port answered with RST: the port is closed but the host is up.
+	ReasonTCPRefused = "tcp-refused"
+	// ReasonEchoReply means the host answered an ICMP echo request.
+	ReasonEchoReply = "echo-reply"
+	// ReasonTimestampReply means the host answered an ICMP timestamp request.
+	ReasonTimestampReply = "timestamp-reply"
+	// ReasonARPResponse means the host answered an ARP request on a local subnet.
+	ReasonARPResponse = "arp-response"
+	// ReasonSYNACK means a raw SYN probe was answered with SYN-ACK: the port is open.
+	ReasonSYNACK = "syn-ack"
+	// ReasonTCPReset means a raw SYN or ACK probe was answered with RST.
+	ReasonTCPReset = "tcp-reset"
+)
+
+const discoveryMethodList = "tcp, tcp-syn, tcp-ack, icmp, icmp-ts, arp"
+
+// ParseDiscoveryMethods parses a comma-separated method list such as "icmp,tcp".
+// Duplicates are dropped and the given order is kept.
+func ParseDiscoveryMethods(spec string) ([]string, error) {
+	var methods []string
+	seen := make(map[string]struct{})
+	for _, m := range strings.Split(spec, ",") {
+		m = strings.ToLower(strings.TrimSpace(m))
+		if m == "" {
+			continue
+		}
+		switch m {
+		case DiscoveryTCP, DiscoveryTCPSYN, DiscoveryTCPACK, DiscoveryICMPEcho, DiscoveryICMPTimestamp, DiscoveryARP:
+		default:
+			return nil, fmt.Errorf("unknown discovery method %q (use %s)", m, discoveryMethodList)
+		}
+		if _, dup := seen[m]; dup {
+			continue
+		}
+		seen[m] = struct{}{}
+		methods = append(methods, m)
+	}
+	if len(methods) == 0 {
+		return nil, fmt.Errorf("no discovery methods given (use %s)", discoveryMethodList)
+	}
+	return methods, nil
+}
+
+// DefaultDiscoveryMethods picks discovery methods when none were requested:
+// TCP connect probes, preceded by ARP when a target is on a directly attached
+// Ethernet subnet reachable from src and the process has raw socket
+// privileges. Raw tcp-syn and tcp-ack probes run only when asked for.
+func DefaultDiscoveryMethods(ts *TargetSet, src SourceConfig) []string {
+	if rawSocketsPermitted() && OnLocalSubnet(ts, src) {
+		return []string{DiscoveryARP, DiscoveryTCP}
+	}
+	return []string{DiscoveryTCP}
+}
+
+// rawSocketsPermitted reports whether this process may open raw sockets,
+// which ARP discovery needs.
+func rawSocketsPermitted() bool {
+	if runtime.GOOS != "linux" {
+		return false
+	}
+	conn, err := net.ListenPacket("ip4:tcp", "127.0.0.1")
+	if err != nil {
+		return false
+	}
+	_ = conn.Close()
+	return true
+}
+
+// DiscoveryResult records why a host was considered alive.
+type DiscoveryResult struct {
+	Host   string
+	Reason string
+	Port   int
+	RTT    time.Duration
+	// MAC and Vendor are set when ARP found the host.
+	MAC    string
+	Vendor string
+}
+
+// DiscoveredHosts returns the host addresses of results, in order.
+func DiscoveredHosts(results []DiscoveryResult) []string {
+	hosts := make([]string, 0, len(results))
+	for _, r := range results {
+		hosts = append(hosts, r.Host)
+	}
+	return hosts
+}
+
+// DiscoverActiveHosts performs a quick host discovery on a CIDR range
+// It attempts to connect to common ports (443, 80, 22, 445, 3306) to determine if hosts are active
+func DiscoverActiveHosts(hosts []string, timeout time.Duration, numWorkers int) []string {
+	return DiscoverActiveHostsWithOptions(hosts, DiscoveryOptions{
+		Ports:      []int{443, 80, 22, 445, 3306, 8080, 3389},
+		Timeout:    timeout,
+		NumWorkers: numWorkers,
+	})
+}
+
+// DiscoverActiveHostsWithOptions performs host discovery using configurable probe ports and concurrency.
+func DiscoverActiveHostsWithOptions(hosts []string, opts DiscoveryOptions) []string {
+	if len(hosts) <= 1 {
+		// Skip discovery for single IPs or empty lists
+		return hosts
+	}
+	found, _ := DiscoverActiveTargets(context.Background(), SliceIterator(hosts), opts)
+	return DiscoveredHosts(found)
+}
+
+// DiscoverActiveTargets probes hosts as they come out of it with a fixed pool of
+// workers, so memory stays flat however large the range is. Active hosts are
+// returned in iteration order; cancelling ctx returns the hosts found so far.
+// An error is returned only when none of the selected methods can run.
+func DiscoverActiveTargets(ctx context.Context, it TargetIterator, opts DiscoveryOptions) ([]DiscoveryResult, error) {
+	commonPorts := opts.Ports
+	if len(commonPorts) == 0 {
+		commonPorts = []int{443, 80, 22}
+	}
+	timeout := opts.Timeout
+	if timeout <= 0 {
+		timeout = 500 * time.Millisecond
+	}
+	numWorkers := opts.NumWorkers
+	if numWorkers <= 0 {
+		numWorkers = 25
+	}
+	// Discovery probes only need the interface and address; a pinned source
+	// port would clash with the scan's own connections.
+	var dialer Dialer = NetDialer{Source: SourceConfig{Interface: opts.Source.Interface, IP: opts.Source.IP}}
+	if opts.Proxy != nil {
+		dialer = opts.Proxy
+	}
+	methods, probers, err := usableDiscoveryMethods(opts)
+	if err != nil {
+		return nil, err
+	}
+	defer probers.Close()
+
+	type probe struct {
+		seq    uint64
+		host   string
+		result DiscoveryResult
+	}
+	jobs := make(chan probe)
+	activeChan := make(chan probe, numWorkers)
+	var wg sync.WaitGroup
+	for i := 0; i < numWorkers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for job := range jobs {
+				if result, ok := probeHost(ctx, job.host, methods, probers, commonPorts, timeout, dialer); ok {
+					job.result = result
+					activeChan <- job
+				}
+			}
+		}()
+	}
+
+	go func() {
+		defer close(jobs)
+		for seq := uint64(0); ; seq++ {
+			host, ok := it.Next()
+			if !ok {
+				return
+			}
+			select {
+			case jobs <- probe{seq: seq, host: host}:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+
+	go func() {
+		wg.Wait()
+		close(activeChan)
+	}()
+
+	var found []probe
+	for job := range activeChan {
+		found = append(found, job)
+	}
+	sort.Slice(found, func(i, j int) bool { return found[i].seq < found[j].seq })
+	active := make([]DiscoveryResult, 0, len(found))
+	for _, job := range found {
+		active = append(active, job.result)
+	}
+	return active, nil
+}
+
+// discoveryProbers holds the sockets and rate limiter shared by all
+// discovery workers.
+type discoveryProbers struct {
+	icmp    *icmpPinger
+	arp     *arpProber
+	tcp     *tcpPinger
+	limiter *RateLimiter
+}
+
+// wait takes n tokens from the rate limiter, returning false if ctx is
+// cancelled first.
+func (p *discoveryProbers) wait(ctx context.Context, n int) bool {
+	for i := 0; i < n; i++ {
+		if p.limiter.Wait(ctx) != nil {
+			return false
+		}
+	}
+	return true
+}
+
+func (p *discoveryProbers) Close() {
+	if p.tcp != nil {
+		p.tcp.Close()
+	}
+	if p.icmp != nil {
+		p.icmp.Close()
+	}
+	if p.arp != nil {
+		p.arp.Close()
+	}
+}
+
+// usableDiscoveryMethods opens the sockets the selected methods need and drops
+// methods that cannot run, reporting each through opts.OnUnavailable. Raw TCP
+// methods that cannot run are replaced by connect probes, like the SYN scan.
+func usableDiscoveryMethods(opts DiscoveryOptions) ([]string, *discoveryProbers, error) {
+	methods := opts.Methods
+	if len(methods) == 0 {
+		methods = []string{DiscoveryTCP}
+	}
+	probers := &discoveryProbers{limiter: opts.Limiter}
+	usable := make([]string, 0, len(methods))
+	var lastErr error
+	for _, m := range methods {
+		var err error
+		switch {
+		case opts.Proxy != nil && m != DiscoveryTCP:
+			err = errors.New("its probes cannot go through a proxy")
+		case m == DiscoveryICMPEcho, m == DiscoveryICMPTimestamp:
+			if probers.icmp == nil {
+				probers.icmp = openICMPPinger(opts.Source)
+			}
+			err = probers.icmp.available(m)
+		case m == DiscoveryARP:
+			probers.arp, err = openARPProber(opts.Source)
+		case m == DiscoveryTCPSYN, m == DiscoveryTCPACK:
+			if probers.tcp == nil {
+				probers.tcp, err = openTCPPinger(opts.Source)
+			}
+		}
+		if err != nil {
+			lastErr = err
+			if opts.OnUnavailable != nil {
+				opts.OnUnavailable(m, err)
+			}
+			if (m == DiscoveryTCPSYN || m == DiscoveryTCPACK) && !slices.Contains(methods, DiscoveryTCP) && !slices.Contains(usable, DiscoveryTCP) {
+				usable = append(usable, DiscoveryTCP)
+			}
+			continue
+		}
+		usable = append(usable, m)
+	}
+	if len(usable) == 0 {
+		probers.Close()
+		return nil, nil, fmt.Errorf("no usable host discovery method: %w", lastErr)
+	}
+	return usable, probers, nil
+}
+
+// probeHost runs the discovery methods in order until one proves the host is up.
+func probeHost(ctx context.Context, host string, methods []string, probers *discoveryProbers, ports []int, timeout time.Duration, dialer Dialer) (DiscoveryResult, bool) {
+	for _, m := range methods {
+		if ctx.Err() != nil {
+			break
+		}
+		switch m {
+		case DiscoveryTCP:
+			if result, ok := probeTCP(ctx, host, ports, timeout, dialer, probers.limiter); ok {
+				return result, true
+			}
+		case DiscoveryICMPEcho:
+			if !probers.wait(ctx, 1) {
+				break
+			}
+			if rtt, _, ok := probers.icmp.probe(ctx, host, icmpEcho, timeout); ok {
+				return DiscoveryResult{Host: host, Reason: ReasonEchoReply, RTT: rtt}, true
+			}
+		case DiscoveryICMPTimestamp:
+			if !probers.wait(ctx, 1) {
+				break
+			}
+			if rtt, _, ok := probers.icmp.probe(ctx, host, icmpTimestamp, timeout); ok {
+				return DiscoveryResult{Host: host, Reason: ReasonTimestampReply, RTT: rtt}, true
+			}
+		case DiscoveryARP:
+			if !probers.wait(ctx, 1) {
+				break
+			}
+			if result, ok := probers.arp.probe(ctx, host, timeout); ok {
+				return result, true
+			}
+		case DiscoveryTCPSYN, DiscoveryTCPACK:
+			// The pinger sends to every port at once.
+			if !probers.wait(ctx, len(ports)) {
+				break
+			}
+			flags := byte(tcpFlagSyn)
+			if m == DiscoveryTCPACK {
+				flags = tcpFlagAck
+			}
+			if result, ok := probers.tcp.probe(ctx, host, ports, flags, timeout); ok {
+				return result, true
+			}
+		}
+	}
+	return DiscoveryResult{}, false
+}
+
+// probeTCP dials the probe ports in order until one proves the host is up.
+// A refused connection counts: only a live host sends the RST. Timeouts and
+// unreachable errors move on to the next port.
+func probeTCP(ctx context.Context, host string, ports []int, timeout time.Duration, dialer Dialer, limiter *RateLimiter) (DiscoveryResult, bool) {
+	for _, port := range ports {
+		if limiter.Wait(ctx) != nil {
+			return DiscoveryResult{}, false
+		}
+		address := net.JoinHostPort(host, fmt.Sprintf("%d", port))
+		start := time.Now()
+		conn, err := dialWithin(ctx, dialer, "tcp", address, timeout)
+		rtt := time.Since(start)
+		if err == nil {
+			_ = conn.Close()
+			return DiscoveryResult{Host: host, Reason: ReasonTCPConnect, Port: port, RTT: rtt}, true
+		}
+		if connectErrorState(err) == PortClosed {
+			return DiscoveryResult{Host: host, Reason: ReasonTCPRefused, Port: port, RTT: rtt}, true
+		}
+	}
+	return DiscoveryResult{}, false
+}
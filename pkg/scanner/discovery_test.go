@@ -0,0 +1,172 @@
+package scanner
+
+import (
+	"context"
+	"net"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/NexusFireMan/gomap/v2/internal/testutil"
+)
+
+func TestDiscoverActiveTargetsReportsReasons(t *testing.T) {
+	port := testutil.StartService(t, nil)
+
+	// 127.0.0.2 has nothing listening, so the kernel answers with RST: alive but refused.
+	hosts := []string{"127.0.0.2", "127.0.0.1"}
+	active, err := DiscoverActiveTargets(context.Background(), SliceIterator(hosts), DiscoveryOptions{
+		Ports:      []int{port},
+		Timeout:    300 * time.Millisecond,
+		NumWorkers: 2,
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(active) != 2 {
+		t.Fatalf("expected both hosts alive, got %+v", active)
+	}
+	if active[0].Host != "127.0.0.2" || active[0].Reason != ReasonTCPRefused || active[0].Port != port {
+		t.Fatalf("unexpected refused result: %+v", active[0])
+	}
+	if active[1].Host != "127.0.0.1" || active[1].Reason != ReasonTCPConnect || active[1].RTT <= 0 {
+		t.Fatalf("unexpected connect result: %+v", active[1])
+	}
+}
+
+func TestDiscoverActiveTargetsStopsOnCancel(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	active, err := DiscoverActiveTargets(ctx, SliceIterator([]string{"127.0.0.1", "127.0.0.2"}), DiscoveryOptions{
+		Ports:   []int{1},
+		Timeout: 100 * time.Millisecond,
+	})
+	if err != nil || len(active) != 0 {
+		t.Fatalf("expected no hosts after cancellation, got %+v", active)
+	}
+}
+
+func TestParseDiscoveryMethods(t *testing.T) {
+	methods, err := ParseDiscoveryMethods("ICMP, tcp,icmp,icmp-ts,TCP-SYN,tcp-ack")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if strings.Join(methods, ",") != "icmp,tcp,icmp-ts,tcp-syn,tcp-ack" {
+		t.Fatalf("unexpected methods: %v", methods)
+	}
+	for _, spec := range []string{"", " , ", "ndp", "tcp,udp"} {
+		if _, err := ParseDiscoveryMethods(spec); err == nil {
+			t.Fatalf("expected error for %q", spec)
+		}
+	}
+}
+
+func TestDiscoverActiveTargetsICMP(t *testing.T) {
+	pinned := SourceConfig{Interface: "lo", IP: net.ParseIP("127.0.0.1")}
+	for _, tc := range []struct {
+		method string
+		source SourceConfig
+	}{
+		{DiscoveryICMPEcho, SourceConfig{}},
+		{DiscoveryICMPTimestamp, SourceConfig{}},
+		{DiscoveryICMPEcho, pinned},
+		{DiscoveryICMPTimestamp, pinned},
+	} {
+		method := tc.method
+		var unavailable error
+		active, err := DiscoverActiveTargets(context.Background(), SliceIterator([]string{"127.0.0.1"}), DiscoveryOptions{
+			Methods:       []string{method},
+			Timeout:       time.Second,
+			Source:        tc.source,
+			OnUnavailable: func(_ string, err error) { unavailable = err },
+		})
+		if err != nil {
+			if unavailable == nil {
+				t.Fatalf("%s: error without OnUnavailable callback: %v", method, err)
+			}
+			t.Logf("%s discovery unavailable here: %v", method, err)
+			continue
+		}
+		want := ReasonEchoReply
+		if method == DiscoveryICMPTimestamp {
+			want = ReasonTimestampReply
+		}
+		if len(active) != 1 || active[0].Reason != want || active[0].Port != 0 {
+			t.Fatalf("%s: unexpected result %+v", method, active)
+		}
+	}
+}
+
+func TestDiscoverActiveTargetsFallsBackToTCP(t *testing.T) {
+	// Whatever ICMP can do here, TCP stays usable and still finds the refusing host.
+	active, err := DiscoverActiveTargets(context.Background(), SliceIterator([]string{"127.0.0.2"}), DiscoveryOptions{
+		Methods: []string{DiscoveryICMPTimestamp, DiscoveryTCP},
+		Ports:   []int{1},
+		Timeout: 300 * time.Millisecond,
+	})
+	if err != nil || len(active) != 1 {
+		t.Fatalf("expected one live host, got %+v (%v)", active, err)
+	}
+}
+
+func TestDiscoverActiveTargetsRawTCP(t *testing.T) {
+	open := testutil.StartService(t, nil)
+
+	cases := []struct {
+		method string
+		host   string
+		reason string
+		port   int
+		source SourceConfig
+	}{
+		{DiscoveryTCPSYN, "127.0.0.1", ReasonSYNACK, open, SourceConfig{}},
+		{DiscoveryTCPSYN, "127.0.0.2", ReasonTCPReset, 1, SourceConfig{}},
+		{DiscoveryTCPACK, "127.0.0.1", ReasonTCPReset, open, SourceConfig{}},
+		{DiscoveryTCPSYN, "127.0.0.1", ReasonSYNACK, open, SourceConfig{Interface: "lo", IP: net.ParseIP("127.0.0.1"), Port: 45123}},
+	}
+	for _, tc := range cases {
+		var unavailable error
+		active, err := DiscoverActiveTargets(context.Background(), SliceIterator([]string{tc.host}), DiscoveryOptions{
+			Methods:       []string{tc.method},
+			Ports:         []int{tc.port},
+			Timeout:       time.Second,
+			Source:        tc.source,
+			OnUnavailable: func(_ string, err error) { unavailable = err },
+		})
+		if err != nil {
+			t.Fatalf("%s: connect fallback should keep discovery usable: %v", tc.method, err)
+		}
+		if unavailable != nil {
+			// Unprivileged: the connect probes stand in and still find the host.
+			t.Logf("%s discovery unavailable here: %v", tc.method, unavailable)
+			if len(active) != 1 {
+				t.Fatalf("%s: expected connect fallback to find %s, got %+v", tc.method, tc.host, active)
+			}
+			continue
+		}
+		if len(active) != 1 || active[0].Reason != tc.reason || active[0].Port != tc.port {
+			t.Fatalf("%s %s: unexpected result %+v", tc.method, tc.host, active)
+		}
+	}
+}
+
+func TestTCPPingWaitAnswers(t *testing.T) {
+	syn := &tcpPingWait{seq: 1000, flags: tcpFlagSyn}
+	if syn.ack() != 0 {
+		t.Fatal("SYN probes must not carry an acknowledgement number")
+	}
+	if !syn.answers(tcpResponse{ack: 1001, flags: tcpFlagSyn | tcpFlagAck}) || !syn.answers(tcpResponse{ack: 1001, flags: tcpFlagRst | tcpFlagAck}) {
+		t.Fatal("SYN-ACK and RST acknowledging seq+1 should answer a SYN probe")
+	}
+	if syn.answers(tcpResponse{ack: 77, flags: tcpFlagSyn | tcpFlagAck}) {
+		t.Fatal("a SYN-ACK for another sequence number should be ignored")
+	}
+
+	ack := &tcpPingWait{seq: 1000, flags: tcpFlagAck}
+	if ack.ack() != 1000 || !ack.answers(tcpResponse{seq: 1000, flags: tcpFlagRst}) {
+		t.Fatal("a RST to an ACK probe takes the probe's ack as its sequence number")
+	}
+	if ack.answers(tcpResponse{seq: 1001, ack: 1001, flags: tcpFlagRst}) {
+		t.Fatal("a RST with another sequence number should be ignored")
+	}
+}
@@ -0,0 +1,362 @@
+package scanner
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os/exec"
+	"regexp"
+	"runtime"
+	"strings"
+	"time"
+)
+
+// RSTDropRules lists local iptables, ip6tables and nftables rules on the
+// output path that drop or reject outgoing TCP segments with RST set, including
+// rules in chains jumped to from OUTPUT and a DROP policy no rule accepts
+// resets ahead of. Such rules stop both the kernel's own resets and the ones
+// the SYN engine sends, so probed ports can be left half-open on the target.
+// Tools that are not installed are skipped; an error is returned only when no
+// ruleset could be read at all.
+func RSTDropRules(ctx context.Context) ([]string, error) {
+	if runtime.GOOS != "linux" {
+		return nil, errors.New("firewall check is supported on linux only")
+	}
+	var (
+		rules   []string
+		lastErr error
+		read    bool
+		// shadowed holds the nftables tables that iptables-nft already
+		// listed, so their rules are not reported twice.
+		shadowed = map[string]bool{}
+	)
+	for _, tool := range []struct{ name, table string }{{"iptables", "ip filter"}, {"ip6tables", "ip6 filter"}} {
+		out, err := runFirewallTool(ctx, tool.name, "-S")
+		if errors.Is(err, exec.ErrNotFound) {
+			continue
+		}
+		if err != nil {
+			lastErr = err
+			continue
+		}
+		read = true
+		for _, rule := range rstDropRules(out) {
+			rules = append(rules, tool.name+" "+rule)
+		}
+		if version, err := runFirewallTool(ctx, tool.name, "-V"); err == nil && strings.Contains(version, "nf_tables") {
+			shadowed[tool.table] = true
+		}
+	}
+	out, err := runFirewallTool(ctx, "nft", "list", "ruleset")
+	switch {
+	case err == nil:
+		read = true
+		for _, rule := range nftRSTDropRules(out, shadowed) {
+			rules = append(rules, "nft "+rule)
+		}
+	case !errors.Is(err, exec.ErrNotFound):
+		lastErr = err
+	}
+	if !read {
+		if lastErr == nil {
+			lastErr = errors.New("none of iptables, ip6tables and nft is installed")
+		}
+		return nil, lastErr
+	}
+	return rules, nil
+}
+
+// runFirewallTool runs a firewall listing command, returning an error
+// wrapping exec.ErrNotFound when the tool is not installed.
+func runFirewallTool(ctx context.Context, tool string, args ...string) (string, error) {
+	path, err := exec.LookPath(tool)
+	if err != nil {
+		return "", err
+	}
+	runCtx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+	out, err := exec.CommandContext(runCtx, path, args...).Output()
+	if err != nil {
+		return "", fmt.Errorf("%s %s: %w", tool, strings.Join(args, " "), err)
+	}
+	return string(out), nil
+}
+
+// fwRule is one firewall rule reduced to what decides the fate of a reset.
+type fwRule struct {
+	text string
+	// resets means the rule's TCP flag match selects resets; others means it
+	// has a flag match that never selects them, so the rule can be ignored.
+	resets, others bool
+	// target is DROP, REJECT, ACCEPT or RETURN, the chain a jump or goto
+	// leads to, or empty for rules without a verdict.
+	target string
+}
+
+// fwRuleset holds the chains of one firewall table, rules in order.
+type fwRuleset struct {
+	chains map[string][]fwRule
+	// policies maps base chains to their policy and the text to report it by.
+	policies map[string][2]string
+}
+
+func newFWRuleset() fwRuleset {
+	return fwRuleset{chains: map[string][]fwRule{}, policies: map[string][2]string{}}
+}
+
+// rstDrops walks entry and every chain it jumps to, collecting the rules that
+// drop or reject resets. A rule counts when its own flag match selects resets
+// or the jump that led to its chain did. A DROP policy on entry is reported
+// too, unless a reached rule accepts resets.
+func (rs fwRuleset) rstDrops(entry string) []string {
+	var (
+		found    []string
+		accepted bool
+		seen     = map[string]bool{}
+	)
+	var walk func(chain string, viaReset bool)
+	walk = func(chain string, viaReset bool) {
+		if seen[chain] {
+			return
+		}
+		seen[chain] = true
+		for _, r := range rs.chains[chain] {
+			if r.others {
+				continue
+			}
+			resets := r.resets || viaReset
+			switch r.target {
+			case "DROP", "REJECT":
+				if resets {
+					found = append(found, r.text)
+				}
+			case "ACCEPT":
+				accepted = accepted || resets
+			default:
+				if _, ok := rs.chains[r.target]; ok {
+					walk(r.target, resets)
+				}
+			}
+		}
+	}
+	walk(entry, false)
+	if p, ok := rs.policies[entry]; ok && p[0] == "DROP" && !accepted {
+		found = append(found, p[1])
+	}
+	return found
+}
+
+// rstDropRules picks the rules from `iptables -S` output that drop or reject
+// resets on the way out of OUTPUT: --tcp-flags whose comparison set has RST
+// and nothing but RST and ACK, which is what the kernel and the SYN engine
+// send, or any DROP/REJECT in a chain jumped to with such a match. Sanity
+// rules such as "--tcp-flags SYN,RST SYN,RST" only match invalid combinations
+// and are not reported.
+func rstDropRules(listing string) []string {
+	rs := newFWRuleset()
+	for _, line := range strings.Split(listing, "\n") {
+		line = strings.TrimSpace(line)
+		fields := strings.Fields(line)
+		if len(fields) < 2 {
+			continue
+		}
+		switch fields[0] {
+		case "-P":
+			if len(fields) >= 3 {
+				rs.policies[fields[1]] = [2]string{fields[2], line}
+			}
+		case "-N":
+			rs.chains[fields[1]] = nil
+		case "-A":
+			rule := fwRule{text: line}
+			for i, f := range fields {
+				switch f {
+				case "--tcp-flags":
+					if i+2 < len(fields) && matchesReset(fields[i+2]) && !negatedFlags(fields) {
+						rule.resets = true
+					} else {
+						rule.others = true
+					}
+				case "-j", "-g":
+					if i+1 < len(fields) {
+						rule.target = fields[i+1]
+					}
+				}
+			}
+			rs.chains[fields[1]] = append(rs.chains[fields[1]], rule)
+		}
+	}
+	return rs.rstDrops("OUTPUT")
+}
+
+func matchesReset(comp string) bool {
+	hasRST := false
+	for _, f := range strings.Split(comp, ",") {
+		switch f {
+		case "RST":
+			hasRST = true
+		case "ACK":
+		default:
+			return false
+		}
+	}
+	return hasRST
+}
+
+// negatedFlags reports a "! --tcp-flags" match, which selects segments
+// without the flags.
+func negatedFlags(fields []string) bool {
+	for i := 0; i+1 < len(fields); i++ {
+		if fields[i] == "!" && fields[i+1] == "--tcp-flags" {
+			return true
+		}
+	}
+	return false
+}
+
+var (
+	nftQuoted    = regexp.MustCompile(`"[^"]*"`)
+	nftBaseChain = regexp.MustCompile(`\btype filter hook output\b`)
+	nftPolicy    = regexp.MustCompile(`\bpolicy (\w+);`)
+	nftFlagNames = map[string]bool{"fin": true, "syn": true, "rst": true, "psh": true, "ack": true, "urg": true, "ecn": true, "cwr": true}
+)
+
+// nftRSTDropRules applies the walk of rstDropRules to `nft list ruleset`
+// output, starting from every filter chain hooked to output. Tables named in
+// skip, as "family name", are left out. Rules are reported as
+// "family table chain: rule".
+func nftRSTDropRules(listing string, skip map[string]bool) []string {
+	var (
+		found        []string
+		rs           fwRuleset
+		table, chain string
+		bases        []string
+		depth        int
+	)
+	for _, line := range strings.Split(listing, "\n") {
+		line = strings.TrimSpace(line)
+		fields := strings.Fields(nftQuoted.ReplaceAllString(line, `""`))
+		opens := strings.Count(line, "{") - strings.Count(line, "}")
+		switch {
+		case depth == 0 && len(fields) >= 3 && fields[0] == "table":
+			for _, base := range bases {
+				found = append(found, rs.rstDrops(base)...)
+			}
+			rs, bases, chain = newFWRuleset(), nil, ""
+			table = fields[1] + " " + fields[2]
+			if skip[table] {
+				table = ""
+			}
+		case depth == 1 && table != "" && len(fields) >= 2 && fields[0] == "chain":
+			chain = fields[1]
+			rs.chains[chain] = nil
+		case depth == 2 && chain != "" && len(fields) > 0 && fields[0] != "}":
+			name := table + " " + chain
+			if nftBaseChain.MatchString(line) {
+				bases = append(bases, chain)
+				if m := nftPolicy.FindStringSubmatch(line); m != nil {
+					rs.policies[chain] = [2]string{strings.ToUpper(m[1]), name + ": policy " + m[1]}
+				}
+				break
+			}
+			rule := nftRule(fields)
+			rule.text = name + ": " + line
+			rs.chains[chain] = append(rs.chains[chain], rule)
+		}
+		depth += opens
+		if depth <= 1 {
+			chain = ""
+		}
+	}
+	for _, base := range bases {
+		found = append(found, rs.rstDrops(base)...)
+	}
+	return found
+}
+
+// nftRule reads the verdict and the "tcp flags" match of one nft rule.
+func nftRule(fields []string) fwRule {
+	var rule fwRule
+	for i := 0; i < len(fields); i++ {
+		switch fields[i] {
+		case "drop", "reject", "accept", "return":
+			if rule.target == "" {
+				rule.target = strings.ToUpper(fields[i])
+			}
+		case "jump", "goto":
+			if rule.target == "" && i+1 < len(fields) {
+				rule.target = fields[i+1]
+			}
+		case "tcp":
+			if i+1 < len(fields) && fields[i+1] == "flags" {
+				rule.resets = nftFlagsMatchReset(strings.Join(fields[i+2:], " "))
+				rule.others = !rule.resets
+			}
+		}
+	}
+	return rule
+}
+
+// nftFlagsMatchReset reports whether the expression after "tcp flags"
+// selects resets. It understands "rst", "== rst|ack", "rst / fin,syn,rst,ack",
+// "& (rst|ack) == rst", "& rst != 0" and sets such as "{ rst, rst|ack }".
+func nftFlagsMatchReset(expr string) bool {
+	expr = strings.NewReplacer("(", " ", ")", " ", "{", " { ", "}", " } ", "|", " | ", ",", " , ", "/", " / ").Replace(expr)
+	toks := strings.Fields(expr)
+	if len(toks) == 0 || toks[0] == "!=" {
+		return false
+	}
+	var mask []string
+	if toks[0] == "&" {
+		i := 1
+		for i < len(toks) && toks[i] != "==" && toks[i] != "!=" {
+			i++
+		}
+		if i+1 >= len(toks) {
+			return false
+		}
+		mask = nftFlagValue(toks[1:i])[0]
+		op := toks[i]
+		toks = toks[i+1:]
+		switch {
+		case op == "!=" && toks[0] == "0":
+			return matchesReset(strings.ToUpper(strings.Join(mask, ",")))
+		case op == "!=" || toks[0] == "0":
+			return false
+		}
+	} else if toks[0] == "==" {
+		toks = toks[1:]
+	}
+	for _, value := range nftFlagValue(toks) {
+		if matchesReset(strings.ToUpper(strings.Join(value, ","))) {
+			return true
+		}
+	}
+	return false
+}
+
+// nftFlagValue reads the flag value at the start of toks: one combination,
+// or the elements of an anonymous set. It stops at a mask or the first token
+// that is not part of the value.
+func nftFlagValue(toks []string) [][]string {
+	values := [][]string{nil}
+	inSet := false
+	for _, t := range toks {
+		switch {
+		case t == "{":
+			inSet = true
+		case t == "}" || t == "/":
+			return values
+		case t == "," && inSet:
+			values = append(values, nil)
+		case t == "|" || t == ",":
+		case nftFlagNames[t]:
+			values[len(values)-1] = append(values[len(values)-1], t)
+		default:
+			if !inSet {
+				return values
+			}
+		}
+	}
+	return values
+}
@@ -0,0 +1,182 @@
+package scanner
+
+import (
+	"slices"
+	"testing"
+)
+
+func TestRSTDropRules(t *testing.T) {
+	listing := `-P OUTPUT ACCEPT
+-A OUTPUT -p tcp -m tcp --tcp-flags RST RST -j DROP
+-A OUTPUT -p tcp -m tcp --tcp-flags SYN,RST SYN,RST -j DROP
+-A OUTPUT -d 10.0.0.0/8 -p tcp -m tcp --tcp-flags ALL RST,ACK -j REJECT --reject-with icmp-port-unreachable
+-A OUTPUT -p tcp -m tcp ! --tcp-flags RST RST -j DROP
+-A OUTPUT -p tcp -m tcp --tcp-flags RST RST -j ACCEPT
+`
+	rules := rstDropRules(listing)
+	if len(rules) != 2 {
+		t.Fatalf("expected 2 rules, got %d: %q", len(rules), rules)
+	}
+	if rules[0] != "-A OUTPUT -p tcp -m tcp --tcp-flags RST RST -j DROP" {
+		t.Fatalf("unexpected first rule: %q", rules[0])
+	}
+}
+
+func TestRSTDropRulesChainsAndPolicy(t *testing.T) {
+	for _, tc := range []struct {
+		name    string
+		listing string
+		want    []string
+	}{
+		{
+			"drop policy without a reset accept",
+			`-P INPUT ACCEPT
+-P OUTPUT DROP
+-A OUTPUT -o lo -j ACCEPT
+-A OUTPUT -p tcp -m tcp --dport 443 -j ACCEPT
+`,
+			[]string{"-P OUTPUT DROP"},
+		},
+		{
+			"drop policy with a reset accept",
+			`-P OUTPUT DROP
+-A OUTPUT -p tcp -m tcp --tcp-flags RST RST -j ACCEPT
+`,
+			nil,
+		},
+		{
+			"drop policy accepting resets in a user chain",
+			`-P OUTPUT DROP
+-N resets
+-A OUTPUT -p tcp -m tcp --tcp-flags RST,ACK RST -j resets
+-A resets -j ACCEPT
+`,
+			nil,
+		},
+		{
+			"drop in a chain jumped to on resets",
+			`-P OUTPUT ACCEPT
+-N RSTCHK
+-N LOGDROP
+-A OUTPUT -p tcp -m tcp --tcp-flags RST RST -j RSTCHK
+-A RSTCHK -d 192.0.2.0/24 -j LOGDROP
+-A LOGDROP -j LOG --log-prefix "rst "
+-A LOGDROP -j DROP
+`,
+			[]string{"-A LOGDROP -j DROP"},
+		},
+		{
+			"reset drop in a chain jumped to for all traffic",
+			`-P OUTPUT ACCEPT
+-N egress
+-A OUTPUT -j egress
+-A egress -p tcp -m tcp --tcp-flags RST RST -j REJECT
+-A egress -d 198.51.100.7/32 -j DROP
+`,
+			[]string{"-A egress -p tcp -m tcp --tcp-flags RST RST -j REJECT"},
+		},
+		{
+			"chains not reached from OUTPUT",
+			`-P INPUT DROP
+-N unused
+-A INPUT -p tcp -m tcp --tcp-flags RST RST -j DROP
+-A unused -p tcp -m tcp --tcp-flags RST RST -j DROP
+-A unused -j unused
+`,
+			nil,
+		},
+		{
+			"jump loops end",
+			`-N a
+-N b
+-A OUTPUT -p tcp -m tcp --tcp-flags RST RST -g a
+-A a -j b
+-A b -j a
+-A b -j DROP
+`,
+			[]string{"-A b -j DROP"},
+		},
+	} {
+		if got := rstDropRules(tc.listing); !slices.Equal(got, tc.want) {
+			t.Fatalf("%s: got %q, want %q", tc.name, got, tc.want)
+		}
+	}
+}
+
+func TestNFTRSTDropRules(t *testing.T) {
+	listing := `table ip filter {
+	chain OUTPUT {
+		type filter hook output priority filter; policy accept;
+		meta l4proto tcp tcp flags rst / rst counter packets 0 bytes 0 drop
+	}
+}
+table inet fw {
+	set blocked {
+		type ipv4_addr
+		elements = { 192.0.2.1,
+			     192.0.2.2 }
+	}
+
+	chain output {
+		type filter hook output priority filter; policy drop;
+		oifname "lo" accept comment "drop nothing"
+		tcp flags & (fin | syn | rst | ack) == syn accept
+		tcp flags syn,rst / syn,rst drop
+		tcp flags != rst drop
+		tcp flags { rst, rst | ack } jump rstcheck
+		ip daddr @blocked drop
+	}
+
+	chain rstcheck {
+		ip daddr 198.51.100.0/24 reject with tcp reset
+		tcp flags & rst != 0 counter drop
+	}
+}
+table ip nat {
+	chain OUTPUT {
+		type nat hook output priority -100; policy accept;
+		tcp flags rst drop
+	}
+}
+table ip6 strict {
+	chain out {
+		type filter hook output priority 0; policy drop;
+		tcp flags == rst | ack accept
+	}
+}
+`
+	want := []string{
+		"inet fw rstcheck: ip daddr 198.51.100.0/24 reject with tcp reset",
+		"inet fw rstcheck: tcp flags & rst != 0 counter drop",
+		"inet fw output: policy drop",
+	}
+	if got := nftRSTDropRules(listing, map[string]bool{"ip filter": true}); !slices.Equal(got, want) {
+		t.Fatalf("got %q, want %q", got, want)
+	}
+	got := nftRSTDropRules(listing, nil)
+	if len(got) != 4 || got[0] != "ip filter OUTPUT: meta l4proto tcp tcp flags rst / rst counter packets 0 bytes 0 drop" {
+		t.Fatalf("the ip filter table should be read when not skipped: %q", got)
+	}
+}
+
+func TestNFTFlagsMatchReset(t *testing.T) {
+	for expr, want := range map[string]bool{
+		"rst drop":                          true,
+		"== rst | ack counter drop":         true,
+		"rst / fin,syn,rst,ack drop":        true,
+		"rst,ack / fin,syn,rst,ack drop":    true,
+		"& (rst | ack) == rst drop":         true,
+		"& rst != 0 drop":                   true,
+		"& rst == 0 drop":                   false,
+		"& (syn | rst) == syn | rst drop":   false,
+		"!= rst drop":                       false,
+		"{ syn, rst } drop":                 true,
+		"{ syn, syn | ack } drop":           false,
+		"syn / fin,syn,rst,ack drop":        false,
+		"fin,psh,urg / fin,syn,rst,psh,ack": false,
+	} {
+		if got := nftFlagsMatchReset(expr); got != want {
+			t.Fatalf("%q: got %v, want %v", expr, got, want)
+		}
+	}
+}
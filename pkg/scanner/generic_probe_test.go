@@ -1,6 +1,7 @@
 package scanner
 
 import (
+	"context"
 	"net"
 	"testing"
 	"time"
@@ -37,7 +38,7 @@ func TestGenericProbeDetectsFTPOnNonStandardPort(t *testing.T) {
 	s := NewScanner("127.0.0.1", false)
 	s.Configure(ScanConfig{Timeout: 150 * time.Millisecond, NumWorkers: 1})
 
-	results := s.Scan([]int{port}, true)
+	results := s.Scan(context.Background(), []int{port}, true)
 	if len(results) != 1 {
 		t.Fatalf("expected one result, got %d (%v)", len(results), results)
 	}
@@ -92,7 +93,7 @@ func TestFTPProbeEnrichesGenericGreetingWithSYST(t *testing.T) {
 	s := NewScanner("127.0.0.1", false)
 	s.Configure(ScanConfig{Timeout: 150 * time.Millisecond, NumWorkers: 1})
 
-	results := s.Scan([]int{port}, true)
+	results := s.Scan(context.Background(), []int{port}, true)
 	if len(results) != 1 {
 		t.Fatalf("expected one result, got %d (%v)", len(results), results)
 	}
@@ -0,0 +1,329 @@
+package scanner
+
+import (
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"math/rand/v2"
+	"net"
+	"net/netip"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"golang.org/x/net/icmp"
+	"golang.org/x/net/ipv4"
+	"golang.org/x/net/ipv6"
+)
+
+const (
+	icmpProtoV4 = 1
+	icmpProtoV6 = 58
+)
+
+type icmpProbeKind int
+
+const (
+	icmpEcho icmpProbeKind = iota
+	icmpTimestamp
+)
+
+// icmpPinger sends echo and timestamp requests for host discovery over one
+// socket per address family, and hands each reply to the probe waiting for it.
+type icmpPinger struct {
+	v4, v6       *icmpEndpoint
+	v4Err, v6Err error
+}
+
+type icmpKey struct {
+	peer string
+	kind icmpProbeKind
+	seq  int
+}
+
+type icmpEndpoint struct {
+	conn *icmp.PacketConn
+	v6   bool
+	// privileged endpoints are raw sockets: every ICMP packet arrives and our
+	// ID filters them. Datagram ("ping") sockets get only their own replies,
+	// with the ID rewritten by the kernel, and can only send echo requests.
+	privileged bool
+	id         int
+	seq        atomic.Uint32
+	// ifIndex, when set, is the interface requests are sent through.
+	ifIndex int
+
+	mu sync.Mutex
+	// waiting receives the TTL (hop limit for IPv6) of the reply, 0 if unknown.
+	waiting map[icmpKey]chan int
+}
+
+// openICMPPinger opens the sockets on src's address. Requests go out through
+// src's interface, if any.
+func openICMPPinger(src SourceConfig) *icmpPinger {
+	p := &icmpPinger{}
+	p.v4, p.v4Err = openICMPEndpoint(false, src)
+	p.v6, p.v6Err = openICMPEndpoint(true, src)
+	return p
+}
+
+// openICMPEndpoint prefers a raw socket and falls back to an unprivileged
+// datagram socket, which Linux allows for groups in net.ipv4.ping_group_range.
+func openICMPEndpoint(v6 bool, src SourceConfig) (*icmpEndpoint, error) {
+	rawNet, dgramNet := "ip4:icmp", "udp4"
+	if v6 {
+		rawNet, dgramNet = "ip6:ipv6-icmp", "udp6"
+	}
+	addr, err := src.listenAddr(v6)
+	if err != nil {
+		return nil, err
+	}
+	ifIndex, err := src.ifIndex()
+	if err != nil {
+		return nil, err
+	}
+	privileged := true
+	conn, err := icmp.ListenPacket(rawNet, addr)
+	if err != nil {
+		rawErr := err
+		privileged = false
+		conn, err = icmp.ListenPacket(dgramNet, addr)
+		if err != nil {
+			return nil, fmt.Errorf("raw ICMP socket unavailable (%v; needs root/CAP_NET_RAW) and unprivileged ICMP socket refused (%v; check net.ipv4.ping_group_range)", rawErr, err)
+		}
+	}
+	ep := &icmpEndpoint{
+		conn:       conn,
+		v6:         v6,
+		privileged: privileged,
+		id:         rand.IntN(0xffff) + 1,
+		ifIndex:    ifIndex,
+		waiting:    make(map[icmpKey]chan int),
+	}
+	go ep.readLoop()
+	return ep, nil
+}
+
+// available reports whether method can run with the sockets that opened.
+func (p *icmpPinger) available(method string) error {
+	switch method {
+	case DiscoveryICMPEcho:
+		if p.v4 == nil && p.v6 == nil {
+			return p.v4Err
+		}
+	case DiscoveryICMPTimestamp:
+		if p.v4 == nil {
+			return p.v4Err
+		}
+		if !p.v4.privileged {
+			return errors.New("ICMP timestamp requests need a raw socket (root/CAP_NET_RAW); unprivileged ICMP sockets only send echo requests")
+		}
+	}
+	return nil
+}
+
+// probe sends one request of the given kind and waits for the matching reply.
+// It returns the round-trip time and the reply's TTL, 0 if unknown.
+func (p *icmpPinger) probe(ctx context.Context, host string, kind icmpProbeKind, timeout time.Duration) (time.Duration, int, bool) {
+	addr, err := netip.ParseAddr(host)
+	if err != nil {
+		return 0, 0, false
+	}
+	addr = addr.Unmap()
+	ep := p.v4
+	if addr.Is6() {
+		if kind == icmpTimestamp {
+			// ICMPv6 has no timestamp message.
+			return 0, 0, false
+		}
+		ep = p.v6
+	}
+	if ep == nil {
+		return 0, 0, false
+	}
+	return ep.probe(ctx, addr, kind, timeout)
+}
+
+func (p *icmpPinger) Close() {
+	if p.v4 != nil {
+		_ = p.v4.conn.Close()
+	}
+	if p.v6 != nil {
+		_ = p.v6.conn.Close()
+	}
+}
+
+func (ep *icmpEndpoint) probe(ctx context.Context, addr netip.Addr, kind icmpProbeKind, timeout time.Duration) (time.Duration, int, bool) {
+	seq := int(uint16(ep.seq.Add(1)))
+	key := icmpKey{peer: addr.WithZone("").String(), kind: kind, seq: seq}
+	replied := make(chan int, 1)
+	ep.mu.Lock()
+	ep.waiting[key] = replied
+	ep.mu.Unlock()
+	defer func() {
+		ep.mu.Lock()
+		delete(ep.waiting, key)
+		ep.mu.Unlock()
+	}()
+
+	packet, err := ep.request(kind, seq).Marshal(nil)
+	if err != nil {
+		return 0, 0, false
+	}
+	var dst net.Addr = &net.UDPAddr{IP: addr.AsSlice(), Zone: addr.Zone()}
+	if ep.privileged {
+		dst = &net.IPAddr{IP: addr.AsSlice(), Zone: addr.Zone()}
+	}
+	start := time.Now()
+	if err := ep.write(packet, dst); err != nil {
+		return 0, 0, false
+	}
+
+	timer := time.NewTimer(timeout)
+	defer timer.Stop()
+	select {
+	case ttl := <-replied:
+		return time.Since(start), ttl, true
+	case <-timer.C:
+	case <-ctx.Done():
+	}
+	return 0, 0, false
+}
+
+// write sends packet to dst, through the source interface when one is set.
+// The interface goes in an IP_PKTINFO control message, which raw and
+// unprivileged ICMP sockets both accept.
+func (ep *icmpEndpoint) write(packet []byte, dst net.Addr) error {
+	var err error
+	switch {
+	case ep.ifIndex == 0:
+		_, err = ep.conn.WriteTo(packet, dst)
+	case ep.v6:
+		_, err = ep.conn.IPv6PacketConn().WriteTo(packet, &ipv6.ControlMessage{IfIndex: ep.ifIndex}, dst)
+	default:
+		_, err = ep.conn.IPv4PacketConn().WriteTo(packet, &ipv4.ControlMessage{IfIndex: ep.ifIndex}, dst)
+	}
+	return err
+}
+
+func (ep *icmpEndpoint) request(kind icmpProbeKind, seq int) *icmp.Message {
+	if kind == icmpTimestamp {
+		// Identifier, sequence, then originate/receive/transmit timestamps in ms since midnight UTC.
+		data := make([]byte, 16)
+		binary.BigEndian.PutUint16(data[0:2], uint16(ep.id))
+		binary.BigEndian.PutUint16(data[2:4], uint16(seq))
+		now := time.Now().UTC()
+		midnight := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, time.UTC)
+		binary.BigEndian.PutUint32(data[4:8], uint32(now.Sub(midnight).Milliseconds()))
+		return &icmp.Message{Type: ipv4.ICMPTypeTimestamp, Body: &icmp.RawBody{Data: data}}
+	}
+	var typ icmp.Type = ipv4.ICMPTypeEcho
+	if ep.v6 {
+		typ = ipv6.ICMPTypeEchoRequest
+	}
+	return &icmp.Message{Type: typ, Body: &icmp.Echo{ID: ep.id, Seq: seq, Data: []byte("gomap-discovery")}}
+}
+
+func (ep *icmpEndpoint) readLoop() {
+	proto := icmpProtoV4
+	if ep.v6 {
+		proto = icmpProtoV6
+	}
+	read := ep.reader()
+	buf := make([]byte, 1500)
+	for {
+		n, ttl, peer, err := read(buf)
+		if err != nil {
+			if errors.Is(err, net.ErrClosed) {
+				return
+			}
+			continue
+		}
+		msg, err := icmp.ParseMessage(proto, buf[:n])
+		if err != nil {
+			continue
+		}
+		var (
+			kind    icmpProbeKind
+			id, seq int
+		)
+		switch body := msg.Body.(type) {
+		case *icmp.Echo:
+			if msg.Type != ipv4.ICMPTypeEchoReply && msg.Type != ipv6.ICMPTypeEchoReply {
+				continue
+			}
+			kind, id, seq = icmpEcho, body.ID, body.Seq
+		case *icmp.RawBody:
+			if msg.Type != ipv4.ICMPTypeTimestampReply || len(body.Data) < 4 {
+				continue
+			}
+			kind = icmpTimestamp
+			id = int(binary.BigEndian.Uint16(body.Data[0:2]))
+			seq = int(binary.BigEndian.Uint16(body.Data[2:4]))
+		default:
+			continue
+		}
+		if ep.privileged && id != ep.id {
+			continue
+		}
+		peerIP, ok := icmpPeer(peer)
+		if !ok {
+			continue
+		}
+		ep.mu.Lock()
+		if replied, waiting := ep.waiting[icmpKey{peer: peerIP, kind: kind, seq: seq}]; waiting {
+			select {
+			case replied <- ttl:
+			default:
+			}
+		}
+		ep.mu.Unlock()
+	}
+}
+
+// reader returns a read function that also reports each reply's TTL (hop
+// limit for IPv6) through IP_RECVTTL control messages, which raw and
+// unprivileged ICMP sockets both support. Where they cannot be enabled the
+// TTL is reported as 0.
+func (ep *icmpEndpoint) reader() func(b []byte) (n, ttl int, peer net.Addr, err error) {
+	if p6 := ep.conn.IPv6PacketConn(); p6 != nil && p6.SetControlMessage(ipv6.FlagHopLimit, true) == nil {
+		return func(b []byte) (int, int, net.Addr, error) {
+			n, cm, peer, err := p6.ReadFrom(b)
+			if cm == nil {
+				return n, 0, peer, err
+			}
+			return n, cm.HopLimit, peer, err
+		}
+	}
+	if p4 := ep.conn.IPv4PacketConn(); p4 != nil && p4.SetControlMessage(ipv4.FlagTTL, true) == nil {
+		return func(b []byte) (int, int, net.Addr, error) {
+			n, cm, peer, err := p4.ReadFrom(b)
+			if cm == nil {
+				return n, 0, peer, err
+			}
+			return n, cm.TTL, peer, err
+		}
+	}
+	return func(b []byte) (int, int, net.Addr, error) {
+		n, peer, err := ep.conn.ReadFrom(b)
+		return n, 0, peer, err
+	}
+}
+
+func icmpPeer(addr net.Addr) (string, bool) {
+	var ip net.IP
+	switch a := addr.(type) {
+	case *net.IPAddr:
+		ip = a.IP
+	case *net.UDPAddr:
+		ip = a.IP
+	default:
+		return "", false
+	}
+	parsed, ok := netip.AddrFromSlice(ip)
+	if !ok {
+		return "", false
+	}
+	return parsed.Unmap().String(), true
+}
@@ -0,0 +1,301 @@
+package scanner
+
+import (
+	"bufio"
+	"context"
+	_ "embed"
+	"encoding/binary"
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// OSGuess is the operating system family a host's TCP/IP stack points to.
+type OSGuess struct {
+	Name string
+	// Confidence is "high" when the SYN-ACK option layout and window settings
+	// match a signature, "medium" when only the option layout does and "low"
+	// for guesses from the TTL alone.
+	Confidence string
+	// Evidence lists the observed values, e.g. "ttl=64 win=65160 mss=1460 ws=7 opts=M,S,T,N,W".
+	Evidence string
+}
+
+// tcpFingerprint holds the SYN-ACK characteristics used to guess the OS.
+type tcpFingerprint struct {
+	ttl    int
+	window int
+	mss    int
+	// wscale is -1 when the window scale option is absent.
+	wscale int
+	// layout lists the options in order, e.g. "M,S,T,N,W".
+	layout string
+}
+
+func (fp tcpFingerprint) String() string {
+	return fmt.Sprintf("ttl=%d win=%d mss=%d ws=%d opts=%s", fp.ttl, fp.window, fp.mss, fp.wscale, fp.layout)
+}
+
+// synProbeOptions are the TCP options carried by SYN scan probes: MSS,
+// SACK permitted, timestamps, NOP and window scale, in the order Linux sends
+// them. Stacks only echo the options a SYN offers, so a bare SYN would get the
+// same MSS-only answer from every OS.
+func synProbeOptions(tsval uint32) []byte {
+	opts := []byte{
+		2, 4, 0x05, 0xb4, // MSS 1460
+		4, 2, // SACK permitted
+		8, 10, 0, 0, 0, 0, 0, 0, 0, 0, // timestamps
+		1,       // NOP
+		3, 3, 7, // window scale 7
+	}
+	binary.BigEndian.PutUint32(opts[8:12], tsval)
+	return opts
+}
+
+// parseTCPOptions walks the option bytes of a TCP header and returns the MSS,
+// the window scale (-1 when absent) and the option layout.
+func parseTCPOptions(opts []byte) (mss, wscale int, layout string) {
+	wscale = -1
+	var kinds []string
+	for i := 0; i < len(opts); {
+		kind := opts[i]
+		switch kind {
+		case 0:
+			kinds = append(kinds, "E")
+			return mss, wscale, strings.Join(kinds, ",")
+		case 1:
+			kinds = append(kinds, "N")
+			i++
+			continue
+		}
+		if i+1 >= len(opts) {
+			break
+		}
+		length := int(opts[i+1])
+		if length < 2 || i+length > len(opts) {
+			break
+		}
+		body := opts[i+2 : i+length]
+		switch kind {
+		case 2:
+			kinds = append(kinds, "M")
+			if len(body) == 2 {
+				mss = int(binary.BigEndian.Uint16(body))
+			}
+		case 3:
+			kinds = append(kinds, "W")
+			if len(body) == 1 {
+				wscale = int(body[0])
+			}
+		case 4:
+			kinds = append(kinds, "S")
+		case 8:
+			kinds = append(kinds, "T")
+		default:
+			kinds = append(kinds, "?"+strconv.Itoa(int(kind)))
+		}
+		i += length
+	}
+	return mss, wscale, strings.Join(kinds, ",")
+}
+
+// fingerprintSegment builds the fingerprint of a TCP segment received with ttl.
+func fingerprintSegment(seg []byte, ttl int) (tcpFingerprint, bool) {
+	if len(seg) < 20 {
+		return tcpFingerprint{}, false
+	}
+	dataOffset := int(seg[12]>>4) * 4
+	if dataOffset < 20 || dataOffset > len(seg) {
+		return tcpFingerprint{}, false
+	}
+	fp := tcpFingerprint{ttl: ttl, window: int(binary.BigEndian.Uint16(seg[14:16]))}
+	fp.mss, fp.wscale, fp.layout = parseTCPOptions(seg[20:dataOffset])
+	return fp, true
+}
+
+// initialTTL rounds an observed TTL up to the common initial value it most
+// likely started from; 0 means the TTL is unknown.
+func initialTTL(ttl int) int {
+	switch {
+	case ttl <= 0:
+		return 0
+	case ttl <= 32:
+		return 32
+	case ttl <= 64:
+		return 64
+	case ttl <= 128:
+		return 128
+	default:
+		return 255
+	}
+}
+
+// guessOSFromTTL names the OS families that use the initial TTL ttl came from.
+func guessOSFromTTL(ttl int) *OSGuess {
+	var name string
+	switch initialTTL(ttl) {
+	case 32:
+		name = "Windows 9x/NT (legacy)"
+	case 64:
+		name = "Linux/Unix"
+	case 128:
+		name = "Windows"
+	case 255:
+		name = "Network device or Solaris"
+	default:
+		return nil
+	}
+	return &OSGuess{Name: name, Confidence: "low", Evidence: fmt.Sprintf("ttl=%d", ttl)}
+}
+
+// guessOS matches fp against the embedded signature table. Signatures must
+// share the initial TTL; the option layout weighs most, then window size and
+// window scale. Without any layout match the guess falls back to the TTL.
+func guessOS(fp tcpFingerprint) *OSGuess {
+	ittl := initialTTL(fp.ttl)
+	var (
+		best      *osSignature
+		bestScore int
+	)
+	sigs := osSignatures()
+	for i, sig := range sigs {
+		if sig.ttl != ittl || sig.layout != fp.layout {
+			continue
+		}
+		score := 1
+		if sig.window >= 0 {
+			if sig.window != fp.window {
+				continue
+			}
+			score++
+		}
+		if sig.wscale >= 0 {
+			if sig.wscale != fp.wscale {
+				continue
+			}
+			score++
+		}
+		if best == nil || score > bestScore {
+			best, bestScore = &sigs[i], score
+		}
+	}
+	if best == nil {
+		guess := guessOSFromTTL(fp.ttl)
+		if guess != nil {
+			guess.Evidence = fp.String()
+		}
+		return guess
+	}
+	confidence := "medium"
+	if bestScore > 1 {
+		confidence = "high"
+	}
+	return &OSGuess{Name: best.name, Confidence: confidence, Evidence: fp.String()}
+}
+
+// TTLProber guesses OS families from the TTL of ICMP echo replies. Connect
+// scans never see the IP header of TCP replies, so this weaker, TTL-only
+// guess is all they can get, at the cost of one extra packet per host that
+// callers should only send when asked. It runs unprivileged where ping
+// sockets are allowed.
+type TTLProber struct {
+	pinger *icmpPinger
+}
+
+// NewTTLProber opens the ICMP sockets, raw or unprivileged, on src's address
+// and interface.
+func NewTTLProber(src SourceConfig) (*TTLProber, error) {
+	p := openICMPPinger(src)
+	if err := p.available(DiscoveryICMPEcho); err != nil {
+		p.Close()
+		return nil, err
+	}
+	return &TTLProber{pinger: p}, nil
+}
+
+// GuessOS sends one echo request to host and guesses its OS family from the
+// reply's TTL. ok is false when the host did not answer or the TTL could not
+// be read.
+func (t *TTLProber) GuessOS(ctx context.Context, host string, timeout time.Duration) (guess *OSGuess, ok bool) {
+	ip, err := resolveSYNTarget(host)
+	if err != nil {
+		return nil, false
+	}
+	_, ttl, replied := t.pinger.probe(ctx, ip.String(), icmpEcho, timeout)
+	if !replied || ttl <= 0 {
+		return nil, false
+	}
+	guess = guessOSFromTTL(ttl)
+	return guess, guess != nil
+}
+
+// Close releases the ICMP sockets.
+func (t *TTLProber) Close() {
+	t.pinger.Close()
+}
+
+//go:embed os_signatures.txt
+var osSignatureTable string
+
+type osSignature struct {
+	name   string
+	ttl    int
+	window int // -1 matches any
+	wscale int // -1 matches any
+	layout string
+}
+
+var (
+	osSignaturesOnce sync.Once
+	osSignatureList  []osSignature
+)
+
+func osSignatures() []osSignature {
+	osSignaturesOnce.Do(func() {
+		sc := bufio.NewScanner(strings.NewReader(osSignatureTable))
+		for sc.Scan() {
+			line := strings.TrimSpace(sc.Text())
+			if line == "" || strings.HasPrefix(line, "#") {
+				continue
+			}
+			sig, err := parseOSSignature(line)
+			if err != nil {
+				continue
+			}
+			osSignatureList = append(osSignatureList, sig)
+		}
+	})
+	return osSignatureList
+}
+
+func parseOSSignature(line string) (osSignature, error) {
+	fields := strings.Split(line, "\t")
+	if len(fields) != 5 {
+		return osSignature{}, fmt.Errorf("want 5 tab-separated fields, got %d", len(fields))
+	}
+	sig := osSignature{name: strings.TrimSpace(fields[0]), layout: strings.TrimSpace(fields[4])}
+	var err error
+	if sig.ttl, err = strconv.Atoi(fields[1]); err != nil {
+		return osSignature{}, fmt.Errorf("bad initial ttl %q", fields[1])
+	}
+	if sig.window, err = signatureNumber(fields[2]); err != nil {
+		return osSignature{}, err
+	}
+	if sig.wscale, err = signatureNumber(fields[3]); err != nil {
+		return osSignature{}, err
+	}
+	return sig, nil
+}
+
+func signatureNumber(field string) (int, error) {
+	if field == "*" {
+		return -1, nil
+	}
+	n, err := strconv.Atoi(field)
+	if err != nil || n < 0 {
+		return 0, fmt.Errorf("bad signature value %q", field)
+	}
+	return n, nil
+}
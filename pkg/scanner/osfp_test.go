@@ -0,0 +1,91 @@
+package scanner
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestParseTCPOptionsLayouts(t *testing.T) {
+	linux := []byte{2, 4, 0x05, 0xb4, 4, 2, 8, 10, 0, 0, 0, 1, 0, 0, 0, 0, 1, 3, 3, 7}
+	mss, wscale, layout := parseTCPOptions(linux)
+	if mss != 1460 || wscale != 7 || layout != "M,S,T,N,W" {
+		t.Fatalf("linux options: mss=%d ws=%d layout=%q", mss, wscale, layout)
+	}
+
+	windows := []byte{2, 4, 0x05, 0xb4, 1, 3, 3, 8, 1, 1, 4, 2}
+	mss, wscale, layout = parseTCPOptions(windows)
+	if mss != 1460 || wscale != 8 || layout != "M,N,W,N,N,S" {
+		t.Fatalf("windows options: mss=%d ws=%d layout=%q", mss, wscale, layout)
+	}
+
+	// A truncated option ends the walk instead of reading past the header.
+	mss, wscale, layout = parseTCPOptions([]byte{2, 4, 0x05})
+	if mss != 0 || wscale != -1 || layout != "" {
+		t.Fatalf("truncated options: mss=%d ws=%d layout=%q", mss, wscale, layout)
+	}
+}
+
+func TestSYNProbeOptionsRoundTrip(t *testing.T) {
+	opts := synProbeOptions(12345)
+	if len(opts)%4 != 0 {
+		t.Fatalf("options must be padded to 32-bit words, got %d bytes", len(opts))
+	}
+	seg := buildTCPSegment([]byte{10, 0, 0, 1}, []byte{10, 0, 0, 2}, 40000, 80, 1, 0, tcpFlagSyn, opts)
+	fp, ok := fingerprintSegment(seg, 64)
+	if !ok || fp.layout != "M,S,T,N,W" || fp.mss != 1460 || fp.wscale != 7 || fp.window != 64240 {
+		t.Fatalf("unexpected fingerprint: %+v ok=%v", fp, ok)
+	}
+}
+
+func TestGuessOS(t *testing.T) {
+	cases := []struct {
+		fp         tcpFingerprint
+		name       string
+		confidence string
+	}{
+		{tcpFingerprint{ttl: 57, window: 65160, mss: 1460, wscale: 7, layout: "M,S,T,N,W"}, "Linux 3.x-6.x", "high"},
+		{tcpFingerprint{ttl: 64, window: 65483, mss: 65495, wscale: 10, layout: "M,S,T,N,W"}, "Linux", "medium"},
+		{tcpFingerprint{ttl: 118, window: 65535, mss: 1460, wscale: 8, layout: "M,N,W,N,N,S"}, "Windows 10/11, Server 2016+", "high"},
+		{tcpFingerprint{ttl: 50, window: 65535, mss: 1460, wscale: 6, layout: "M,N,W,N,N,T,S,E"}, "macOS / iOS", "high"},
+		// No signature has this layout: fall back to the initial TTL.
+		{tcpFingerprint{ttl: 120, window: 1024, mss: 1460, wscale: -1, layout: "M"}, "Windows", "low"},
+		{tcpFingerprint{ttl: 250, window: 4128, mss: 536, wscale: -1, layout: "M"}, "Cisco IOS", "high"},
+	}
+	for _, tc := range cases {
+		got := guessOS(tc.fp)
+		if got == nil || got.Name != tc.name || got.Confidence != tc.confidence {
+			t.Fatalf("guessOS(%s) = %+v, want %s (%s)", tc.fp, got, tc.name, tc.confidence)
+		}
+		if got.Evidence != tc.fp.String() {
+			t.Fatalf("unexpected evidence %q", got.Evidence)
+		}
+	}
+	if guessOS(tcpFingerprint{}) != nil {
+		t.Fatal("expected no guess without a TTL")
+	}
+}
+
+func TestInitialTTL(t *testing.T) {
+	for ttl, want := range map[int]int{0: 0, 30: 32, 33: 64, 64: 64, 100: 128, 128: 128, 129: 255, 255: 255} {
+		if got := initialTTL(ttl); got != want {
+			t.Fatalf("initialTTL(%d) = %d, want %d", ttl, got, want)
+		}
+	}
+}
+
+func TestOSSignatureTableParses(t *testing.T) {
+	entries := 0
+	for _, line := range strings.Split(osSignatureTable, "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		entries++
+		if _, err := parseOSSignature(line); err != nil {
+			t.Fatalf("bad signature %q: %v", line, err)
+		}
+	}
+	if entries == 0 || len(osSignatures()) != entries {
+		t.Fatalf("parsed %d of %d signatures", len(osSignatures()), entries)
+	}
+}
@@ -0,0 +1,121 @@
+package scanner
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"os"
+	"syscall"
+	"testing"
+	"time"
+)
+
+func closedLocalTCPPort(t *testing.T) int {
+	t.Helper()
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("listen: %v", err)
+	}
+	port := listener.Addr().(*net.TCPAddr).Port
+	_ = listener.Close()
+	return port
+}
+
+func TestScanReportsClosedPortWhenRequested(t *testing.T) {
+	port := closedLocalTCPPort(t)
+
+	s := NewScanner("127.0.0.1", false)
+	s.Configure(ScanConfig{Timeout: 200 * time.Millisecond, NumWorkers: 1})
+	if results := s.Scan(context.Background(), []int{port}, false); len(results) != 0 {
+		t.Fatalf("expected closed port hidden by default, got %+v", results)
+	}
+
+	s.Configure(ScanConfig{Timeout: 200 * time.Millisecond, NumWorkers: 1, ShowClosed: true})
+	results := s.Scan(context.Background(), []int{port}, false)
+	if len(results) != 1 {
+		t.Fatalf("expected one closed result, got %+v", results)
+	}
+	if results[0].State != PortClosed || results[0].IsOpen {
+		t.Fatalf("expected closed state, got %+v", results[0])
+	}
+}
+
+func TestConnectErrorState(t *testing.T) {
+	refused := &net.OpError{Op: "dial", Net: "tcp", Err: os.NewSyscallError("connect", syscall.ECONNREFUSED)}
+	if got := connectErrorState(refused); got != PortClosed {
+		t.Fatalf("expected refused dial to be closed, got %s", got)
+	}
+	timeout := &net.OpError{Op: "dial", Net: "tcp", Err: os.NewSyscallError("connect", syscall.ETIMEDOUT)}
+	if got := connectErrorState(timeout); got != PortFiltered {
+		t.Fatalf("expected timed out dial to be filtered, got %s", got)
+	}
+	if got := connectErrorState(fmt.Errorf("no route")); got != PortFiltered {
+		t.Fatalf("expected unknown error to be filtered, got %s", got)
+	}
+}
+
+func TestReportsState(t *testing.T) {
+	s := NewScanner("127.0.0.1", false)
+	if !s.reportsState(PortOpen) {
+		t.Fatal("open ports must always be reported")
+	}
+	if s.reportsState(PortClosed) || s.reportsState(PortFiltered) || s.reportsState(PortOpenFiltered) {
+		t.Fatal("non-open states must be hidden by default")
+	}
+	s.ShowClosed = true
+	if !s.reportsState(PortClosed) || !s.reportsState(PortUnfiltered) || s.reportsState(PortFiltered) {
+		t.Fatal("show-closed should only reveal RST-backed states")
+	}
+	s.ShowFiltered = true
+	if !s.reportsState(PortFiltered) || !s.reportsState(PortOpenFiltered) {
+		t.Fatal("show-filtered should reveal silent states")
+	}
+}
+
+func TestScanUDPReportsClosedPort(t *testing.T) {
+	conn, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("listen udp: %v", err)
+	}
+	port := conn.LocalAddr().(*net.UDPAddr).Port
+	_ = conn.Close()
+
+	s := NewScanner("127.0.0.1", false)
+	s.Configure(ScanConfig{Timeout: 300 * time.Millisecond, NumWorkers: 1, ShowClosed: true, ShowFiltered: true})
+	results := s.ScanUDP(context.Background(), []int{port}, false)
+	if len(results) != 1 {
+		t.Fatalf("expected one udp result, got %+v", results)
+	}
+	if results[0].State != PortClosed {
+		t.Fatalf("expected closed udp port from icmp unreachable, got %+v", results[0])
+	}
+}
+
+func TestBuildResultsFromPortStates(t *testing.T) {
+	s := NewScanner("127.0.0.1", false)
+	s.ShowFiltered = true
+	states := map[int]PortState{22: PortOpen, 23: PortClosed, 25: PortFiltered}
+
+	got := BuildResultsFromPortStates(context.Background(), s, states, false)
+	if len(got) != 2 {
+		t.Fatalf("expected open and filtered results, got %+v", got)
+	}
+	if got[0].Port != 22 || got[0].State != PortOpen || got[0].ServiceName != "ssh" {
+		t.Fatalf("unexpected open result: %+v", got[0])
+	}
+	if got[1].Port != 25 || got[1].State != PortFiltered || got[1].ServiceName != "" {
+		t.Fatalf("unexpected filtered result: %+v", got[1])
+	}
+}
+
+func TestOpenCount(t *testing.T) {
+	results := []ScanResult{
+		{Port: 22},
+		{Port: 23, State: PortClosed},
+		{Port: 80, State: PortOpen, IsOpen: true},
+		{Port: 161, State: PortOpenFiltered},
+	}
+	if got := OpenCount(results); got != 2 {
+		t.Fatalf("expected 2 open results, got %d", got)
+	}
+}
@@ -0,0 +1,150 @@
+package scanner
+
+import (
+	"fmt"
+	"net/netip"
+	"strconv"
+	"strings"
+)
+
+// isRangeSpec reports whether spec uses octet (10.0.1-3.10-20) or dash
+// (10.0.0.1-10.0.0.200) range syntax rather than a plain address or hostname.
+func isRangeSpec(spec string) bool {
+	if !strings.ContainsAny(spec, "-*") || strings.Contains(spec, "/") {
+		return false
+	}
+	if looksLikeOctetRange(spec) {
+		return true
+	}
+	start, _, ok := strings.Cut(spec, "-")
+	if !ok {
+		return false
+	}
+	_, err := netip.ParseAddr(start)
+	return err == nil
+}
+
+// looksLikeOctetRange checks the shape only: four dot-separated numeric, a-b or * fields.
+func looksLikeOctetRange(spec string) bool {
+	parts := strings.Split(spec, ".")
+	if len(parts) != 4 {
+		return false
+	}
+	for _, part := range parts {
+		if part == "*" {
+			continue
+		}
+		lo, hi, isRange := strings.Cut(part, "-")
+		if !isDigits(lo) || (isRange && !isDigits(hi)) {
+			return false
+		}
+	}
+	return true
+}
+
+func isDigits(s string) bool {
+	if s == "" {
+		return false
+	}
+	for _, r := range s {
+		if r < '0' || r > '9' {
+			return false
+		}
+	}
+	return true
+}
+
+// rangeIntervals parses an octet or dash range spec into address intervals.
+func rangeIntervals(spec string) ([]addrInterval, error) {
+	if looksLikeOctetRange(spec) {
+		return octetRangeIntervals(spec)
+	}
+	return dashRangeInterval(spec)
+}
+
+// octetRangeIntervals parses nmap-style IPv4 octet ranges such as 10.0.1-3.10-20 or
+// 192.168.1.*. Each combination of the first three octets becomes one interval over
+// the last octet; the TargetSet merges contiguous rows.
+func octetRangeIntervals(spec string) ([]addrInterval, error) {
+	var bounds [4][2]int
+	rows := 1
+	for i, part := range strings.Split(spec, ".") {
+		lo, hi, err := parseOctetField(part)
+		if err != nil {
+			return nil, fmt.Errorf("invalid octet range %q in %s: %v", part, spec, err)
+		}
+		bounds[i] = [2]int{lo, hi}
+		if i < 3 {
+			rows *= hi - lo + 1
+		}
+	}
+
+	ivs := make([]addrInterval, 0, rows)
+	for a := bounds[0][0]; a <= bounds[0][1]; a++ {
+		for b := bounds[1][0]; b <= bounds[1][1]; b++ {
+			for c := bounds[2][0]; c <= bounds[2][1]; c++ {
+				start := netip.AddrFrom4([4]byte{byte(a), byte(b), byte(c), byte(bounds[3][0])})
+				end := netip.AddrFrom4([4]byte{byte(a), byte(b), byte(c), byte(bounds[3][1])})
+				ivs = append(ivs, addrInterval{start: addrToU128(start), end: addrToU128(end)})
+			}
+		}
+	}
+	return ivs, nil
+}
+
+func parseOctetField(part string) (int, int, error) {
+	if part == "*" {
+		return 0, 255, nil
+	}
+	loStr, hiStr, isRange := strings.Cut(part, "-")
+	lo, err := parseOctet(loStr)
+	if err != nil {
+		return 0, 0, err
+	}
+	if !isRange {
+		return lo, lo, nil
+	}
+	hi, err := parseOctet(hiStr)
+	if err != nil {
+		return 0, 0, err
+	}
+	if lo > hi {
+		return 0, 0, fmt.Errorf("start %d is greater than end %d", lo, hi)
+	}
+	return lo, hi, nil
+}
+
+func parseOctet(s string) (int, error) {
+	n, err := strconv.Atoi(s)
+	if err != nil || n < 0 || n > 255 {
+		return 0, fmt.Errorf("%q is not an octet between 0 and 255", s)
+	}
+	return n, nil
+}
+
+// dashRangeInterval parses start-end address ranges such as 10.0.0.1-10.0.0.200
+// or fd00::1-fd00::40. Both ends must be the same address family.
+func dashRangeInterval(spec string) ([]addrInterval, error) {
+	startStr, endStr, _ := strings.Cut(spec, "-")
+	start, err := netip.ParseAddr(startStr)
+	if err != nil {
+		return nil, fmt.Errorf("invalid range start %q in %s", startStr, spec)
+	}
+	end, err := netip.ParseAddr(endStr)
+	if err != nil {
+		return nil, fmt.Errorf("invalid range end %q in %s", endStr, spec)
+	}
+	start, end = start.Unmap(), end.Unmap()
+	if start.Is4() != end.Is4() {
+		return nil, fmt.Errorf("range %s mixes IPv4 and IPv6 addresses", spec)
+	}
+	if end.Less(start) {
+		return nil, fmt.Errorf("range start %s is greater than end %s", start, end)
+	}
+	// The host cap is the TargetSet's to enforce: exclusions may be larger.
+	return []addrInterval{{start: addrToU128(start), end: addrToU128(end)}}, nil
+}
+
+func rangeTooLargeError(spec string, limit uint64) error {
+	return fmt.Errorf("range too large: %s. Maximum: %d hosts. Use a smaller range", spec, limit)
+}
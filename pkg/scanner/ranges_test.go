@@ -0,0 +1,87 @@
+package scanner
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestExpandOctetRanges(t *testing.T) {
+	ips, err := ExpandCIDR("10.0.1-3.10-20")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(ips) != 3*11 {
+		t.Fatalf("expected 33 hosts, got %d", len(ips))
+	}
+	if ips[0] != "10.0.1.10" || ips[len(ips)-1] != "10.0.3.20" {
+		t.Fatalf("unexpected range: %s - %s", ips[0], ips[len(ips)-1])
+	}
+
+	short, err := ExpandCIDR("192.168.1.5-40")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(short) != 36 || short[0] != "192.168.1.5" || short[35] != "192.168.1.40" {
+		t.Fatalf("unexpected short range: %d hosts (%s - %s)", len(short), short[0], short[len(short)-1])
+	}
+}
+
+func TestExpandDashRanges(t *testing.T) {
+	ips, err := ExpandCIDR("10.0.0.250-10.0.1.4")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(ips) != 11 || ips[0] != "10.0.0.250" || ips[10] != "10.0.1.4" {
+		t.Fatalf("unexpected dash range: %v", ips)
+	}
+
+	v6, err := ExpandCIDR("fd00:11::fe-fd00:11::101")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(v6) != 4 || v6[0] != "fd00:11::fe" || v6[3] != "fd00:11::101" {
+		t.Fatalf("unexpected ipv6 dash range: %v", v6)
+	}
+}
+
+func TestExpandRangeErrorsNameOffendingToken(t *testing.T) {
+	cases := map[string]string{
+		"10.0.1-300.5":       `"1-300"`,
+		"10.0.9-3.5":         `"9-3"`,
+		"10.0.0.1-foo":       `"foo"`,
+		"10.0.0.9-10.0.0.1":  "greater than end",
+		"10.0.0.1-fd00::1":   "mixes IPv4 and IPv6",
+		"10.*.*.1-2":         "range too large",
+		"10.0.0.0-10.1.0.0":  "range too large",
+		"fd00::-fd00::1:0:0": "range too large",
+	}
+	for spec, want := range cases {
+		_, err := ExpandCIDR(spec)
+		if err == nil || !strings.Contains(err.Error(), want) {
+			t.Fatalf("%s: expected error containing %s, got %v", spec, want, err)
+		}
+	}
+}
+
+func TestExpandRangeAtCap(t *testing.T) {
+	ips, err := ExpandCIDR("10.0.*.*")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(ips) != maxExpandedHosts {
+		t.Fatalf("expected %d hosts, got %d", maxExpandedHosts, len(ips))
+	}
+}
+
+func TestIsCIDRRecognizesRanges(t *testing.T) {
+	for _, spec := range []string{"10.0.0.0/24", "10.0.1-3.10", "10.0.0.1-10.0.0.20", "10.0.0.*"} {
+		if !IsCIDR(spec) {
+			t.Fatalf("expected %s to be treated as a range", spec)
+		}
+	}
+	for _, spec := range []string{"10.0.0.1", "lab-host.internal", "fd00::1"} {
+		if IsCIDR(spec) {
+			t.Fatalf("expected %s to be treated as a single host", spec)
+		}
+	}
+}
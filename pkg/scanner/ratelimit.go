@@ -0,0 +1,107 @@
+package scanner
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// RateLimiter is a token bucket shared by every engine of a run: connect,
+// UDP and raw scans, service probes, host discovery and traceroute take one
+// token per probe they send, so the whole run stays under one global rate
+// however many hosts are scanned at once. A nil RateLimiter is unlimited.
+type RateLimiter struct {
+	mu     sync.Mutex
+	rate   float64 // tokens added per second
+	burst  float64 // bucket size
+	tokens float64 // may go negative: each waiter has reserved its token
+	last   time.Time
+	first  time.Time
+	taken  uint64
+}
+
+// RateStats summarizes the probes a RateLimiter let through.
+type RateStats struct {
+	MaxRate int
+	Burst   int
+	Probes  uint64
+	// Elapsed runs from the first probe to the time of the call.
+	Elapsed time.Duration
+}
+
+// Rate returns the achieved probes per second, or 0 before any probe.
+func (st RateStats) Rate() float64 {
+	if st.Probes == 0 || st.Elapsed <= 0 {
+		return 0
+	}
+	return float64(st.Probes) / st.Elapsed.Seconds()
+}
+
+// NewRateLimiter creates a limiter allowing rate probes per second on
+// average and up to burst back to back. burst <= 0 picks a tenth of a
+// second's worth of tokens, and at least one. The bucket starts full.
+func NewRateLimiter(rate, burst int) *RateLimiter {
+	if rate <= 0 {
+		rate = 1
+	}
+	if burst <= 0 {
+		burst = max(1, rate/10)
+	}
+	return &RateLimiter{rate: float64(rate), burst: float64(burst), tokens: float64(burst)}
+}
+
+// Wait blocks until a token is available and takes it. It returns ctx.Err()
+// if ctx is cancelled first, and gives the reserved token back.
+func (l *RateLimiter) Wait(ctx context.Context) error {
+	if l == nil {
+		return ctx.Err()
+	}
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	l.mu.Lock()
+	now := time.Now()
+	if l.last.IsZero() {
+		l.first = now
+	} else {
+		l.tokens = min(l.burst, l.tokens+now.Sub(l.last).Seconds()*l.rate)
+	}
+	l.last = now
+	l.tokens--
+	delay := time.Duration(0)
+	if l.tokens < 0 {
+		delay = time.Duration(-l.tokens / l.rate * float64(time.Second))
+	}
+	l.mu.Unlock()
+
+	if delay > 0 {
+		timer := time.NewTimer(delay)
+		defer timer.Stop()
+		select {
+		case <-timer.C:
+		case <-ctx.Done():
+			l.mu.Lock()
+			l.tokens++
+			l.mu.Unlock()
+			return ctx.Err()
+		}
+	}
+	l.mu.Lock()
+	l.taken++
+	l.mu.Unlock()
+	return nil
+}
+
+// Stats returns the limits and the probes let through so far.
+func (l *RateLimiter) Stats() RateStats {
+	if l == nil {
+		return RateStats{}
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	st := RateStats{MaxRate: int(l.rate), Burst: int(l.burst), Probes: l.taken}
+	if !l.first.IsZero() {
+		st.Elapsed = time.Since(l.first)
+	}
+	return st
+}
@@ -0,0 +1,113 @@
+package scanner
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/NexusFireMan/gomap/v2/internal/testutil"
+)
+
+func TestRateLimiterPacesAfterBurst(t *testing.T) {
+	l := NewRateLimiter(200, 5)
+	start := time.Now()
+	for i := 0; i < 5; i++ {
+		if err := l.Wait(context.Background()); err != nil {
+			t.Fatalf("wait: %v", err)
+		}
+	}
+	if elapsed := time.Since(start); elapsed > 20*time.Millisecond {
+		t.Fatalf("a full bucket should let the burst through at once, took %v", elapsed)
+	}
+	// The next 20 tokens arrive at 200/s: about 100ms.
+	for i := 0; i < 20; i++ {
+		if err := l.Wait(context.Background()); err != nil {
+			t.Fatalf("wait: %v", err)
+		}
+	}
+	if elapsed := time.Since(start); elapsed < 90*time.Millisecond {
+		t.Fatalf("25 probes at 200/s with burst 5 took only %v", elapsed)
+	}
+	st := l.Stats()
+	if st.Probes != 25 || st.MaxRate != 200 || st.Burst != 5 || st.Rate() > 260 {
+		t.Fatalf("unexpected stats %+v (rate %.1f)", st, st.Rate())
+	}
+}
+
+func TestRateLimiterCancelReturnsToken(t *testing.T) {
+	l := NewRateLimiter(1, 1)
+	if err := l.Wait(context.Background()); err != nil {
+		t.Fatalf("wait: %v", err)
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	if err := l.Wait(ctx); !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("expected deadline error, got %v", err)
+	}
+	if st := l.Stats(); st.Probes != 1 {
+		t.Fatalf("a cancelled wait must not count as a probe: %+v", st)
+	}
+	l.mu.Lock()
+	tokens := l.tokens
+	l.mu.Unlock()
+	if tokens < -0.01 {
+		t.Fatalf("cancelled reservation still holds a token: %v", tokens)
+	}
+
+	var unlimited *RateLimiter
+	if err := unlimited.Wait(context.Background()); err != nil {
+		t.Fatalf("nil limiter should never block: %v", err)
+	}
+	if unlimited.Stats() != (RateStats{}) {
+		t.Fatal("nil limiter should report no stats")
+	}
+}
+
+func TestNewRateLimiterDefaultBurst(t *testing.T) {
+	if got := NewRateLimiter(300, 0).Stats().Burst; got != 30 {
+		t.Fatalf("expected burst 30, got %d", got)
+	}
+	if got := NewRateLimiter(5, 0).Stats().Burst; got != 1 {
+		t.Fatalf("expected burst 1, got %d", got)
+	}
+}
+
+func TestRateLimiterSharedAcrossEngines(t *testing.T) {
+	port := testutil.StartService(t, nil)
+
+	limiter := NewRateLimiter(1000, 1)
+	active, err := DiscoverActiveTargets(context.Background(), SliceIterator([]string{"127.0.0.1", "127.0.0.2"}), DiscoveryOptions{
+		Ports:   []int{port},
+		Timeout: 300 * time.Millisecond,
+		Limiter: limiter,
+	})
+	if err != nil || len(active) != 2 {
+		t.Fatalf("unexpected discovery result %+v (%v)", active, err)
+	}
+	for _, host := range []string{"10.0.11.6", "10.0.11.7"} {
+		s := NewScanner(host, false)
+		s.Configure(ScanConfig{Timeout: time.Second, NumWorkers: 8, Limiter: limiter, Dialer: pipeDialer{serve: fakeServices}})
+		s.Scan(context.Background(), []int{21, 22, 23, 80}, false)
+	}
+	// Two discovery probes plus four ports on each of two hosts.
+	if st := limiter.Stats(); st.Probes != 10 {
+		t.Fatalf("expected 10 probes through the shared limiter, got %+v", st)
+	}
+}
+
+func TestRateLimiterWaitIsNotLatency(t *testing.T) {
+	port := testutil.StartService(t, nil)
+	s := NewScanner("127.0.0.1", false)
+	// Four dials at 10/s with no burst queue for up to 300ms each.
+	s.Configure(ScanConfig{Timeout: time.Second, NumWorkers: 4, Limiter: NewRateLimiter(10, 1)})
+	results := s.Scan(context.Background(), []int{port, port, port, port}, false)
+	if len(results) == 0 {
+		t.Fatal("expected the open port")
+	}
+	for _, r := range results {
+		if r.Latency >= 100*time.Millisecond {
+			t.Fatalf("rate limiter wait counted as latency: %v", r.Latency)
+		}
+	}
+}
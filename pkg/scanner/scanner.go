@@ -1,6 +1,7 @@
 package scanner
 
 import (
+	"context"
 	"crypto/tls"
 	"encoding/binary"
 	"errors"
@@ -14,8 +15,6 @@ import (
 	"sync"
 	"syscall"
 	"time"
-
-	"github.com/stacktitan/smb/smb"
 )
 
 // Scanner handles the port scanning logic
@@ -26,6 +25,7 @@ type Scanner struct {
 	Timeout            time.Duration
 	Retries            int
 	AdaptiveTimeout    bool
+	Congestion         bool
 	BackoffBase        time.Duration
 	BackoffMax         time.Duration
 	MinAdaptiveTimeout time.Duration
@@ -35,7 +35,19 @@ type Scanner struct {
 	RandomAgent        bool
 	RandomIP           bool
 	DeepVersion        bool
+	ShowClosed         bool
+	ShowFiltered       bool
 	targetPrefix       netip.Prefix
+	budget             *DialBudget
+	onResult           func(ScanResult)
+	source             SourceConfig
+	dialer             Dialer
+	probeDialer        Dialer
+	congestion         *congestionWindow
+	limiter            *RateLimiter
+	// scanCtx is the context of the running scan. Service probes inherit
+	// it, so a host or scan deadline stops them promptly.
+	scanCtx context.Context
 
 	adaptiveMu    sync.Mutex
 	ewmaLatency   time.Duration
@@ -57,6 +69,26 @@ type ScanConfig struct {
 	RandomIP        bool
 	TargetCIDR      string
 	DeepVersion     bool
+	ShowClosed      bool
+	ShowFiltered    bool
+	// Budget, when set, caps in-flight dials across every scanner sharing it.
+	Budget *DialBudget
+	// OnResult, when set, is called once per completed port in any state, including
+	// states hidden by ShowClosed/ShowFiltered. It is called from worker goroutines.
+	OnResult func(ScanResult)
+	// Source pins the interface, address and port every probe is sent from.
+	// It configures the default dialer and is ignored when Dialer is set.
+	Source SourceConfig
+	// Dialer, when set, opens every connection instead of the default
+	// NetDialer, e.g. a ProxyDialer. UDP scans need it to support "udp".
+	Dialer Dialer
+	// Congestion replaces the fixed connect concurrency with a congestion
+	// window of up to NumWorkers dials, and the adaptive timeout with an
+	// RFC 6298 RTO. See CongestionStats.
+	Congestion bool
+	// Limiter, when set, is a global rate cap shared with other scanners
+	// and engines. Every dial, scan or service probe, takes a token.
+	Limiter *RateLimiter
 }
 
 // NewScanner creates a new Scanner instance
@@ -85,6 +117,8 @@ func NewScanner(host string, ghostMode bool) *Scanner {
 		RandomAgent:        false,
 		RandomIP:           false,
 		DeepVersion:        false,
+		dialer:             NetDialer{},
+		probeDialer:        NetDialer{},
 	}
 }
 
@@ -110,6 +144,13 @@ func (s *Scanner) Configure(cfg ScanConfig) {
 	s.RandomAgent = cfg.RandomAgent
 	s.RandomIP = cfg.RandomIP
 	s.DeepVersion = cfg.DeepVersion
+	s.ShowClosed = cfg.ShowClosed
+	s.ShowFiltered = cfg.ShowFiltered
+	s.budget = cfg.Budget
+	s.limiter = cfg.Limiter
+	s.onResult = cfg.OnResult
+	s.source = cfg.Source
+	s.setDialer(cfg.Dialer, cfg.Source)
 	if s.RandomIP {
 		s.targetPrefix = parseTargetPrefix(cfg.TargetCIDR, s.Host)
 	}
@@ -132,10 +173,28 @@ func (s *Scanner) Configure(cfg ScanConfig) {
 			s.NumWorkers = 4
 		}
 	}
+	s.Congestion = cfg.Congestion
+	s.congestion = nil
+	if s.Congestion {
+		s.congestion = newCongestionWindow(s.NumWorkers, s.Timeout, s.MaxAdaptiveTimeout)
+	}
+}
+
+// CongestionStats returns the congestion window and RTT estimates of the
+// last connect scan, or nil when ScanConfig.Congestion is off.
+func (s *Scanner) CongestionStats() *CongestionStats {
+	if s.congestion == nil {
+		return nil
+	}
+	stats := s.congestion.stats()
+	return &stats
 }
 
-// Scan performs the port scanning operation
-func (s *Scanner) Scan(ports []int, detectServices bool) []ScanResult {
+// Scan performs the port scanning operation. Cancelling ctx stops new probes and
+// aborts in-flight dials and service probes; results completed before
+// cancellation are returned, open ports with whatever detection finished.
+func (s *Scanner) Scan(ctx context.Context, ports []int, detectServices bool) []ScanResult {
+	s.scanCtx = ctx
 	if s.GhostMode {
 		rand.Shuffle(len(ports), func(i, j int) {
 			ports[i], ports[j] = ports[j], ports[i]
@@ -161,53 +220,144 @@ func (s *Scanner) Scan(ports []int, detectServices bool) []ScanResult {
 		go func(workerID int) {
 			defer wg.Done()
 			for port := range portsChan {
+				if ctx.Err() != nil {
+					continue
+				}
 				if s.GhostMode {
 					s.addJitter()
 				}
-				if rateLimiter != nil {
-					<-rateLimiter
+				if !waitRateSlot(ctx, rateLimiter) {
+					continue
+				}
+				result := s.scanPort(ctx, port, detectServices)
+				if ctx.Err() != nil && !result.IsOpen {
+					// The dial was cut short by cancellation, so the state is unknown.
+					continue
 				}
-				resultsChan <- s.scanPort(port, detectServices)
+				s.emitResult(result)
+				resultsChan <- result
 			}
 		}(i)
 	}
 
-	for _, port := range ports {
-		portsChan <- port
-	}
-	close(portsChan)
+	feedPorts(ctx, portsChan, ports)
 
 	wg.Wait()
 	close(resultsChan)
 
-	var openPorts []ScanResult
+	var reported []ScanResult
 	for result := range resultsChan {
-		if result.IsOpen {
-			openPorts = append(openPorts, result)
+		if s.reportsState(result.EffectiveState()) {
+			reported = append(reported, result)
 		}
 	}
 
-	sort.Slice(openPorts, func(i, j int) bool {
-		return openPorts[i].Port < openPorts[j].Port
+	sort.Slice(reported, func(i, j int) bool {
+		return reported[i].Port < reported[j].Port
 	})
 
-	return dedupeOpenResults(openPorts)
+	return dedupeOpenResults(reported)
+}
+
+// feedPorts sends ports to workers until done or ctx is cancelled, then closes the channel.
+func feedPorts(ctx context.Context, portsChan chan<- int, ports []int) {
+	defer close(portsChan)
+	for _, port := range ports {
+		select {
+		case portsChan <- port:
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+// waitRateSlot blocks for the next rate limiter tick. It returns false if ctx is cancelled first.
+func waitRateSlot(ctx context.Context, rateLimiter <-chan time.Time) bool {
+	if rateLimiter == nil {
+		return ctx.Err() == nil
+	}
+	select {
+	case <-rateLimiter:
+		return true
+	case <-ctx.Done():
+		return false
+	}
+}
+
+func (s *Scanner) emitResult(result ScanResult) {
+	if s.onResult != nil {
+		s.onResult(result)
+	}
+}
+
+// reportsState reports whether results in the given state should be kept.
+// RST-backed states (closed, unfiltered) follow ShowClosed; silent states follow ShowFiltered.
+func (s *Scanner) reportsState(state PortState) bool {
+	return StateVisible(state, s.ShowClosed, s.ShowFiltered)
+}
+
+// StateVisible reports whether a port state is shown under the given
+// --show-closed/--show-filtered settings. Open ports are always visible.
+func StateVisible(state PortState, showClosed, showFiltered bool) bool {
+	switch state {
+	case PortOpen:
+		return true
+	case PortClosed, PortUnfiltered:
+		return showClosed
+	case PortFiltered, PortOpenFiltered:
+		return showFiltered
+	default:
+		return false
+	}
+}
+
+// connectErrorState maps a failed connect() to a port state: an active refusal
+// proves the host answered for the port, everything else is treated as dropped.
+func connectErrorState(err error) PortState {
+	if errors.Is(err, syscall.ECONNREFUSED) || errors.Is(err, syscall.ECONNRESET) {
+		return PortClosed
+	}
+	return PortFiltered
 }
 
 // scanPort scans a single port
-func (s *Scanner) scanPort(port int, detectServices bool) ScanResult {
+func (s *Scanner) scanPort(ctx context.Context, port int, detectServices bool) ScanResult {
 	address := net.JoinHostPort(s.Host, fmt.Sprintf("%d", port))
-	start := time.Now()
 
 	var (
 		conn net.Conn
 		err  error
+		// latency is the handshake time of the last attempt.
+		latency time.Duration
 	)
 
 	for attempt := 0; attempt <= s.Retries; attempt++ {
+		if err = s.limiter.Wait(ctx); err != nil {
+			break
+		}
+		seq, ok := s.congestion.acquire(ctx)
+		if !ok {
+			err = ctx.Err()
+			break
+		}
+		if !s.budget.acquire(ctx) {
+			s.congestion.release()
+			err = ctx.Err()
+			break
+		}
+		// Waits for the rate limiter, the window and the budget are queueing,
+		// not path delay, so neither the RTT sample nor the reported latency
+		// includes them.
 		attemptStart := time.Now()
-		conn, err = net.DialTimeout("tcp", address, s.currentTimeout())
-		s.recordDialOutcome(err, time.Since(attemptStart))
+		conn, err = s.dialContext(ctx, "tcp", address, s.currentTimeout())
+		latency = time.Since(attemptStart)
+		s.budget.release()
+		if ctx.Err() != nil {
+			s.congestion.release()
+			break
+		}
+		s.congestion.observe(seq, err, latency)
+		s.recordDialOutcome(err, latency)
 		if err == nil {
 			break
 		}
@@ -217,17 +367,17 @@ func (s *Scanner) scanPort(port int, detectServices bool) ScanResult {
 	}
 
 	if err != nil {
-		latency := time.Since(start)
 		return ScanResult{
 			Port:      port,
 			IsOpen:    false,
+			State:     connectErrorState(err),
 			Latency:   latency,
 			LatencyMs: latency.Milliseconds(),
 		}
 	}
+	conn = watchConn(ctx, conn)
 	defer func() { _ = conn.Close() }()
 
-	latency := time.Since(start)
 	latencyMs := latency.Milliseconds()
 	if latencyMs == 0 {
 		latencyMs = 1
@@ -235,6 +385,7 @@ func (s *Scanner) scanPort(port int, detectServices bool) ScanResult {
 	result := ScanResult{
 		Port:      port,
 		IsOpen:    true,
+		State:     PortOpen,
 		Latency:   latency,
 		LatencyMs: latencyMs,
 	}
@@ -253,6 +404,9 @@ func (s *Scanner) scanPort(port int, detectServices bool) ScanResult {
 }
 
 func (s *Scanner) currentTimeout() time.Duration {
+	if s.congestion != nil {
+		return s.congestion.timeout()
+	}
 	base := s.Timeout
 	if !s.AdaptiveTimeout {
 		return base
@@ -285,9 +439,28 @@ func (s *Scanner) currentTimeout() time.Duration {
 func (s *Scanner) ioTimeout(min time.Duration) time.Duration {
 	timeout := s.currentTimeout()
 	if timeout < min {
-		return min
+		timeout = min
 	}
-	return timeout
+	return s.withinDeadline(timeout)
+}
+
+// withinDeadline shortens timeout to what is left before the scan's
+// deadline, so a probe started just before it does not outlive it.
+func (s *Scanner) withinDeadline(timeout time.Duration) time.Duration {
+	deadline, ok := s.probeContext().Deadline()
+	if !ok {
+		return timeout
+	}
+	return min(timeout, max(time.Until(deadline), time.Millisecond))
+}
+
+// probeContext returns the context of the running scan, or Background
+// outside of one.
+func (s *Scanner) probeContext() context.Context {
+	if s.scanCtx == nil {
+		return context.Background()
+	}
+	return s.scanCtx
 }
 
 func (s *Scanner) boundedServiceTimeout(min, max time.Duration) time.Duration {
@@ -816,8 +989,7 @@ func (s *Scanner) grabHTTPBanner(port int) string {
 
 	// Try TLS first on common HTTPS ports for realistic service/version discovery.
 	if shouldUseTLSForHTTP(port) {
-		dialer := &net.Dialer{Timeout: timeout}
-		tlsConn, tlsErr := tls.DialWithDialer(dialer, "tcp", address, &tls.Config{
+		tlsConn, tlsErr := s.dialTLS(address, timeout, &tls.Config{
 			InsecureSkipVerify: true, // Banner grabbing only
 			ServerName:         s.Host,
 		})
@@ -827,7 +999,7 @@ func (s *Scanner) grabHTTPBanner(port int) string {
 	}
 
 	if conn == nil {
-		conn, err = net.DialTimeout("tcp", address, timeout)
+		conn, err = s.dial("tcp", address, timeout)
 		if err != nil {
 			return ""
 		}
@@ -909,7 +1081,7 @@ func (s *Scanner) probeFTP(port int) string {
 	address := net.JoinHostPort(s.Host, fmt.Sprintf("%d", port))
 	timeout := s.boundedServiceTimeout(1200*time.Millisecond, 4*time.Second)
 
-	conn, err := net.DialTimeout("tcp", address, timeout)
+	conn, err := s.dial("tcp", address, timeout)
 	if err != nil {
 		return ""
 	}
@@ -946,13 +1118,12 @@ func (s *Scanner) probeMailService(port int, payload string, useTLS bool) string
 		err  error
 	)
 	if useTLS {
-		dialer := &net.Dialer{Timeout: timeout}
-		conn, err = tls.DialWithDialer(dialer, "tcp", address, &tls.Config{
+		conn, err = s.dialTLS(address, timeout, &tls.Config{
 			InsecureSkipVerify: true,
 			ServerName:         s.Host,
 		})
 	} else {
-		conn, err = net.DialTimeout("tcp", address, timeout)
+		conn, err = s.dial("tcp", address, timeout)
 	}
 	if err != nil {
 		return ""
@@ -1006,7 +1177,7 @@ func (s *Scanner) probeFTPGenericLines(port int) string {
 	address := net.JoinHostPort(s.Host, fmt.Sprintf("%d", port))
 	timeout := s.boundedServiceTimeout(700*time.Millisecond, 1500*time.Millisecond)
 
-	conn, err := net.DialTimeout("tcp", address, timeout)
+	conn, err := s.dial("tcp", address, timeout)
 	if err != nil {
 		return ""
 	}
@@ -1131,7 +1302,7 @@ func (s *Scanner) probeTextService(port int, payload string) string {
 		timeout = 750 * time.Millisecond
 	}
 
-	conn, err := net.DialTimeout("tcp", address, timeout)
+	conn, err := s.dial("tcp", address, timeout)
 	if err != nil {
 		return ""
 	}
@@ -1190,7 +1361,7 @@ func (s *Scanner) probeTextServiceWriteFirstWithTimeout(port int, payload string
 	address := net.JoinHostPort(s.Host, fmt.Sprintf("%d", port))
 	timeout := s.boundedServiceTimeout(minTimeout, maxTimeout)
 
-	conn, err := net.DialTimeout("tcp", address, timeout)
+	conn, err := s.dial("tcp", address, timeout)
 	if err != nil {
 		return ""
 	}
@@ -1357,7 +1528,7 @@ func (s *Scanner) oncRPCNullCall(port int, program, version uint32) (accepted, r
 		timeout = 1200 * time.Millisecond
 	}
 
-	conn, err := net.DialTimeout("tcp", address, timeout)
+	conn, err := s.dial("tcp", address, timeout)
 	if err != nil {
 		return false, false
 	}
@@ -1440,7 +1611,7 @@ func (s *Scanner) detectAJP(port int) bool {
 		timeout = 1200 * time.Millisecond
 	}
 
-	conn, err := net.DialTimeout("tcp", address, timeout)
+	conn, err := s.dial("tcp", address, timeout)
 	if err != nil {
 		return false
 	}
@@ -1475,7 +1646,7 @@ func (s *Scanner) detectDNSVersionTCP(port int) string {
 		timeout = 1500 * time.Millisecond
 	}
 
-	conn, err := net.DialTimeout("tcp", address, timeout)
+	conn, err := s.dial("tcp", address, timeout)
 	if err != nil {
 		return ""
 	}
@@ -1608,7 +1779,7 @@ func (s *Scanner) detectMSSQLTDS(port int) bool {
 		timeout = 1200 * time.Millisecond
 	}
 
-	conn, err := net.DialTimeout("tcp", address, timeout)
+	conn, err := s.dial("tcp", address, timeout)
 	if err != nil {
 		return false
 	}
@@ -1641,7 +1812,7 @@ func (s *Scanner) detectRDPInfo(port int) (version, evidence string, ok bool) {
 	address := net.JoinHostPort(s.Host, fmt.Sprintf("%d", port))
 	timeout := s.boundedServiceTimeout(900*time.Millisecond, 1800*time.Millisecond)
 
-	conn, err := net.DialTimeout("tcp", address, timeout)
+	conn, err := s.dial("tcp", address, timeout)
 	if err != nil {
 		return "", "", false
 	}
@@ -1740,13 +1911,12 @@ func (s *Scanner) detectLDAPBind(port int, useTLS bool) bool {
 	)
 
 	if useTLS {
-		dialer := &net.Dialer{Timeout: timeout}
-		conn, err = tls.DialWithDialer(dialer, "tcp", address, &tls.Config{
+		conn, err = s.dialTLS(address, timeout, &tls.Config{
 			InsecureSkipVerify: true,
 			ServerName:         s.Host,
 		})
 	} else {
-		conn, err = net.DialTimeout("tcp", address, timeout)
+		conn, err = s.dial("tcp", address, timeout)
 	}
 	if err != nil {
 		return false
@@ -1783,13 +1953,12 @@ func (s *Scanner) detectWinRM(port int) (string, string) {
 		err  error
 	)
 	if port == 5986 {
-		dialer := &net.Dialer{Timeout: timeout}
-		conn, err = tls.DialWithDialer(dialer, "tcp", address, &tls.Config{
+		conn, err = s.dialTLS(address, timeout, &tls.Config{
 			InsecureSkipVerify: true,
 			ServerName:         s.Host,
 		})
 	} else {
-		conn, err = net.DialTimeout("tcp", address, timeout)
+		conn, err = s.dial("tcp", address, timeout)
 	}
 	if err != nil {
 		return "", ""
@@ -1858,10 +2027,6 @@ func (s *Scanner) detectSMBVersion(port int) (string, string) {
 		return rawSMB, "raw smb negotiate"
 	}
 
-	if smbLib := s.attemptSMBLibrary(address); smbLib != "" {
-		return smbLib, "smb library"
-	}
-
 	if port == 139 {
 		return "Microsoft Windows netbios-ssn", "NetBIOS session service on tcp/139"
 	}
@@ -1879,7 +2044,7 @@ func shouldUseTLSForHTTP(port int) bool {
 
 // attemptRawSMBDetection tries to detect SMB by reading raw response
 func (s *Scanner) attemptRawSMBDetection(address string) string {
-	conn, err := net.DialTimeout("tcp", address, s.Timeout)
+	conn, err := s.dial("tcp", address, s.Timeout)
 	if err != nil {
 		return ""
 	}
@@ -2014,28 +2179,10 @@ func (s *Scanner) extractSMB2Dialect(data []byte) string {
 	return "SMB 2.0+"
 }
 
-// attemptSMBLibrary tries to use SMB library for detection
-func (s *Scanner) attemptSMBLibrary(address string) string {
-	opts := smb.Options{
-		Host:     s.Host,
-		Port:     445,
-		User:     "",
-		Password: "",
-	}
-
-	session, err := smb.NewSession(opts, false)
-	if err == nil {
-		defer session.Close()
-		return "Microsoft Windows SMB"
-	}
-
-	return ""
-}
-
 func (s *Scanner) buildHTTPRequest(method, path string) string {
 	headers := []string{
 		fmt.Sprintf("%s %s HTTP/1.1", method, path),
-		"Host: " + s.Host,
+		"Host: " + httpHostHeader(s.Host),
 		"Connection: close",
 		"Accept: */*",
 		"User-Agent: " + s.httpUserAgent(),
@@ -2061,10 +2208,13 @@ func (s *Scanner) httpUserAgent() string {
 }
 
 func (s *Scanner) randomHeaderIP() string {
-	if !s.RandomIP || !s.targetPrefix.IsValid() || !s.targetPrefix.Addr().Is4() {
+	if !s.RandomIP || !s.targetPrefix.IsValid() {
 		return ""
 	}
 	p := s.targetPrefix.Masked()
+	if p.Addr().Is6() {
+		return randomIPv6InPrefix(p)
+	}
 	addr := p.Addr()
 	prefixBits := p.Bits()
 	if prefixBits >= 31 {
@@ -2081,6 +2231,30 @@ func (s *Scanner) randomHeaderIP() string {
 	return ip.String()
 }
 
+// randomIPv6InPrefix returns a random address inside p, avoiding the
+// all-zero host part (the subnet-router anycast address).
+func randomIPv6InPrefix(p netip.Prefix) string {
+	prefixBits := p.Bits()
+	if prefixBits >= 127 {
+		return ""
+	}
+	base := p.Addr().As16()
+	for {
+		out := base
+		for i := prefixBits / 8; i < 16; i++ {
+			b := byte(rand.IntN(256))
+			if i == prefixBits/8 && prefixBits%8 != 0 {
+				hostMask := byte(0xff) >> (prefixBits % 8)
+				b = base[i]&^hostMask | b&hostMask
+			}
+			out[i] = b
+		}
+		if out != base {
+			return netip.AddrFrom16(out).String()
+		}
+	}
+}
+
 func parseTargetPrefix(cidr, host string) netip.Prefix {
 	if cidr != "" {
 		if p, err := netip.ParsePrefix(cidr); err == nil {
@@ -2088,13 +2262,25 @@ func parseTargetPrefix(cidr, host string) netip.Prefix {
 		}
 	}
 	ip, err := netip.ParseAddr(host)
-	if err != nil || !ip.Is4() {
+	if err != nil {
 		return netip.Prefix{}
 	}
-	// Fallback approximation when scanning a single host.
+	ip = ip.WithZone("").Unmap()
+	// Fallback approximation when scanning a single host: the local /24 or /64.
+	if ip.Is6() {
+		return netip.PrefixFrom(ip, 64).Masked()
+	}
 	return netip.PrefixFrom(ip, 24).Masked()
 }
 
+// httpHostHeader brackets IPv6 literals for the HTTP Host header (RFC 7230) and drops any zone.
+func httpHostHeader(host string) string {
+	if addr, err := netip.ParseAddr(host); err == nil && addr.Is6() && !addr.Is4In6() {
+		return "[" + addr.WithZone("").String() + "]"
+	}
+	return host
+}
+
 func ip4ToUint(ip netip.Addr) uint32 {
 	b := ip.As4()
 	return uint32(b[0])<<24 | uint32(b[1])<<16 | uint32(b[2])<<8 | uint32(b[3])
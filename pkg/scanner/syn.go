@@ -1,217 +1,841 @@
 package scanner
 
 import (
+	"context"
 	"encoding/binary"
 	"errors"
 	"fmt"
+	"hash/maphash"
 	"math/rand/v2"
 	"net"
+	"net/netip"
 	"runtime"
 	"sort"
 	"strings"
+	"sync"
+	"syscall"
 	"time"
+
+	"golang.org/x/net/ipv4"
+	"golang.org/x/net/ipv6"
 )
 
 const (
+	tcpFlagFin = 0x01
 	tcpFlagSyn = 0x02
 	tcpFlagRst = 0x04
+	tcpFlagPsh = 0x08
 	tcpFlagAck = 0x10
+	tcpFlagUrg = 0x20
+)
+
+// Raw TCP scan techniques accepted in SYNConfig.Technique.
+const (
+	TechniqueSYN  = "syn"
+	TechniqueFIN  = "fin"
+	TechniqueNULL = "null"
+	TechniqueXmas = "xmas"
+	TechniqueACK  = "ack"
 )
 
-// SYNConfig contains runtime options for SYN discovery.
+// IsRawScanType reports whether scanType is one of the raw TCP techniques.
+func IsRawScanType(scanType string) bool {
+	switch scanType {
+	case TechniqueSYN, TechniqueFIN, TechniqueNULL, TechniqueXmas, TechniqueACK:
+		return true
+	}
+	return false
+}
+
+// SYNConfig contains runtime options for the raw TCP scan engine.
 type SYNConfig struct {
+	// Rate caps probes per second across all targets of one Scan call, not
+	// per host; 0 means unlimited.
 	Rate      int
 	Retries   int
 	GhostMode bool
+	// Technique selects the probe flags; empty means TechniqueSYN. FIN, NULL
+	// and Xmas scans report RST as closed and silence as open|filtered; ACK
+	// scans report RST as unfiltered and silence as filtered.
+	Technique string
+	// Source pins the interface, address and port probes are sent from. A
+	// pinned port replaces the per-probe random source ports.
+	Source SourceConfig
+	// Limiter, when set, caps the probe rate across every engine sharing it.
+	Limiter *RateLimiter
 }
 
 type tcpResponse struct {
 	srcPort int
 	dstPort int
+	seq     uint32
+	ack     uint32
 	flags   byte
 }
 
 // DiscoverOpenPortsSYN discovers open ports via native TCP SYN probes.
 // Requires root/CAP_NET_RAW privileges.
-func DiscoverOpenPortsSYN(host string, ports []int, cfg SYNConfig) ([]int, error) {
+func DiscoverOpenPortsSYN(ctx context.Context, host string, ports []int, cfg SYNConfig) ([]int, error) {
+	states, err := DiscoverPortStatesSYN(ctx, host, ports, cfg)
+	if err != nil {
+		return nil, err
+	}
+	openPorts := make([]int, 0, len(states))
+	for port, state := range states {
+		if state == PortOpen {
+			openPorts = append(openPorts, port)
+		}
+	}
+	sort.Ints(openPorts)
+	return openPorts, nil
+}
+
+// DiscoverPortStatesSYN probes ports with native TCP SYN packets and classifies
+// every port: SYN-ACK is open, RST is closed and silence after all retries is filtered.
+// cfg.Technique switches to FIN, NULL, Xmas or ACK probes and their states. If ctx is cancelled, the states answered so far are returned with ctx.Err().
+// Requires root/CAP_NET_RAW privileges.
+func DiscoverPortStatesSYN(ctx context.Context, host string, ports []int, cfg SYNConfig) (map[int]PortState, error) {
 	if len(ports) == 0 {
 		return nil, nil
 	}
-	if runtime.GOOS != "linux" {
-		return nil, errors.New("native syn scan currently supported on linux only")
+	s, err := NewSYNScanner(cfg)
+	if err != nil {
+		return nil, err
 	}
+	defer s.Close()
 
-	dstIP, err := resolveIPv4(host)
-	if err != nil {
+	results, err := s.Scan(ctx, []SYNTarget{{Host: host, Ports: ports}})
+	if results == nil {
 		return nil, err
 	}
-	srcIP, err := resolveSourceIPv4(dstIP)
+	if results[0].Err != nil {
+		return nil, results[0].Err
+	}
+	return results[0].States, err
+}
+
+// SYNTarget is one host of a multi-host SYN scan and the ports to probe on it.
+type SYNTarget struct {
+	Host  string
+	Ports []int
+}
+
+// SYNHostResult holds the port states found for one SYNTarget. Err is set when
+// the host could not be probed at all, for example because it does not resolve.
+type SYNHostResult struct {
+	States map[int]PortState
+	Err    error
+	// OS is guessed from the host's first SYN-ACK, or from the TTL alone when
+	// only resets came back. It is nil when the host never answered.
+	OS *OSGuess
+}
+
+// SYNScanner sends raw TCP probes (SYN by default, or the FIN, NULL, Xmas and
+// ACK techniques) for many hosts over one raw socket per address family. Each
+// probe gets its own source port, unless SYNConfig.Source pins one, and a
+// stateless cookie as its sequence number: a keyed hash of (dst IP, dst port,
+// src port). A reply counts
+// only if it acknowledges the cookie of the probe it answers, so stray or
+// spoofed segments to our ports are ignored.
+type SYNScanner struct {
+	cfg          SYNConfig
+	v4, v6       net.PacketConn
+	v4Err, v6Err error
+
+	mu     sync.Mutex
+	active *synScan
+}
+
+// synScan is the state of one Scan call, updated by the socket readers.
+type synScan struct {
+	// key is the secret hash seed for this scan's cookies.
+	key     maphash.Seed
+	hosts   map[netip.Addr]*synHost
+	pending int
+	settled chan struct{}
+}
+
+type synHost struct {
+	dst, src net.IP
+	conn     net.PacketConn
+	states   map[int]PortState
+	pending  map[int]struct{}
+	err      error
+	// fp is the fingerprint of the first SYN-ACK and ttl the TTL of the
+	// latest reply, for the OS guess.
+	fp  *tcpFingerprint
+	ttl int
+}
+
+// osGuess guesses the host's OS from its SYN-ACK, or from the TTL alone.
+func (h *synHost) osGuess() *OSGuess {
+	if h.fp != nil {
+		return guessOS(*h.fp)
+	}
+	return guessOSFromTTL(h.ttl)
+}
+
+// NewSYNScanner opens the raw sockets. It fails when neither IPv4 nor IPv6 raw
+// TCP sockets can be opened, which usually means missing privileges.
+func NewSYNScanner(cfg SYNConfig) (*SYNScanner, error) {
+	if cfg.Technique == "" {
+		cfg.Technique = TechniqueSYN
+	}
+	if !IsRawScanType(cfg.Technique) {
+		return nil, fmt.Errorf("unknown raw scan technique %q", cfg.Technique)
+	}
+	if runtime.GOOS != "linux" {
+		return nil, fmt.Errorf("native %s scan currently supported on linux only", cfg.Technique)
+	}
+	if cfg.Retries < 0 {
+		cfg.Retries = 0
+	}
+	s := &SYNScanner{cfg: cfg}
+	s.v4, s.v4Err = openSYNSocket("ip4:tcp", false, cfg)
+	s.v6, s.v6Err = openSYNSocket("ip6:tcp", true, cfg)
+	if s.v4 == nil && s.v6 == nil {
+		return nil, s.v4Err
+	}
+	for _, conn := range []net.PacketConn{s.v4, s.v6} {
+		if conn != nil {
+			go s.readLoop(conn)
+		}
+	}
+	return s, nil
+}
+
+func openSYNSocket(network string, v6 bool, cfg SYNConfig) (net.PacketConn, error) {
+	addr, err := cfg.Source.listenAddr(v6)
 	if err != nil {
 		return nil, err
 	}
-
-	conn, err := net.ListenPacket("ip4:tcp", srcIP.String())
+	conn, err := net.ListenPacket(network, addr)
 	if err != nil {
-		msg := strings.ToLower(err.Error())
-		if strings.Contains(msg, "operation not permitted") || strings.Contains(msg, "permission denied") {
-			return nil, errors.New("insufficient privileges for native syn scan")
+		if isPermissionError(err) {
+			return nil, fmt.Errorf("insufficient privileges for native %s scan", cfg.Technique)
 		}
 		return nil, fmt.Errorf("failed to open raw tcp socket: %w", err)
 	}
-	defer func() { _ = conn.Close() }()
+	if err := cfg.Source.bindPacketConn(conn); err != nil {
+		_ = conn.Close()
+		return nil, fmt.Errorf("failed to bind raw tcp socket to %s: %w", cfg.Source.Interface, err)
+	}
+	return conn, nil
+}
 
-	srcPort := 40000 + rand.IntN(20000)
-	timeoutPerRound := 650 * time.Millisecond
-	if cfg.GhostMode {
-		timeoutPerRound = 1200 * time.Millisecond
+func isPermissionError(err error) bool {
+	msg := strings.ToLower(err.Error())
+	return strings.Contains(msg, "operation not permitted") || strings.Contains(msg, "permission denied")
+}
+
+// Close releases the raw sockets.
+func (s *SYNScanner) Close() {
+	for _, conn := range []net.PacketConn{s.v4, s.v6} {
+		if conn != nil {
+			_ = conn.Close()
+		}
 	}
-	if cfg.Retries < 0 {
-		cfg.Retries = 0
+}
+
+// Scan probes every target's ports and returns one result per target, in the
+// same order. Probes are interleaved across hosts, and retries, the rate limit
+// and the reply wait are shared by the whole batch, so a /24 takes about as
+// long as a single host. If ctx is cancelled, the states answered so far are
+// returned with ctx.Err().
+func (s *SYNScanner) Scan(ctx context.Context, targets []SYNTarget) ([]SYNHostResult, error) {
+	scan := &synScan{
+		key:     maphash.MakeSeed(),
+		hosts:   make(map[netip.Addr]*synHost, len(targets)),
+		settled: make(chan struct{}),
+	}
+	bound := make([]*synHost, len(targets))
+	errs := make([]error, len(targets))
+	for i, t := range targets {
+		h, err := s.prepareHost(scan, t.Host)
+		if err != nil {
+			errs[i] = err
+			continue
+		}
+		for _, p := range t.Ports {
+			if _, known := h.states[p]; known {
+				continue
+			}
+			if _, queued := h.pending[p]; !queued {
+				h.pending[p] = struct{}{}
+				scan.pending++
+			}
+		}
+		bound[i] = h
+	}
+
+	s.mu.Lock()
+	s.active = scan
+	s.mu.Unlock()
+	defer func() {
+		s.mu.Lock()
+		s.active = nil
+		s.mu.Unlock()
+	}()
+
+	err := s.run(ctx, scan)
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	results := make([]SYNHostResult, len(targets))
+	for i, t := range targets {
+		h := bound[i]
+		if h == nil {
+			results[i].Err = errs[i]
+			continue
+		}
+		if h.err != nil {
+			results[i].Err = h.err
+			continue
+		}
+		states := make(map[int]PortState, len(t.Ports))
+		for _, p := range t.Ports {
+			if state, ok := h.states[p]; ok {
+				states[p] = state
+			} else if err == nil {
+				states[p] = s.silentState()
+			}
+		}
+		results[i].States = states
+		results[i].OS = h.osGuess()
 	}
+	return results, err
+}
 
-	targetPorts := dedupeSortedPorts(append([]int(nil), ports...))
-	sort.Ints(targetPorts)
-	openSet := make(map[int]struct{}, 16)
-	pending := make(map[int]struct{}, len(targetPorts))
-	for _, p := range targetPorts {
-		pending[p] = struct{}{}
+// prepareHost resolves host and registers it in scan. Hosts that resolve to
+// the same address share one entry.
+func (s *SYNScanner) prepareHost(scan *synScan, host string) (*synHost, error) {
+	dstIP, err := resolveSYNTarget(host)
+	if err != nil {
+		return nil, err
+	}
+	addr, _ := netip.AddrFromSlice(dstIP)
+	if h, ok := scan.hosts[addr]; ok {
+		return h, nil
+	}
+	conn, connErr := s.v4, s.v4Err
+	if addr.Is6() {
+		conn, connErr = s.v6, s.v6Err
 	}
+	if conn == nil {
+		return nil, connErr
+	}
+	srcIP, err := s.cfg.Source.sourceIP(dstIP)
+	if err != nil {
+		return nil, err
+	}
+	h := &synHost{
+		dst:     dstIP,
+		src:     srcIP,
+		conn:    conn,
+		states:  make(map[int]PortState),
+		pending: make(map[int]struct{}),
+	}
+	scan.hosts[addr] = h
+	return h, nil
+}
 
-	for attempt := 0; attempt <= cfg.Retries && len(pending) > 0; attempt++ {
-		batch := make([]int, 0, 64)
-		for p := range pending {
-			batch = append(batch, p)
+// run sends the probe rounds and waits for replies after each one.
+func (s *SYNScanner) run(ctx context.Context, scan *synScan) error {
+	timeoutPerRound := 650 * time.Millisecond
+	if s.cfg.GhostMode {
+		timeoutPerRound = 1200 * time.Millisecond
+	}
+	var interval time.Duration
+	if s.cfg.Rate > 0 {
+		interval = time.Second / time.Duration(s.cfg.Rate)
+		if interval < time.Millisecond {
+			interval = time.Millisecond
 		}
-		sort.Ints(batch)
+	}
 
-		for i, port := range batch {
-			if _, stillPending := pending[port]; !stillPending {
+	for attempt := 0; attempt <= s.cfg.Retries; attempt++ {
+		round := s.pendingRound(scan)
+		if len(round) == 0 {
+			return nil
+		}
+		for _, probe := range round {
+			if ctx.Err() != nil {
+				return ctx.Err()
+			}
+			s.mu.Lock()
+			_, stillPending := probe.host.pending[probe.port]
+			s.mu.Unlock()
+			if !stillPending {
 				continue
 			}
-			if err := sendTCPProbe(conn, srcIP, dstIP, srcPort, port, tcpFlagSyn); err != nil {
-				msg := strings.ToLower(err.Error())
-				if strings.Contains(msg, "operation not permitted") || strings.Contains(msg, "permission denied") {
-					return nil, errors.New("insufficient privileges for native syn scan")
-				}
-				return nil, fmt.Errorf("failed to send syn probe: %w", err)
+			if err := s.cfg.Limiter.Wait(ctx); err != nil {
+				return err
 			}
-			if cfg.Rate > 0 {
-				interval := time.Second / time.Duration(cfg.Rate)
-				if interval < time.Millisecond {
-					interval = time.Millisecond
+			srcPort := s.sourcePort()
+			seq := scan.cookie(probe.host.dst, probe.port, srcPort)
+			if err := sendTCPSegment(probe.host.conn, probe.host.src, probe.host.dst, srcPort, probe.port, seq, s.probeAck(seq), s.probeFlags(), s.probeOptions()); err != nil {
+				if isPermissionError(err) {
+					return fmt.Errorf("insufficient privileges for native %s scan", s.cfg.Technique)
+				}
+				if errors.Is(err, syscall.ENOBUFS) {
+					// The send queue is full: leave the port for the next round.
+					time.Sleep(time.Millisecond)
+					continue
 				}
+				s.dropHost(scan, probe.host, fmt.Errorf("failed to send syn probe: %w", err))
+				continue
+			}
+			if interval > 0 {
 				time.Sleep(interval)
 			}
-			// Drain responses incrementally to avoid socket buffer overflows on large scans.
-			if (i+1)%64 == 0 {
-				if err := collectSYNResponses(conn, srcPort, pending, openSet, 220*time.Millisecond); err != nil {
-					return nil, err
-				}
+		}
+
+		timer := time.NewTimer(timeoutPerRound)
+		select {
+		case <-scan.settled:
+		case <-timer.C:
+		case <-ctx.Done():
+		}
+		timer.Stop()
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+	}
+	return nil
+}
+
+type synProbe struct {
+	host *synHost
+	port int
+}
+
+// pendingRound lists the unanswered probes, taking one port per host in turn
+// so no single host receives a burst.
+func (s *SYNScanner) pendingRound(scan *synScan) []synProbe {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	hosts := make([]*synHost, 0, len(scan.hosts))
+	perHost := make([][]int, 0, len(scan.hosts))
+	for _, h := range scan.hosts {
+		if len(h.pending) == 0 {
+			continue
+		}
+		ports := make([]int, 0, len(h.pending))
+		for p := range h.pending {
+			ports = append(ports, p)
+		}
+		sort.Ints(ports)
+		hosts = append(hosts, h)
+		perHost = append(perHost, ports)
+	}
+	// Map order is random; sort for a stable interleave.
+	order := make([]int, len(hosts))
+	for i := range order {
+		order[i] = i
+	}
+	sort.Slice(order, func(a, b int) bool {
+		return bytesLess(hosts[order[a]].dst, hosts[order[b]].dst)
+	})
+
+	var round []synProbe
+	for i := 0; ; i++ {
+		added := false
+		for _, hi := range order {
+			if i < len(perHost[hi]) {
+				round = append(round, synProbe{host: hosts[hi], port: perHost[hi][i]})
+				added = true
 			}
 		}
+		if !added {
+			return round
+		}
+	}
+}
 
-		if err := collectSYNResponses(conn, srcPort, pending, openSet, timeoutPerRound); err != nil {
-			return nil, err
+func bytesLess(a, b net.IP) bool {
+	if len(a) != len(b) {
+		return len(a) < len(b)
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return a[i] < b[i]
 		}
 	}
+	return false
+}
 
-	openPorts := make([]int, 0, len(openSet))
-	for p := range openSet {
-		openPorts = append(openPorts, p)
+// dropHost gives up on a host whose probes cannot be sent.
+func (s *SYNScanner) dropHost(scan *synScan, h *synHost, err error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	h.err = err
+	for p := range h.pending {
+		delete(h.pending, p)
+		scan.settle()
 	}
-	sort.Ints(openPorts)
-	return openPorts, nil
 }
 
-func collectSYNResponses(conn net.PacketConn, srcPort int, pending map[int]struct{}, openSet map[int]struct{}, wait time.Duration) error {
-	deadline := time.Now().Add(wait)
-	for time.Now().Before(deadline) {
-		remaining := time.Until(deadline)
-		if remaining <= 0 {
-			break
+// Probes use a random source port from this range, so retries and hosts do
+// not share one port.
+const (
+	synSourcePortBase  = 40000
+	synSourcePortCount = 20000
+)
+
+// sourcePort returns the source port of the next probe: the pinned one, or a
+// random port from the probe range.
+func (s *SYNScanner) sourcePort() int {
+	if s.cfg.Source.Port != 0 {
+		return s.cfg.Source.Port
+	}
+	return synSourcePortBase + rand.IntN(synSourcePortCount)
+}
+
+// ownsPort reports whether replies to local port belong to this scanner.
+func (s *SYNScanner) ownsPort(port int) bool {
+	if s.cfg.Source.Port != 0 {
+		return port == s.cfg.Source.Port
+	}
+	return port >= synSourcePortBase && port < synSourcePortBase+synSourcePortCount
+}
+
+// probeFlags returns the TCP flags sent by the scan technique.
+func (s *SYNScanner) probeFlags() byte {
+	switch s.cfg.Technique {
+	case TechniqueFIN:
+		return tcpFlagFin
+	case TechniqueNULL:
+		return 0
+	case TechniqueXmas:
+		return tcpFlagFin | tcpFlagPsh | tcpFlagUrg
+	case TechniqueACK:
+		return tcpFlagAck
+	default:
+		return tcpFlagSyn
+	}
+}
+
+// probeAck returns the acknowledgement number of the probe whose sequence
+// number is cookie. Only ACK probes carry one: the cookie again, which the
+// target's RST echoes back as its sequence number. Other probes leave the
+// field zero, as a stack does while the ACK flag is clear.
+func (s *SYNScanner) probeAck(cookie uint32) uint32 {
+	if s.probeFlags()&tcpFlagAck != 0 {
+		return cookie
+	}
+	return 0
+}
+
+// probeOptions returns the TCP options sent by the scan technique. SYN probes
+// look like a regular connection attempt so the SYN-ACK shows the target's
+// option layout for OS guessing.
+func (s *SYNScanner) probeOptions() []byte {
+	if s.cfg.Technique != TechniqueSYN {
+		return nil
+	}
+	return synProbeOptions(uint32(time.Now().UnixMilli()))
+}
+
+// classify maps reply flags to a port state for the scan technique. SYN scans
+// read SYN-ACK as open and RST as closed. FIN, NULL and Xmas probes are
+// answered with RST only by closed ports (RFC 793). ACK probes draw a RST from
+// open and closed ports alike, so RST only proves the port is unfiltered.
+func (s *SYNScanner) classify(flags byte) (PortState, bool) {
+	rst := flags&tcpFlagRst != 0
+	switch s.cfg.Technique {
+	case TechniqueSYN:
+		if flags&(tcpFlagSyn|tcpFlagAck) == tcpFlagSyn|tcpFlagAck {
+			return PortOpen, true
 		}
-		if remaining > 150*time.Millisecond {
-			remaining = 150 * time.Millisecond
+		if rst {
+			return PortClosed, true
 		}
-		resp, ok, readErr := readTCPResponse(conn, remaining)
-		if readErr != nil {
-			if ne, ok := readErr.(net.Error); ok && ne.Timeout() {
-				continue
+	case TechniqueACK:
+		if rst {
+			return PortUnfiltered, true
+		}
+	default:
+		if rst {
+			return PortClosed, true
+		}
+	}
+	return "", false
+}
+
+// silentState is the state of ports that never answered: open|filtered for
+// FIN, NULL and Xmas scans, where open ports stay silent, filtered otherwise.
+func (s *SYNScanner) silentState() PortState {
+	switch s.cfg.Technique {
+	case TechniqueFIN, TechniqueNULL, TechniqueXmas:
+		return PortOpenFiltered
+	default:
+		return PortFiltered
+	}
+}
+
+// acknowledges reports whether resp answers the probe whose sequence number
+// was cookie. A reply to a segment without ACK acknowledges its sequence
+// number plus its length, one for each SYN or FIN flag; a RST to a segment
+// with ACK takes that segment's acknowledgement number as its own sequence
+// number.
+func (s *SYNScanner) acknowledges(resp tcpResponse, cookie uint32) bool {
+	flags := s.probeFlags()
+	if flags&tcpFlagAck != 0 {
+		return resp.seq == cookie
+	}
+	length := uint32(0)
+	if flags&tcpFlagSyn != 0 {
+		length++
+	}
+	if flags&tcpFlagFin != 0 {
+		length++
+	}
+	return resp.ack == cookie+length
+}
+
+// cookie is the initial sequence number of the probe from srcPort to dst:dstPort.
+func (scan *synScan) cookie(dst net.IP, dstPort, srcPort int) uint32 {
+	var h maphash.Hash
+	h.SetSeed(scan.key)
+	addr, _ := netip.AddrFromSlice(dst)
+	a16 := addr.Unmap().As16()
+	_, _ = h.Write(a16[:])
+	var ports [4]byte
+	binary.BigEndian.PutUint16(ports[0:2], uint16(dstPort))
+	binary.BigEndian.PutUint16(ports[2:4], uint16(srcPort))
+	_, _ = h.Write(ports[:])
+	return uint32(h.Sum64())
+}
+
+// settle counts one answered probe; callers hold the scanner lock.
+func (scan *synScan) settle() {
+	scan.pending--
+	if scan.pending == 0 {
+		close(scan.settled)
+	}
+}
+
+// readLoop classifies replies for the active scan (see classify). Replies must
+// come from a probed host and port and acknowledge the cookie of the probe
+// they answer. Every verified SYN-ACK,
+// retransmissions included, is answered with a RST whose sequence number is
+// the acknowledged cookie+1, so no half-open state is left on the target.
+// The first SYN-ACK of each host is kept for the OS guess.
+func (s *SYNScanner) readLoop(conn net.PacketConn) {
+	read := newTCPSegmentReader(conn)
+	buf := make([]byte, 4096)
+	for {
+		n, ttl, peer, err := read(buf)
+		if err != nil {
+			if errors.Is(err, net.ErrClosed) {
+				return
 			}
-			return fmt.Errorf("failed to read syn response: %w", readErr)
+			continue
 		}
-		if !ok || resp.dstPort != srcPort {
+		resp, ok, _ := parseTCPSegment(buf[:n])
+		if !ok || !s.ownsPort(resp.dstPort) {
 			continue
 		}
-		if _, exists := pending[resp.srcPort]; !exists {
+		ipAddr, isIP := peer.(*net.IPAddr)
+		if !isIP {
 			continue
 		}
-		if resp.flags&tcpFlagSyn != 0 && resp.flags&tcpFlagAck != 0 {
-			openSet[resp.srcPort] = struct{}{}
-			delete(pending, resp.srcPort)
+		addr, valid := netip.AddrFromSlice(ipAddr.IP)
+		if !valid {
 			continue
 		}
-		if resp.flags&tcpFlagRst != 0 {
-			delete(pending, resp.srcPort)
+		addr = addr.Unmap()
+
+		state, answers := s.classify(resp.flags)
+		if !answers {
+			continue
+		}
+
+		var resetFrom *synHost
+		s.mu.Lock()
+		if scan := s.active; scan != nil && s.acknowledges(resp, scan.cookie(ipAddr.IP, resp.srcPort, resp.dstPort)) {
+			if h, known := scan.hosts[addr]; known {
+				if ttl > 0 {
+					h.ttl = ttl
+				}
+				if state == PortOpen && h.fp == nil {
+					if fp, ok := fingerprintSegment(buf[:n], ttl); ok {
+						h.fp = &fp
+					}
+				}
+				if _, waiting := h.pending[resp.srcPort]; waiting {
+					h.states[resp.srcPort] = state
+					delete(h.pending, resp.srcPort)
+					scan.settle()
+				}
+				if state == PortOpen {
+					resetFrom = h
+				}
+			}
+		}
+		s.mu.Unlock()
+		if resetFrom != nil {
+			// Tear the half-open connection down ourselves instead of relying on
+			// the kernel, whose RST local firewall rules may drop.
+			_ = sendTCPSegment(conn, resetFrom.src, resetFrom.dst, resp.dstPort, resp.srcPort, resp.ack, 0, tcpFlagRst, nil)
 		}
 	}
-	return nil
+}
+
+// tcpSegmentReader reads one TCP segment, without IP header, from a raw
+// socket and returns the TTL or hop limit it arrived with, 0 if unknown.
+type tcpSegmentReader func(b []byte) (n, ttl int, peer net.Addr, err error)
+
+// newTCPSegmentReader reads the TTL through IP_RECVTTL (IPV6_RECVHOPLIMIT for
+// IPv6) control messages. Where those cannot be enabled it falls back to
+// plain reads, which deliver the segment without its TTL.
+func newTCPSegmentReader(conn net.PacketConn) tcpSegmentReader {
+	if isIPv6Conn(conn) {
+		p := ipv6.NewPacketConn(conn)
+		if p.SetControlMessage(ipv6.FlagHopLimit, true) == nil {
+			return func(b []byte) (int, int, net.Addr, error) {
+				n, cm, peer, err := p.ReadFrom(b)
+				if cm == nil {
+					return n, 0, peer, err
+				}
+				return n, cm.HopLimit, peer, err
+			}
+		}
+	} else {
+		p := ipv4.NewPacketConn(conn)
+		if p.SetControlMessage(ipv4.FlagTTL, true) == nil {
+			return func(b []byte) (int, int, net.Addr, error) {
+				n, cm, peer, err := p.ReadFrom(b)
+				if cm == nil {
+					return n, 0, peer, err
+				}
+				return n, cm.TTL, peer, err
+			}
+		}
+	}
+	// Go strips the IPv4 header from raw reads, and raw IPv6 sockets never
+	// deliver one.
+	return func(b []byte) (int, int, net.Addr, error) {
+		n, peer, err := conn.ReadFrom(b)
+		return n, 0, peer, err
+	}
 }
 
 // BuildResultsFromKnownOpenPorts builds scan results from a pre-discovered open port list.
-func BuildResultsFromKnownOpenPorts(s *Scanner, openPorts []int, detectServices bool) []ScanResult {
+// If ctx is cancelled during service detection, ports that were not fingerprinted
+// are still returned with port-map results. Every port is passed to the
+// OnResult hook once, whether or not detection ran.
+func BuildResultsFromKnownOpenPorts(ctx context.Context, s *Scanner, openPorts []int, detectServices bool) []ScanResult {
 	if len(openPorts) == 0 {
 		return nil
 	}
 	sort.Ints(openPorts)
 	openPorts = dedupeSortedPorts(openPorts)
 
-	if detectServices {
-		return s.Scan(openPorts, true)
+	if detectServices && ctx.Err() == nil {
+		results := s.Scan(ctx, openPorts, true)
+		if ctx.Err() == nil {
+			return results
+		}
+		detected := make(map[int]struct{}, len(results))
+		for _, r := range results {
+			detected[r.Port] = struct{}{}
+		}
+		for _, port := range openPorts {
+			if _, ok := detected[port]; !ok {
+				r := synPortMapResult(s, port)
+				s.emitResult(r)
+				results = append(results, r)
+			}
+		}
+		sort.Slice(results, func(i, j int) bool { return results[i].Port < results[j].Port })
+		return results
 	}
 
 	results := make([]ScanResult, 0, len(openPorts))
 	for _, port := range openPorts {
-		r := ScanResult{
-			Port:        port,
-			IsOpen:      true,
-			ServiceName: s.PortManager.GetServiceName(port, ""),
+		r := synPortMapResult(s, port)
+		s.emitResult(r)
+		results = append(results, r)
+	}
+	return results
+}
+
+func synPortMapResult(s *Scanner, port int) ScanResult {
+	r := ScanResult{
+		Port:        port,
+		IsOpen:      true,
+		State:       PortOpen,
+		ServiceName: s.PortManager.GetServiceName(port, ""),
+	}
+	if r.ServiceName != "" {
+		r.Confidence = "low"
+		r.Evidence = "syn+port map"
+		r.DetectionPath = "syn+portmap"
+	}
+	return r
+}
+
+// BuildResultsFromPortStates builds scan results from raw-engine port states.
+// Open ports go through BuildResultsFromKnownOpenPorts; other states are kept
+// only when the scanner is configured to show them.
+func BuildResultsFromPortStates(ctx context.Context, s *Scanner, states map[int]PortState, detectServices bool) []ScanResult {
+	openPorts := make([]int, 0, len(states))
+	var results []ScanResult
+	for port, state := range states {
+		if state == PortOpen {
+			openPorts = append(openPorts, port)
+			continue
 		}
-		if r.ServiceName != "" {
-			r.Confidence = "low"
-			r.Evidence = "syn+port map"
-			r.DetectionPath = "syn+portmap"
+		r := ScanResult{Port: port, State: state}
+		s.emitResult(r)
+		if s.reportsState(state) {
+			results = append(results, r)
 		}
-		results = append(results, r)
 	}
+	results = append(results, BuildResultsFromKnownOpenPorts(ctx, s, openPorts, detectServices)...)
+	sort.Slice(results, func(i, j int) bool { return results[i].Port < results[j].Port })
 	return results
 }
 
-func sendTCPProbe(conn net.PacketConn, srcIP, dstIP net.IP, srcPort, dstPort int, flags byte) error {
-	seq := rand.Uint32()
-	hdr := buildTCPHeader(srcIP, dstIP, srcPort, dstPort, seq, flags)
+func sendTCPSegment(conn net.PacketConn, srcIP, dstIP net.IP, srcPort, dstPort int, seq, ack uint32, flags byte, options []byte) error {
+	hdr := buildTCPSegment(srcIP, dstIP, srcPort, dstPort, seq, ack, flags, options)
 	_, err := conn.WriteTo(hdr, &net.IPAddr{IP: dstIP})
 	return err
 }
 
 func buildTCPHeader(srcIP, dstIP net.IP, srcPort, dstPort int, seq uint32, flags byte) []byte {
-	hdr := make([]byte, 20)
+	return buildTCPSegment(srcIP, dstIP, srcPort, dstPort, seq, 0, flags, nil)
+}
+
+// buildTCPSegment builds a TCP header with its checksum. options must be
+// padded to a multiple of four bytes.
+func buildTCPSegment(srcIP, dstIP net.IP, srcPort, dstPort int, seq, ack uint32, flags byte, options []byte) []byte {
+	hdr := make([]byte, 20+len(options))
 	binary.BigEndian.PutUint16(hdr[0:2], uint16(srcPort))
 	binary.BigEndian.PutUint16(hdr[2:4], uint16(dstPort))
 	binary.BigEndian.PutUint32(hdr[4:8], seq)
-	binary.BigEndian.PutUint32(hdr[8:12], 0)
-	hdr[12] = 5 << 4 // data offset
+	binary.BigEndian.PutUint32(hdr[8:12], ack)
+	hdr[12] = byte(len(hdr)/4) << 4 // data offset
 	hdr[13] = flags
 	binary.BigEndian.PutUint16(hdr[14:16], 64240) // window size
 	// checksum in [16:18]
 	binary.BigEndian.PutUint16(hdr[18:20], 0)
+	copy(hdr[20:], options)
 
-	sum := tcpChecksum(srcIP.To4(), dstIP.To4(), hdr)
+	sum := tcpChecksum(srcIP, dstIP, hdr)
 	binary.BigEndian.PutUint16(hdr[16:18], sum)
 	return hdr
 }
 
+// tcpChecksum computes the TCP checksum over the IPv4 or IPv6 pseudo-header.
+// Linux raw IPv6 sockets do not fill in the TCP checksum, so both families are built here.
 func tcpChecksum(srcIP, dstIP net.IP, tcpHdr []byte) uint16 {
+	src4, dst4 := srcIP.To4(), dstIP.To4()
+	if src4 == nil || dst4 == nil {
+		return tcpChecksumIPv6(srcIP.To16(), dstIP.To16(), tcpHdr)
+	}
+	srcIP, dstIP = src4, dst4
 	pseudo := make([]byte, 12+len(tcpHdr))
 	copy(pseudo[0:4], srcIP)
 	copy(pseudo[4:8], dstIP)
@@ -222,6 +846,18 @@ func tcpChecksum(srcIP, dstIP net.IP, tcpHdr []byte) uint16 {
 	return checksum16(pseudo)
 }
 
+// tcpChecksumIPv6 uses the RFC 8200 pseudo-header: source, destination,
+// upper-layer length, three zero bytes and the next-header value.
+func tcpChecksumIPv6(srcIP, dstIP net.IP, tcpHdr []byte) uint16 {
+	pseudo := make([]byte, 40+len(tcpHdr))
+	copy(pseudo[0:16], srcIP)
+	copy(pseudo[16:32], dstIP)
+	binary.BigEndian.PutUint32(pseudo[32:36], uint32(len(tcpHdr)))
+	pseudo[39] = 6 // TCP
+	copy(pseudo[40:], tcpHdr)
+	return checksum16(pseudo)
+}
+
 func checksum16(data []byte) uint16 {
 	var sum uint32
 	for i := 0; i+1 < len(data); i += 2 {
@@ -236,17 +872,6 @@ func checksum16(data []byte) uint16 {
 	return ^uint16(sum)
 }
 
-func readTCPResponse(conn net.PacketConn, timeout time.Duration) (tcpResponse, bool, error) {
-	var resp tcpResponse
-	_ = conn.SetReadDeadline(time.Now().Add(timeout))
-	buf := make([]byte, 4096)
-	n, _, err := conn.ReadFrom(buf)
-	if err != nil {
-		return resp, false, err
-	}
-	return parseTCPResponsePacket(buf[:n])
-}
-
 func parseTCPResponsePacket(pkt []byte) (tcpResponse, bool, error) {
 	var resp tcpResponse
 	n := len(pkt)
@@ -265,20 +890,36 @@ func parseTCPResponsePacket(pkt []byte) (tcpResponse, bool, error) {
 	if offset+20 > n {
 		return resp, false, nil
 	}
-	resp.srcPort = int(binary.BigEndian.Uint16(pkt[offset : offset+2]))
-	resp.dstPort = int(binary.BigEndian.Uint16(pkt[offset+2 : offset+4]))
-	resp.flags = pkt[offset+13]
+	return parseTCPSegment(pkt[offset:])
+}
+
+func isIPv6Conn(conn net.PacketConn) bool {
+	addr, ok := conn.LocalAddr().(*net.IPAddr)
+	return ok && addr.IP.To4() == nil
+}
+
+// parseTCPSegment reads ports and flags from a bare TCP segment.
+func parseTCPSegment(seg []byte) (tcpResponse, bool, error) {
+	var resp tcpResponse
+	if len(seg) < 20 {
+		return resp, false, nil
+	}
+	resp.srcPort = int(binary.BigEndian.Uint16(seg[0:2]))
+	resp.dstPort = int(binary.BigEndian.Uint16(seg[2:4]))
+	resp.seq = binary.BigEndian.Uint32(seg[4:8])
+	resp.ack = binary.BigEndian.Uint32(seg[8:12])
+	resp.flags = seg[13]
 	return resp, true, nil
 }
 
-func resolveIPv4(host string) (net.IP, error) {
-	ip := net.ParseIP(host)
-	if ip != nil {
-		ip = ip.To4()
-		if ip == nil {
-			return nil, fmt.Errorf("target %s is not ipv4", host)
+// resolveSYNTarget returns the raw-socket destination for host: a 4-byte IP for
+// IPv4 targets and a 16-byte IP for IPv6 targets.
+func resolveSYNTarget(host string) (net.IP, error) {
+	if addr, err := netip.ParseAddr(host); err == nil {
+		if addr.Zone() != "" {
+			return nil, fmt.Errorf("scoped ipv6 target %s is not supported by the syn engine", host)
 		}
-		return ip, nil
+		return net.IP(addr.Unmap().AsSlice()), nil
 	}
 
 	ips, err := net.LookupIP(host)
@@ -290,12 +931,21 @@ func resolveIPv4(host string) (net.IP, error) {
 			return v4, nil
 		}
 	}
-	return nil, fmt.Errorf("no ipv4 address found for %s", host)
+	if len(ips) > 0 {
+		return ips[0].To16(), nil
+	}
+	return nil, fmt.Errorf("no address found for %s", host)
 }
 
-func resolveSourceIPv4(dstIP net.IP) (net.IP, error) {
+// routeSourceIP picks the local address d would send to dstIP from, by
+// connecting a UDP socket, which sends nothing.
+func routeSourceIP(d *net.Dialer, dstIP net.IP) (net.IP, error) {
+	network := "udp4"
+	if dstIP.To4() == nil {
+		network = "udp6"
+	}
 	dstAddr := net.JoinHostPort(dstIP.String(), "80")
-	c, err := net.Dial("udp4", dstAddr)
+	c, err := d.Dial(network, dstAddr)
 	if err != nil {
 		return nil, err
 	}
@@ -304,13 +954,16 @@ func resolveSourceIPv4(dstIP net.IP) (net.IP, error) {
 	local := c.LocalAddr()
 	udpAddr, ok := local.(*net.UDPAddr)
 	if !ok || udpAddr.IP == nil {
-		return nil, errors.New("cannot determine local ipv4 address")
+		return nil, errors.New("cannot determine local source address")
 	}
-	v4 := udpAddr.IP.To4()
-	if v4 == nil {
-		return nil, errors.New("cannot determine local ipv4 address")
+	if network == "udp4" {
+		v4 := udpAddr.IP.To4()
+		if v4 == nil {
+			return nil, errors.New("cannot determine local ipv4 address")
+		}
+		return v4, nil
 	}
-	return v4, nil
+	return udpAddr.IP.To16(), nil
 }
 
 func dedupeSortedPorts(ports []int) []int {
@@ -1,10 +1,16 @@
 package scanner
 
 import (
+	"context"
 	"encoding/binary"
+	"hash/maphash"
 	"net"
 	"reflect"
+	"strings"
 	"testing"
+	"time"
+
+	"github.com/NexusFireMan/gomap/v2/internal/testutil"
 )
 
 func TestBuildTCPHeaderFields(t *testing.T) {
@@ -43,7 +49,7 @@ func TestDedupeSortedPorts(t *testing.T) {
 
 func TestBuildResultsFromKnownOpenPortsWithoutServices(t *testing.T) {
 	s := NewScanner("127.0.0.1", false)
-	got := BuildResultsFromKnownOpenPorts(s, []int{445, 22, 445}, false)
+	got := BuildResultsFromKnownOpenPorts(context.Background(), s, []int{445, 22, 445}, false)
 	if len(got) != 2 {
 		t.Fatalf("expected 2 results, got %d", len(got))
 	}
@@ -55,6 +61,22 @@ func TestBuildResultsFromKnownOpenPortsWithoutServices(t *testing.T) {
 	}
 }
 
+func TestBuildResultsFromKnownOpenPortsEmitsAfterInterrupt(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	for _, detect := range []bool{false, true} {
+		var emitted []int
+		s := NewScanner("127.0.0.1", false)
+		s.Configure(ScanConfig{OnResult: func(r ScanResult) { emitted = append(emitted, r.Port) }})
+		got := BuildResultsFromKnownOpenPorts(ctx, s, []int{445, 22}, detect)
+		// The raw engine proved these ports open, so they must reach streams
+		// and checkpoints even though detection never ran.
+		if len(got) != 2 || !reflect.DeepEqual(emitted, []int{22, 445}) {
+			t.Fatalf("detect=%v: got %+v, emitted %v", detect, got, emitted)
+		}
+	}
+}
+
 func TestParseTCPResponsePacketTCPOnly(t *testing.T) {
 	pkt := make([]byte, 20)
 	binary.BigEndian.PutUint16(pkt[0:2], 445)
@@ -100,3 +122,205 @@ func TestParseTCPResponsePacketIPv4PlusTCP(t *testing.T) {
 		t.Fatalf("unexpected flags: %#x", resp.flags)
 	}
 }
+
+func TestBuildTCPHeaderIPv6Checksum(t *testing.T) {
+	src := net.ParseIP("fd00:11::11")
+	dst := net.ParseIP("fd00:11::6")
+	hdr := buildTCPHeader(src, dst, 40123, 445, 0x11223344, tcpFlagSyn)
+
+	// A correct checksum makes the one's-complement sum over pseudo-header and segment zero.
+	pseudo := make([]byte, 40+len(hdr))
+	copy(pseudo[0:16], src.To16())
+	copy(pseudo[16:32], dst.To16())
+	binary.BigEndian.PutUint32(pseudo[32:36], uint32(len(hdr)))
+	pseudo[39] = 6
+	copy(pseudo[40:], hdr)
+	if got := checksum16(pseudo); got != 0 {
+		t.Fatalf("ipv6 checksum does not verify: residual %#x", got)
+	}
+
+	// The IPv4 pseudo-header must give a different checksum for the same segment.
+	v4 := buildTCPHeader(net.ParseIP("10.0.11.11"), net.ParseIP("10.0.11.6"), 40123, 445, 0x11223344, tcpFlagSyn)
+	if binary.BigEndian.Uint16(v4[16:18]) == binary.BigEndian.Uint16(hdr[16:18]) {
+		t.Fatal("expected ipv4 and ipv6 checksums to differ")
+	}
+}
+
+func TestResolveSYNTargetFamilies(t *testing.T) {
+	v4, err := resolveSYNTarget("10.0.11.6")
+	if err != nil || len(v4) != net.IPv4len {
+		t.Fatalf("expected 4-byte ipv4 target, got %v (%v)", v4, err)
+	}
+	v6, err := resolveSYNTarget("fd00:11::6")
+	if err != nil || len(v6) != net.IPv6len || v6.To4() != nil {
+		t.Fatalf("expected 16-byte ipv6 target, got %v (%v)", v6, err)
+	}
+	if _, err := resolveSYNTarget("fe80::1%eth0"); err == nil {
+		t.Fatal("expected scoped ipv6 target to be rejected")
+	}
+}
+
+func TestSYNScannerScansManyHostsTogether(t *testing.T) {
+	open := testutil.StartService(t, nil)
+
+	s, err := NewSYNScanner(SYNConfig{})
+	if err != nil {
+		t.Skipf("raw sockets unavailable: %v", err)
+	}
+	defer s.Close()
+
+	targets := []SYNTarget{
+		{Host: "127.0.0.1", Ports: []int{open, 1}},
+		{Host: "127.0.0.2", Ports: []int{open}},
+		{Host: "127.0.0.3", Ports: []int{2, 3}},
+		{Host: "bad host name", Ports: []int{1}},
+	}
+	start := time.Now()
+	results, err := s.Scan(context.Background(), targets)
+	if err != nil {
+		t.Fatalf("scan: %v", err)
+	}
+	// Every loopback port answers at once, so no host waits out a reply timeout.
+	if elapsed := time.Since(start); elapsed > 500*time.Millisecond {
+		t.Fatalf("batched scan took %s", elapsed)
+	}
+	want := []map[int]PortState{
+		{open: PortOpen, 1: PortClosed},
+		{open: PortClosed},
+		{2: PortClosed, 3: PortClosed},
+	}
+	for i, w := range want {
+		if results[i].Err != nil || !reflect.DeepEqual(results[i].States, w) {
+			t.Fatalf("%s: got %+v, want %v", targets[i].Host, results[i], w)
+		}
+	}
+	if results[3].Err == nil {
+		t.Fatal("expected an error for the unresolvable host")
+	}
+}
+
+func TestSYNCookieBindsProbe(t *testing.T) {
+	scan := &synScan{key: maphash.MakeSeed()}
+	dst := net.ParseIP("10.0.11.6").To4()
+	c := scan.cookie(dst, 445, 40123)
+	if scan.cookie(net.ParseIP("10.0.11.6"), 445, 40123) != c {
+		t.Fatal("4-byte and 16-byte forms of one address must give the same cookie")
+	}
+	for name, other := range map[string]uint32{
+		"dst ip":   scan.cookie(net.ParseIP("10.0.11.7").To4(), 445, 40123),
+		"dst port": scan.cookie(dst, 139, 40123),
+		"src port": scan.cookie(dst, 445, 40124),
+		"scan key": (&synScan{key: maphash.MakeSeed()}).cookie(dst, 445, 40123),
+	} {
+		if other == c {
+			t.Fatalf("changing the %s should change the cookie", name)
+		}
+	}
+}
+
+func TestSYNScannerStealthTechniques(t *testing.T) {
+	open := testutil.StartService(t, nil)
+
+	cases := []struct {
+		technique    string
+		open, closed PortState
+	}{
+		// Open ports ignore FIN, NULL and Xmas probes; only closed ones answer with RST.
+		{TechniqueFIN, PortOpenFiltered, PortClosed},
+		{TechniqueNULL, PortOpenFiltered, PortClosed},
+		{TechniqueXmas, PortOpenFiltered, PortClosed},
+		// Open and closed ports both reset an unexpected ACK.
+		{TechniqueACK, PortUnfiltered, PortUnfiltered},
+	}
+	for _, tc := range cases {
+		s, err := NewSYNScanner(SYNConfig{Technique: tc.technique})
+		if err != nil {
+			t.Skipf("raw sockets unavailable: %v", err)
+		}
+		results, err := s.Scan(context.Background(), []SYNTarget{{Host: "127.0.0.1", Ports: []int{open, 1}}})
+		s.Close()
+		if err != nil {
+			t.Fatalf("%s: scan: %v", tc.technique, err)
+		}
+		want := map[int]PortState{open: tc.open, 1: tc.closed}
+		if !reflect.DeepEqual(results[0].States, want) {
+			t.Fatalf("%s: got %v, want %v", tc.technique, results[0].States, want)
+		}
+	}
+}
+
+func TestSYNScannerProbeAcknowledgement(t *testing.T) {
+	const cookie = 0x9e3779b9
+	for _, tc := range []struct {
+		technique string
+		probeAck  uint32
+		reply     tcpResponse
+	}{
+		{TechniqueSYN, 0, tcpResponse{seq: 7, ack: cookie + 1}},
+		{TechniqueFIN, 0, tcpResponse{ack: cookie + 1}},
+		{TechniqueXmas, 0, tcpResponse{ack: cookie + 1}},
+		{TechniqueNULL, 0, tcpResponse{ack: cookie}},
+		{TechniqueACK, cookie, tcpResponse{seq: cookie}},
+	} {
+		s := &SYNScanner{cfg: SYNConfig{Technique: tc.technique}}
+		if got := s.probeAck(cookie); got != tc.probeAck {
+			t.Fatalf("%s: probe carries ack %#x, want %#x", tc.technique, got, tc.probeAck)
+		}
+		if !s.acknowledges(tc.reply, cookie) {
+			t.Fatalf("%s: reply %+v should match the probe", tc.technique, tc.reply)
+		}
+		if s.acknowledges(tc.reply, cookie+2) {
+			t.Fatalf("%s: a reply to another probe was accepted", tc.technique)
+		}
+	}
+}
+
+func TestSYNScannerGuessesOS(t *testing.T) {
+	open := testutil.StartService(t, nil)
+
+	s, err := NewSYNScanner(SYNConfig{})
+	if err != nil {
+		t.Skipf("raw sockets unavailable: %v", err)
+	}
+	defer s.Close()
+	results, err := s.Scan(context.Background(), []SYNTarget{{Host: "127.0.0.1", Ports: []int{open}}, {Host: "127.0.0.2", Ports: []int{1}}})
+	if err != nil {
+		t.Fatalf("scan: %v", err)
+	}
+	// The SYN-ACK from the local Linux stack echoes the probe's options.
+	g := results[0].OS
+	if g == nil || !strings.HasPrefix(g.Name, "Linux") || g.Confidence == "low" || !strings.Contains(g.Evidence, "ttl=64 ") || !strings.Contains(g.Evidence, "opts=M,S,T,N,W") {
+		t.Fatalf("unexpected guess for open port: %+v", g)
+	}
+	// A reset carries no options: only the TTL is left.
+	if g := results[1].OS; g == nil || g.Name != "Linux/Unix" || g.Confidence != "low" {
+		t.Fatalf("unexpected guess for closed port: %+v", g)
+	}
+}
+
+func TestSYNScannerPinnedSource(t *testing.T) {
+	open := testutil.StartService(t, nil)
+
+	s, err := NewSYNScanner(SYNConfig{Source: SourceConfig{Interface: "lo", IP: net.ParseIP("127.0.0.1"), Port: 40999}})
+	if err != nil {
+		t.Skipf("raw sockets unavailable: %v", err)
+	}
+	defer s.Close()
+	if s.sourcePort() != 40999 || !s.ownsPort(40999) || s.ownsPort(synSourcePortBase) {
+		t.Fatal("pinned source port not used")
+	}
+	results, err := s.Scan(context.Background(), []SYNTarget{{Host: "127.0.0.1", Ports: []int{open, 1}}})
+	if err != nil {
+		t.Fatalf("scan: %v", err)
+	}
+	// Replies to the pinned port must pass the reader's port filter.
+	if got := results[0].States; got[open] != PortOpen || got[1] != PortClosed {
+		t.Fatalf("unexpected states: %+v", got)
+	}
+}
+
+func TestNewSYNScannerRejectsUnknownTechnique(t *testing.T) {
+	if _, err := NewSYNScanner(SYNConfig{Technique: "maimon"}); err == nil {
+		t.Fatal("expected error for unknown technique")
+	}
+}
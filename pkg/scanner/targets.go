@@ -0,0 +1,67 @@
+package scanner
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"net/netip"
+	"strings"
+)
+
+// ReadTargetList reads target specifications from r, one or more per line.
+// Entries may be separated by whitespace or commas; '#' starts a comment.
+func ReadTargetList(r io.Reader) ([]string, error) {
+	var specs []string
+	sc := bufio.NewScanner(r)
+	for sc.Scan() {
+		line := sc.Text()
+		if i := strings.IndexByte(line, '#'); i >= 0 {
+			line = line[:i]
+		}
+		fields := strings.FieldsFunc(line, func(r rune) bool {
+			return r == ',' || r == ' ' || r == '\t' || r == '\r'
+		})
+		specs = append(specs, fields...)
+	}
+	if err := sc.Err(); err != nil {
+		return nil, err
+	}
+	return specs, nil
+}
+
+// Exclusions is a parsed set of excluded addresses. CIDRs and ranges are kept
+// as address intervals, so excluding a large range never expands it.
+type Exclusions struct {
+	set intervalSet
+}
+
+// ParseExclusions parses exclusion specs: addresses, CIDRs, octet and start-end
+// ranges, and hostnames, which are always resolved locally. CIDRs cover every
+// address of the prefix, network and broadcast included.
+func ParseExclusions(specs []string, opts TargetOptions) (*Exclusions, error) {
+	opts.RemoteDNS = false
+	ex := &Exclusions{}
+	for _, spec := range specs {
+		spec = strings.TrimSpace(spec)
+		if spec == "" {
+			continue
+		}
+		if strings.Contains(spec, "/") {
+			p, err := netip.ParsePrefix(spec)
+			if err != nil {
+				return nil, fmt.Errorf("invalid exclude CIDR: %s - %v", spec, err)
+			}
+			ex.set.insert(prefixInterval(p))
+			continue
+		}
+		intervals, err := parseSpecIntervals(spec, opts)
+		if err != nil {
+			return nil, fmt.Errorf("invalid exclude entry: %w", err)
+		}
+		for _, iv := range intervals {
+			iv.zone = ""
+			ex.set.insert(iv)
+		}
+	}
+	return ex, nil
+}
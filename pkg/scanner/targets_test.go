@@ -0,0 +1,72 @@
+package scanner
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestReadTargetListSkipsCommentsAndBlankLines(t *testing.T) {
+	in := `# in scope
+10.0.11.6
+10.0.11.0/30, 10.0.12.1   # web tier
+
+	fd00:11::6
+`
+	got, err := ReadTargetList(strings.NewReader(in))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	want := []string{"10.0.11.6", "10.0.11.0/30", "10.0.12.1", "fd00:11::6"}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("unexpected specs: got=%v want=%v", got, want)
+	}
+}
+
+func TestParseTargetsDedupesOverlappingRanges(t *testing.T) {
+	got, err := ParseTargets("10.0.11.2,10.0.11.0/30,10.0.11.1")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	want := []string{"10.0.11.2", "10.0.11.1"}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("unexpected targets: got=%v want=%v", got, want)
+	}
+}
+
+func TestParseExclusionsKeepsRangesWhole(t *testing.T) {
+	// Both ranges exceed the 65536-host expansion cap, which must not apply.
+	excluded, err := ParseExclusions([]string{"10.0.0.0-10.2.0.0", "10.3.0.*", "192.168.1.5", "fd00::-fd00::ffff:ffff:ffff"}, TargetOptions{})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	ts, err := NewTargetSet("10.0.0.0/14,192.168.1.4-192.168.1.5,fd00::1,fd00:1::1", TargetOptions{Exclude: excluded})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	// 262142 hosts in the /14, minus 131072 in the dash range and 256 in the octet range.
+	if got, want := ts.Len(), uint64(130814+2); got != want {
+		t.Fatalf("expected %d targets, got %d", want, got)
+	}
+	for host, want := range map[string]bool{
+		"10.1.255.255": false,
+		"10.2.0.0":     false,
+		"10.2.0.1":     true,
+		"10.3.0.200":   false,
+		"10.3.1.0":     true,
+		"192.168.1.4":  true,
+		"192.168.1.5":  false,
+		"fd00::1":      false,
+		"fd00:1::1":    true,
+	} {
+		if ts.Contains(host) != want {
+			t.Fatalf("%s: expected in set = %v", host, want)
+		}
+	}
+}
+
+func TestParseExclusionsRejectsInvalidCIDR(t *testing.T) {
+	if _, err := ParseExclusions([]string{"10.0.0.0/40"}, TargetOptions{}); err == nil {
+		t.Fatal("expected invalid exclude CIDR error")
+	}
+}
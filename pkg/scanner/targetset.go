@@ -0,0 +1,441 @@
+package scanner
+
+import (
+	"fmt"
+	"math/big"
+	"math/bits"
+	"math/rand/v2"
+	"net/netip"
+	"sort"
+	"strings"
+)
+
+// maxIteratedHosts caps a TargetSet: the whole IPv4 space, or an IPv6 /96.
+const maxIteratedHosts = uint64(1) << 32
+
+// TargetIterator yields target addresses one at a time.
+type TargetIterator interface {
+	Next() (string, bool)
+}
+
+// TargetSet is the deduplicated union of parsed target specs, stored as address
+// intervals so even very large ranges cost a few bytes until they are iterated.
+type TargetSet struct {
+	pieces  []addrInterval // disjoint, in the order the specs were given
+	offsets []uint64       // index of the first address of each piece
+	members intervalSet    // sorted union of pieces, for lookups
+	total   uint64
+}
+
+// NewTargetSet parses a comma-separated target spec. Overlapping entries are
+// kept once, at the position where they first appear, and opts.Exclude is subtracted.
+func NewTargetSet(target string, opts TargetOptions) (*TargetSet, error) {
+	var excluded intervalSet
+	if opts.Exclude != nil {
+		excluded = opts.Exclude.set
+	}
+
+	ts := &TargetSet{}
+	seen := false
+	for _, spec := range strings.Split(target, ",") {
+		spec = strings.TrimSpace(spec)
+		if spec == "" {
+			continue
+		}
+		seen = true
+		intervals, err := parseSpecIntervals(spec, opts)
+		if err != nil {
+			return nil, err
+		}
+		for _, iv := range intervals {
+			if iv.name != "" {
+				if !ts.hasName(iv.name) {
+					ts.pieces = append(ts.pieces, iv)
+					ts.offsets = append(ts.offsets, ts.total)
+					ts.total++
+				}
+				continue
+			}
+			for _, outside := range excluded.uncovered(iv) {
+				for _, piece := range ts.members.uncovered(outside) {
+					if err := ts.add(piece, spec); err != nil {
+						return nil, err
+					}
+				}
+			}
+		}
+	}
+	if !seen {
+		return nil, fmt.Errorf("no valid targets found")
+	}
+	return ts, nil
+}
+
+func (ts *TargetSet) add(piece addrInterval, spec string) error {
+	size := piece.size()
+	if size == 0 || ts.total+size > maxIteratedHosts {
+		return rangeTooLargeError(spec, maxIteratedHosts)
+	}
+	ts.members.insert(piece)
+	// Extend the previous piece when ranges continue each other, e.g. octet rows.
+	if n := len(ts.pieces); n > 0 && piece.zone == "" && ts.pieces[n-1].zone == "" && ts.pieces[n-1].end.next() == piece.start {
+		ts.pieces[n-1].end = piece.end
+	} else {
+		ts.pieces = append(ts.pieces, piece)
+		ts.offsets = append(ts.offsets, ts.total)
+	}
+	ts.total += size
+	return nil
+}
+
+// Len returns the number of addresses in the set.
+func (ts *TargetSet) Len() uint64 {
+	return ts.total
+}
+
+// Contains reports whether host is one of the set's addresses.
+func (ts *TargetSet) Contains(host string) bool {
+	addr, err := netip.ParseAddr(host)
+	if err != nil {
+		return ts.hasName(host)
+	}
+	return ts.members.contains(addrToU128(addr))
+}
+
+func (ts *TargetSet) hasName(host string) bool {
+	for _, p := range ts.pieces {
+		if p.name != "" && strings.EqualFold(p.name, host) {
+			return true
+		}
+	}
+	return false
+}
+
+func (ts *TargetSet) overlaps(iv addrInterval) bool {
+	return ts.members.overlaps(iv)
+}
+
+// at returns the address at position idx in spec order.
+func (ts *TargetSet) at(idx uint64) string {
+	p := sort.Search(len(ts.offsets), func(i int) bool { return ts.offsets[i] > idx }) - 1
+	piece := ts.pieces[p]
+	if piece.name != "" {
+		return piece.name
+	}
+	addr := piece.start.add(idx - ts.offsets[p]).addr()
+	if piece.zone != "" {
+		addr = addr.WithZone(piece.zone)
+	}
+	return addr.String()
+}
+
+// Iterator returns a fresh iterator over the set. With randomize, addresses come
+// out in a pseudo-random cyclic-group order so neighbouring hosts are not probed back to back.
+func (ts *TargetSet) Iterator(randomize bool) TargetIterator {
+	it := &setIterator{ts: ts}
+	if randomize && ts.total > 1 {
+		it.perm = newCyclicPermutation(ts.total)
+	}
+	return it
+}
+
+// Collect returns every address, or an error if the set holds more than limit.
+func (ts *TargetSet) Collect(label string, limit uint64) ([]string, error) {
+	if ts.total > limit {
+		return nil, rangeTooLargeError(label, limit)
+	}
+	out := make([]string, 0, ts.total)
+	it := ts.Iterator(false)
+	for host, ok := it.Next(); ok; host, ok = it.Next() {
+		out = append(out, host)
+	}
+	return out, nil
+}
+
+type setIterator struct {
+	ts   *TargetSet
+	perm *cyclicPermutation
+	i    uint64
+}
+
+func (it *setIterator) Next() (string, bool) {
+	if it.perm != nil {
+		idx, ok := it.perm.next()
+		if !ok {
+			return "", false
+		}
+		return it.ts.at(idx), true
+	}
+	if it.i >= it.ts.total {
+		return "", false
+	}
+	host := it.ts.at(it.i)
+	it.i++
+	return host, true
+}
+
+type sliceIterator struct {
+	hosts []string
+	i     int
+}
+
+// SliceIterator adapts an already expanded host list to a TargetIterator.
+func SliceIterator(hosts []string) TargetIterator {
+	return &sliceIterator{hosts: hosts}
+}
+
+func (it *sliceIterator) Next() (string, bool) {
+	if it.i >= len(it.hosts) {
+		return "", false
+	}
+	host := it.hosts[it.i]
+	it.i++
+	return host, true
+}
+
+// u128 is an IPv6 (or IPv4-mapped) address as a 128-bit integer.
+type u128 struct{ hi, lo uint64 }
+
+func addrToU128(a netip.Addr) u128 {
+	b := a.WithZone("").Unmap().As16()
+	return u128{
+		hi: uint64(b[0])<<56 | uint64(b[1])<<48 | uint64(b[2])<<40 | uint64(b[3])<<32 | uint64(b[4])<<24 | uint64(b[5])<<16 | uint64(b[6])<<8 | uint64(b[7]),
+		lo: uint64(b[8])<<56 | uint64(b[9])<<48 | uint64(b[10])<<40 | uint64(b[11])<<32 | uint64(b[12])<<24 | uint64(b[13])<<16 | uint64(b[14])<<8 | uint64(b[15]),
+	}
+}
+
+func (u u128) addr() netip.Addr {
+	var b [16]byte
+	for i := 0; i < 8; i++ {
+		b[i] = byte(u.hi >> (56 - 8*i))
+		b[8+i] = byte(u.lo >> (56 - 8*i))
+	}
+	return netip.AddrFrom16(b).Unmap()
+}
+
+func (u u128) cmp(v u128) int {
+	switch {
+	case u.hi < v.hi || (u.hi == v.hi && u.lo < v.lo):
+		return -1
+	case u == v:
+		return 0
+	default:
+		return 1
+	}
+}
+
+func (u u128) add(n uint64) u128 {
+	lo, carry := bits.Add64(u.lo, n, 0)
+	return u128{hi: u.hi + carry, lo: lo}
+}
+
+func (u u128) sub(v u128) u128 {
+	lo, borrow := bits.Sub64(u.lo, v.lo, 0)
+	return u128{hi: u.hi - v.hi - borrow, lo: lo}
+}
+
+func (u u128) next() u128 { return u.add(1) }
+
+func (u u128) prev() u128 { return u.sub(u128{lo: 1}) }
+
+var maxU128 = u128{hi: ^uint64(0), lo: ^uint64(0)}
+
+// addrInterval is an inclusive address range. zone is only set for single scoped
+// literals, and name only for hostnames kept unresolved (a single "address").
+type addrInterval struct {
+	start, end u128
+	zone       string
+	name       string
+}
+
+// size returns the number of addresses, or 0 if it exceeds maxIteratedHosts.
+func (iv addrInterval) size() uint64 {
+	d := iv.end.sub(iv.start)
+	if d.hi != 0 || d.lo >= maxIteratedHosts {
+		return 0
+	}
+	return d.lo + 1
+}
+
+func singleInterval(addr netip.Addr) addrInterval {
+	u := addrToU128(addr)
+	return addrInterval{start: u, end: u, zone: addr.Zone()}
+}
+
+func prefixInterval(p netip.Prefix) addrInterval {
+	p = p.Masked()
+	start := addrToU128(p.Addr())
+	hostBits := p.Addr().BitLen() - p.Bits()
+	span := u128{}
+	switch {
+	case hostBits >= 128:
+		return addrInterval{start: u128{}, end: maxU128}
+	case hostBits >= 64:
+		span = u128{hi: 1<<(hostBits-64) - 1, lo: ^uint64(0)}
+	default:
+		span = u128{lo: 1<<hostBits - 1}
+	}
+	end := u128{hi: start.hi | span.hi, lo: start.lo | span.lo}
+	return addrInterval{start: start, end: end}
+}
+
+// intervalSet is a sorted list of disjoint intervals.
+type intervalSet struct {
+	ivs []addrInterval
+}
+
+// uncovered returns the parts of iv not already in the set, in ascending order.
+func (s *intervalSet) uncovered(iv addrInterval) []addrInterval {
+	i := sort.Search(len(s.ivs), func(i int) bool { return s.ivs[i].end.cmp(iv.start) >= 0 })
+	var out []addrInterval
+	cur := iv.start
+	for ; i < len(s.ivs) && s.ivs[i].start.cmp(iv.end) <= 0; i++ {
+		if s.ivs[i].start.cmp(cur) > 0 {
+			out = append(out, addrInterval{start: cur, end: s.ivs[i].start.prev(), zone: iv.zone})
+		}
+		if s.ivs[i].end.cmp(iv.end) >= 0 || s.ivs[i].end == maxU128 {
+			return out
+		}
+		cur = s.ivs[i].end.next()
+	}
+	return append(out, addrInterval{start: cur, end: iv.end, zone: iv.zone})
+}
+
+// insert adds an interval that does not overlap the set, merging with adjacent neighbours.
+func (s *intervalSet) insert(iv addrInterval) {
+	iv.zone = ""
+	i := sort.Search(len(s.ivs), func(i int) bool { return s.ivs[i].start.cmp(iv.start) > 0 })
+	if i > 0 && s.ivs[i-1].end.cmp(iv.start) >= 0 {
+		// Overlaps the left neighbour (only happens for exclusion lists).
+		if iv.end.cmp(s.ivs[i-1].end) > 0 {
+			s.ivs[i-1].end = iv.end
+		}
+		s.mergeFrom(i - 1)
+		return
+	}
+	if i > 0 && s.ivs[i-1].end != maxU128 && s.ivs[i-1].end.next() == iv.start {
+		s.ivs[i-1].end = iv.end
+		s.mergeFrom(i - 1)
+		return
+	}
+	s.ivs = append(s.ivs, addrInterval{})
+	copy(s.ivs[i+1:], s.ivs[i:])
+	s.ivs[i] = iv
+	s.mergeFrom(i)
+}
+
+// mergeFrom folds intervals after i that overlap or touch ivs[i].
+func (s *intervalSet) mergeFrom(i int) {
+	j := i + 1
+	for j < len(s.ivs) && (s.ivs[i].end == maxU128 || s.ivs[j].start.cmp(s.ivs[i].end.next()) <= 0) {
+		if s.ivs[j].end.cmp(s.ivs[i].end) > 0 {
+			s.ivs[i].end = s.ivs[j].end
+		}
+		j++
+	}
+	s.ivs = append(s.ivs[:i+1], s.ivs[j:]...)
+}
+
+// overlaps reports whether any address of iv is in the set.
+func (s *intervalSet) overlaps(iv addrInterval) bool {
+	i := sort.Search(len(s.ivs), func(i int) bool { return s.ivs[i].end.cmp(iv.start) >= 0 })
+	return i < len(s.ivs) && s.ivs[i].start.cmp(iv.end) <= 0
+}
+
+func (s *intervalSet) contains(u u128) bool {
+	i := sort.Search(len(s.ivs), func(i int) bool { return s.ivs[i].end.cmp(u) >= 0 })
+	return i < len(s.ivs) && s.ivs[i].start.cmp(u) <= 0
+}
+
+// cyclicPermutation walks [0, n) in a pseudo-random order without storing it:
+// successive powers of a random generator g of the multiplicative group modulo
+// the smallest prime p > n visit every value in [1, p-1] exactly once.
+type cyclicPermutation struct {
+	n, p, g, x uint64
+	steps      uint64
+}
+
+func newCyclicPermutation(n uint64) *cyclicPermutation {
+	p := nextPrime(n)
+	return &cyclicPermutation{
+		n: n,
+		p: p,
+		g: randomGenerator(p),
+		x: 1 + rand.Uint64N(p-1),
+	}
+}
+
+func (c *cyclicPermutation) next() (uint64, bool) {
+	for c.steps < c.p-1 {
+		c.x = mulMod(c.x, c.g, c.p)
+		c.steps++
+		if c.x-1 < c.n {
+			return c.x - 1, true
+		}
+	}
+	return 0, false
+}
+
+func nextPrime(n uint64) uint64 {
+	candidate := new(big.Int).SetUint64(n + 1)
+	for !candidate.ProbablyPrime(0) {
+		candidate.Add(candidate, big.NewInt(1))
+	}
+	return candidate.Uint64()
+}
+
+// randomGenerator picks a random primitive root modulo the prime p.
+func randomGenerator(p uint64) uint64 {
+	if p <= 3 {
+		return p - 1
+	}
+	factors := primeFactors(p - 1)
+	for {
+		g := 2 + rand.Uint64N(p-3)
+		isGenerator := true
+		for _, q := range factors {
+			if powMod(g, (p-1)/q, p) == 1 {
+				isGenerator = false
+				break
+			}
+		}
+		if isGenerator {
+			return g
+		}
+	}
+}
+
+func primeFactors(n uint64) []uint64 {
+	var factors []uint64
+	for q := uint64(2); q*q <= n; q++ {
+		if n%q == 0 {
+			factors = append(factors, q)
+			for n%q == 0 {
+				n /= q
+			}
+		}
+	}
+	if n > 1 {
+		factors = append(factors, n)
+	}
+	return factors
+}
+
+func mulMod(a, b, m uint64) uint64 {
+	hi, lo := bits.Mul64(a, b)
+	_, rem := bits.Div64(hi, lo, m)
+	return rem
+}
+
+func powMod(base, exp, m uint64) uint64 {
+	result := uint64(1)
+	base %= m
+	for exp > 0 {
+		if exp&1 == 1 {
+			result = mulMod(result, base, m)
+		}
+		base = mulMod(base, base, m)
+		exp >>= 1
+	}
+	return result
+}
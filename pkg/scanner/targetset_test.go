@@ -0,0 +1,116 @@
+package scanner
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestTargetSetLargeRangeIsLazy(t *testing.T) {
+	ts, err := NewTargetSet("10.16.0.0/12", TargetOptions{})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if ts.Len() != 1<<20-2 {
+		t.Fatalf("expected %d hosts, got %d", 1<<20-2, ts.Len())
+	}
+	it := ts.Iterator(false)
+	first, _ := it.Next()
+	second, _ := it.Next()
+	if first != "10.16.0.1" || second != "10.16.0.2" {
+		t.Fatalf("unexpected iteration start: %s, %s", first, second)
+	}
+	if !ts.Contains("10.31.255.254") || ts.Contains("10.32.0.1") {
+		t.Fatal("unexpected membership at range edges")
+	}
+	if _, err := ExpandCIDR("10.16.0.0/12"); err == nil {
+		t.Fatal("expected ExpandCIDR to keep its slice limit")
+	}
+}
+
+func TestTargetSetDedupesAndExcludes(t *testing.T) {
+	excluded, err := ParseExclusions([]string{"10.0.0.4/30"}, TargetOptions{})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	ts, err := NewTargetSet("10.0.0.8,10.0.0.0/28,10.0.0.3", TargetOptions{Exclude: excluded})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	got, err := ts.Collect("test", 100)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	want := []string{"10.0.0.8", "10.0.0.1", "10.0.0.2", "10.0.0.3", "10.0.0.9", "10.0.0.10", "10.0.0.11", "10.0.0.12", "10.0.0.13", "10.0.0.14"}
+	if len(got) != len(want) {
+		t.Fatalf("expected %v, got %v", want, got)
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Fatalf("expected %v, got %v", want, got)
+		}
+	}
+}
+
+func TestTargetSetRemoteDNSKeepsHostnames(t *testing.T) {
+	ts, err := NewTargetSet("intranet.invalid,10.0.0.1,INTRANET.invalid,db.invalid", TargetOptions{RemoteDNS: true})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	got, err := ts.Collect("test", 100)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	want := []string{"intranet.invalid", "10.0.0.1", "db.invalid"}
+	if strings.Join(got, ",") != strings.Join(want, ",") {
+		t.Fatalf("expected %v, got %v", want, got)
+	}
+	if !ts.Contains("db.invalid") || !ts.Contains("10.0.0.1") || ts.Contains("web.invalid") {
+		t.Fatal("unexpected Contains result")
+	}
+}
+
+func TestTargetSetRandomizedVisitsEveryHostOnce(t *testing.T) {
+	ts, err := NewTargetSet("10.0.0.0/22,10.9.0.1-10.9.0.40", TargetOptions{})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	seen := make(map[string]int)
+	inOrder := true
+	ordered := ts.Iterator(false)
+	it := ts.Iterator(true)
+	for host, ok := it.Next(); ok; host, ok = it.Next() {
+		seen[host]++
+		if want, _ := ordered.Next(); want != host {
+			inOrder = false
+		}
+	}
+	if uint64(len(seen)) != ts.Len() {
+		t.Fatalf("expected %d distinct hosts, got %d", ts.Len(), len(seen))
+	}
+	for host, n := range seen {
+		if n != 1 || !ts.Contains(host) {
+			t.Fatalf("host %s visited %d times", host, n)
+		}
+	}
+	if inOrder {
+		t.Fatal("expected randomized iteration to differ from address order")
+	}
+}
+
+func TestCyclicPermutationCoversRange(t *testing.T) {
+	for _, n := range []uint64{2, 3, 10, 254, 1000} {
+		perm := newCyclicPermutation(n)
+		seen := make([]bool, n)
+		count := uint64(0)
+		for idx, ok := perm.next(); ok; idx, ok = perm.next() {
+			if idx >= n || seen[idx] {
+				t.Fatalf("n=%d: index %d out of range or repeated", n, idx)
+			}
+			seen[idx] = true
+			count++
+		}
+		if count != n {
+			t.Fatalf("n=%d: visited %d indexes", n, count)
+		}
+	}
+}
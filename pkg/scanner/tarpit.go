@@ -0,0 +1,132 @@
+package scanner
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"math/rand/v2"
+	"slices"
+	"time"
+)
+
+// TarpitSampleSize is how many open ports the silence and latency signals
+// of AssessTarpit need before they mean anything. Callers checking a sample
+// of ports for silence should probe this many.
+const TarpitSampleSize = 5
+
+const (
+	// canaryLow is the bottom of the range canary ports are drawn from.
+	// Ports up there are rarely assigned, so an open one is suspicious.
+	canaryLow = 40000
+	// tarpitOpenRatio is the share of scanned ports that must be open for the
+	// open ratio signal, counted only once tarpitMinPorts were scanned.
+	tarpitOpenRatio = 0.9
+	tarpitMinPorts  = 50
+	// tarpitLatencyCV is the coefficient of variation of handshake times
+	// below which latencies count as identical.
+	tarpitLatencyCV = 0.25
+	// tarpitScore is the score at which a host is suspected: the canary and
+	// the open ratio weigh 2, silence and uniform latency 1.
+	tarpitScore = 3
+)
+
+// TarpitReport is the verdict of AssessTarpit on one host: whether it looks
+// like a middlebox answering every port rather than real services.
+type TarpitReport struct {
+	Suspected bool
+	// OpenPorts and ScannedPorts give the open ratio.
+	OpenPorts    int
+	ScannedPorts int
+	// CanaryPort is the random high port probed before the scan, 0 if none.
+	CanaryPort int
+	CanaryOpen bool
+	// Reasons lists the signals that fired, e.g. "998/1000 ports open".
+	Reasons []string
+}
+
+// ProbeCanary connects to one random high port that is neither in ports nor
+// in the port map, so an open answer hints that the host accepts anything.
+// It goes through the same limits as a scanned port.
+func (s *Scanner) ProbeCanary(ctx context.Context, ports []int) ScanResult {
+	port := canaryLow
+	for range 32 {
+		port = canaryLow + rand.IntN(65536-canaryLow)
+		if !slices.Contains(ports, port) && s.PortManager.GetServiceName(port, "") == "" {
+			break
+		}
+	}
+	return s.scanPort(ctx, port, false)
+}
+
+// AssessTarpit looks for signs that results came from a host answering
+// every port: an open canary (nil if none was probed), an open ratio above
+// 90% of at least 50 scanned ports, identical handshake times, and no
+// service answering detection probes on any open port of detected, the
+// results of service detection on all or a sample of the open ports (nil if
+// none ran). The canary and the ratio weigh twice as much as the other two,
+// and a host is suspected at a score of 3, so no single signal is enough.
+func AssessTarpit(results []ScanResult, scanned int, canary *ScanResult, detected []ScanResult) TarpitReport {
+	report := TarpitReport{OpenPorts: OpenCount(results), ScannedPorts: scanned}
+	score := 0
+	if canary != nil {
+		report.CanaryPort = canary.Port
+		report.CanaryOpen = canary.EffectiveState() == PortOpen
+		if report.CanaryOpen {
+			score += 2
+			report.Reasons = append(report.Reasons, fmt.Sprintf("canary port %d open", canary.Port))
+		}
+	}
+	if scanned >= tarpitMinPorts && float64(report.OpenPorts) >= tarpitOpenRatio*float64(scanned) {
+		score += 2
+		report.Reasons = append(report.Reasons, fmt.Sprintf("%d/%d ports open", report.OpenPorts, scanned))
+	}
+	probed, answered := 0, false
+	for _, r := range detected {
+		if r.EffectiveState() == PortOpen {
+			probed++
+			answered = answered || serviceAnswered(r)
+		}
+	}
+	if probed >= TarpitSampleSize && !answered {
+		score++
+		report.Reasons = append(report.Reasons, fmt.Sprintf("no service answered on %d probed ports", probed))
+	}
+	var latencies []time.Duration
+	for _, r := range results {
+		if r.EffectiveState() == PortOpen && r.Latency > 0 {
+			latencies = append(latencies, r.Latency)
+		}
+	}
+	if cv, ok := latencyCV(latencies); ok && cv <= tarpitLatencyCV {
+		score++
+		report.Reasons = append(report.Reasons, fmt.Sprintf("uniform latency (cv %.2f)", cv))
+	}
+	report.Suspected = score >= tarpitScore
+	return report
+}
+
+// serviceAnswered reports whether detection got more out of a port than the
+// handshake: a version, a TLS session or a fingerprint beyond the port map.
+func serviceAnswered(r ScanResult) bool {
+	return r.Version != "" || r.TLS || (r.Confidence != "" && r.Confidence != "low")
+}
+
+// latencyCV returns the coefficient of variation (stddev/mean) of
+// latencies, or false with fewer than TarpitSampleSize samples.
+func latencyCV(latencies []time.Duration) (float64, bool) {
+	if len(latencies) < TarpitSampleSize {
+		return 0, false
+	}
+	var sum float64
+	for _, l := range latencies {
+		sum += float64(l)
+	}
+	mean := sum / float64(len(latencies))
+	var variance float64
+	for _, l := range latencies {
+		d := float64(l) - mean
+		variance += d * d
+	}
+	variance /= float64(len(latencies))
+	return math.Sqrt(variance) / mean, true
+}
@@ -0,0 +1,104 @@
+package scanner
+
+import (
+	"context"
+	"io"
+	"net"
+	"slices"
+	"testing"
+	"time"
+)
+
+// openResults builds n open results from port 1 up, with handshake times
+// from latency(i).
+func openResults(n int, latency func(i int) time.Duration) []ScanResult {
+	results := make([]ScanResult, n)
+	for i := range results {
+		results[i] = ScanResult{Port: i + 1, IsOpen: true, State: PortOpen, Latency: latency(i)}
+	}
+	return results
+}
+
+func TestAssessTarpit(t *testing.T) {
+	flat := func(int) time.Duration { return 20 * time.Millisecond }
+	jittery := func(i int) time.Duration { return time.Duration(1+i%7*10) * time.Millisecond }
+	openCanary := &ScanResult{Port: 48211, State: PortOpen}
+	closedCanary := &ScanResult{Port: 48211, State: PortClosed}
+	banners := openResults(6, jittery)
+	for i := range banners {
+		banners[i].Version = "OpenSSH 9.6p1"
+		banners[i].Confidence = "high"
+	}
+
+	for _, tc := range []struct {
+		name      string
+		results   []ScanResult
+		scanned   int
+		canary    *ScanResult
+		detected  bool // results went through service detection
+		suspected bool
+		reasons   int
+	}{
+		{"every port open and silent", openResults(1000, jittery), 1000, closedCanary, true, true, 2},
+		{"canary and identical latency", openResults(6, flat), 6, openCanary, false, true, 2},
+		{"canary and silence", openResults(6, jittery), 6, openCanary, true, true, 2},
+		{"canary alone", openResults(2, flat), 100, openCanary, true, false, 1},
+		{"open ratio alone", openResults(95, jittery), 100, closedCanary, false, false, 1},
+		{"silence and latency are not enough", openResults(6, flat), 1000, closedCanary, true, false, 2},
+		{"real services answer", banners, 6, openCanary, true, false, 1},
+		{"ratio needs enough ports", openResults(10, jittery), 10, nil, false, false, 0},
+	} {
+		var detected []ScanResult
+		if tc.detected {
+			detected = tc.results
+		}
+		got := AssessTarpit(tc.results, tc.scanned, tc.canary, detected)
+		if got.Suspected != tc.suspected || len(got.Reasons) != tc.reasons {
+			t.Fatalf("%s: unexpected verdict %+v", tc.name, got)
+		}
+		if tc.canary != nil && got.CanaryPort != 48211 {
+			t.Fatalf("%s: canary port not reported: %+v", tc.name, got)
+		}
+	}
+}
+
+func TestProbeCanary(t *testing.T) {
+	// Every port answers, like a SYN-ACK-everything middlebox.
+	answerAll := func(port int, conn net.Conn) bool {
+		defer func() { _ = conn.Close() }()
+		_, _ = io.Copy(io.Discard, conn)
+		return true
+	}
+	s := NewScanner("10.0.11.6", false)
+	s.Configure(ScanConfig{Timeout: time.Second, Dialer: pipeDialer{serve: answerAll}})
+	ports := []int{22, 80, 443}
+	canary := s.ProbeCanary(context.Background(), ports)
+	if canary.State != PortOpen || canary.Port < canaryLow || slices.Contains(ports, canary.Port) {
+		t.Fatalf("unexpected canary %+v", canary)
+	}
+
+	s.Configure(ScanConfig{Timeout: time.Second, Dialer: pipeDialer{serve: fakeServices}})
+	if canary := s.ProbeCanary(context.Background(), ports); canary.State != PortClosed {
+		t.Fatalf("canary should be closed on a normal host, got %+v", canary)
+	}
+}
+
+func TestAssessTarpitSilentSample(t *testing.T) {
+	jittery := func(i int) time.Duration { return time.Duration(1+i%7*10) * time.Millisecond }
+	results := openResults(1000, jittery)
+	sample := openResults(TarpitSampleSize, jittery)
+	if got := AssessTarpit(results, 1000, nil, nil); got.Suspected {
+		t.Fatalf("the open ratio alone should not be enough: %+v", got)
+	}
+	got := AssessTarpit(results, 1000, nil, sample)
+	if !got.Suspected || got.Reasons[1] != "no service answered on 5 probed ports" {
+		t.Fatalf("a silent sample should tip the verdict: %+v", got)
+	}
+	sample[2].Version = "nginx 1.24.0"
+	if got := AssessTarpit(results, 1000, nil, sample); got.Suspected {
+		t.Fatalf("one answering service should clear the host: %+v", got)
+	}
+	if got := AssessTarpit(results, 1000, nil, sample[:2]); got.Suspected {
+		t.Fatalf("too small a sample should not count: %+v", got)
+	}
+}
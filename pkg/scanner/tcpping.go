@@ -0,0 +1,245 @@
+package scanner
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/rand/v2"
+	"net"
+	"net/netip"
+	"runtime"
+	"strings"
+	"sync"
+	"time"
+)
+
+// tcpPinger sends raw SYN or ACK probes for host discovery from one socket per
+// address family, reusing the SYN engine's packet builder and parser. A SYN-ACK
+// or RST from a probed port is handed to the probe waiting on that peer when
+// it answers that probe's sequence number.
+type tcpPinger struct {
+	v4, v6       *tcpPingEndpoint
+	v4Err, v6Err error
+}
+
+type tcpPingEndpoint struct {
+	conn    net.PacketConn
+	src     SourceConfig
+	srcPort int
+
+	mu      sync.Mutex
+	waiting map[string]*tcpPingWait
+}
+
+// tcpPingWait is a probe waiting for its peer: the sequence number and flags
+// it sent, and where to hand the answer.
+type tcpPingWait struct {
+	seq     uint32
+	flags   byte
+	replied chan tcpResponse
+}
+
+// openTCPPinger opens the raw sockets, bound to src's interface and address.
+// Probes leave from src's port, or a random high port.
+func openTCPPinger(src SourceConfig) (*tcpPinger, error) {
+	if runtime.GOOS != "linux" {
+		return nil, errors.New("raw TCP discovery currently supported on linux only")
+	}
+	p := &tcpPinger{}
+	p.v4, p.v4Err = openTCPPingEndpoint("ip4:tcp", false, src)
+	p.v6, p.v6Err = openTCPPingEndpoint("ip6:tcp", true, src)
+	if p.v4 == nil && p.v6 == nil {
+		return nil, p.v4Err
+	}
+	return p, nil
+}
+
+func openTCPPingEndpoint(network string, v6 bool, src SourceConfig) (*tcpPingEndpoint, error) {
+	addr, err := src.listenAddr(v6)
+	if err != nil {
+		return nil, err
+	}
+	conn, err := net.ListenPacket(network, addr)
+	if err != nil {
+		msg := strings.ToLower(err.Error())
+		if strings.Contains(msg, "operation not permitted") || strings.Contains(msg, "permission denied") {
+			return nil, errors.New("insufficient privileges for raw TCP discovery (needs root/CAP_NET_RAW)")
+		}
+		return nil, fmt.Errorf("failed to open raw tcp socket: %w", err)
+	}
+	if err := src.bindPacketConn(conn); err != nil {
+		_ = conn.Close()
+		return nil, fmt.Errorf("failed to bind raw tcp socket to %s: %w", src.Interface, err)
+	}
+	srcPort := src.Port
+	if srcPort == 0 {
+		srcPort = synSourcePortBase + rand.IntN(synSourcePortCount)
+	}
+	ep := &tcpPingEndpoint{
+		conn:    conn,
+		src:     src,
+		srcPort: srcPort,
+		waiting: make(map[string]*tcpPingWait),
+	}
+	go ep.readLoop()
+	return ep, nil
+}
+
+// probe sends one probe with the given flags to every port and waits for the
+// first SYN-ACK or RST from host.
+func (p *tcpPinger) probe(ctx context.Context, host string, ports []int, flags byte, timeout time.Duration) (DiscoveryResult, bool) {
+	addr, err := netip.ParseAddr(host)
+	if err != nil || addr.Zone() != "" {
+		return DiscoveryResult{}, false
+	}
+	addr = addr.Unmap()
+	ep := p.v4
+	if addr.Is6() {
+		ep = p.v6
+	}
+	if ep == nil {
+		return DiscoveryResult{}, false
+	}
+	resp, rtt, ok := ep.probe(ctx, addr, ports, flags, timeout)
+	if !ok {
+		return DiscoveryResult{}, false
+	}
+	reason := ReasonTCPReset
+	if resp.flags&(tcpFlagSyn|tcpFlagAck) == tcpFlagSyn|tcpFlagAck {
+		reason = ReasonSYNACK
+	}
+	return DiscoveryResult{Host: host, Reason: reason, Port: resp.srcPort, RTT: rtt}, true
+}
+
+func (p *tcpPinger) Close() {
+	if p.v4 != nil {
+		_ = p.v4.conn.Close()
+	}
+	if p.v6 != nil {
+		_ = p.v6.conn.Close()
+	}
+}
+
+func (ep *tcpPingEndpoint) probe(ctx context.Context, addr netip.Addr, ports []int, flags byte, timeout time.Duration) (tcpResponse, time.Duration, bool) {
+	dstIP := net.IP(addr.AsSlice())
+	srcIP, err := ep.src.sourceIP(dstIP)
+	if err != nil {
+		return tcpResponse{}, 0, false
+	}
+
+	key := addr.String()
+	wait := &tcpPingWait{seq: rand.Uint32(), flags: flags, replied: make(chan tcpResponse, 1)}
+	ep.mu.Lock()
+	ep.waiting[key] = wait
+	ep.mu.Unlock()
+	defer func() {
+		ep.mu.Lock()
+		delete(ep.waiting, key)
+		ep.mu.Unlock()
+	}()
+
+	start := time.Now()
+	sent := 0
+	for _, port := range ports {
+		if sendTCPSegment(ep.conn, srcIP, dstIP, ep.srcPort, port, wait.seq, wait.ack(), flags, nil) == nil {
+			sent++
+		}
+	}
+	if sent == 0 {
+		return tcpResponse{}, 0, false
+	}
+
+	timer := time.NewTimer(timeout)
+	defer timer.Stop()
+	select {
+	case resp := <-wait.replied:
+		return resp, time.Since(start), true
+	case <-timer.C:
+	case <-ctx.Done():
+	}
+	return tcpResponse{}, 0, false
+}
+
+// ack is the acknowledgement number of the probe: only ACK probes carry one,
+// and the RST they draw takes it as its sequence number.
+func (w *tcpPingWait) ack() uint32 {
+	if w.flags&tcpFlagAck != 0 {
+		return w.seq
+	}
+	return 0
+}
+
+// answers reports whether resp replies to the probe: a RST to an ACK probe
+// carries the probe's acknowledgement number as its sequence number, while
+// answers to a SYN acknowledge the probe's sequence number plus one.
+func (w *tcpPingWait) answers(resp tcpResponse) bool {
+	if w.flags&tcpFlagAck != 0 {
+		return resp.seq == w.ack()
+	}
+	return resp.ack-1 == w.seq
+}
+
+// readLoop receives every TCP segment the host sees and keeps the answers to
+// our source port. A SYN-ACK or RST from any port of a waiting peer settles it
+// if it answers the peer's probe.
+func (ep *tcpPingEndpoint) readLoop() {
+	v6 := isIPv6Conn(ep.conn)
+	buf := make([]byte, 4096)
+	for {
+		n, peer, err := ep.conn.ReadFrom(buf)
+		if err != nil {
+			if errors.Is(err, net.ErrClosed) {
+				return
+			}
+			continue
+		}
+		var (
+			resp tcpResponse
+			ok   bool
+		)
+		if v6 {
+			resp, ok, _ = parseTCPSegment(buf[:n])
+		} else {
+			resp, ok, _ = parseTCPResponsePacket(buf[:n])
+		}
+		if !ok || resp.dstPort != ep.srcPort {
+			continue
+		}
+		isSynAck := resp.flags&(tcpFlagSyn|tcpFlagAck) == tcpFlagSyn|tcpFlagAck
+		if !isSynAck && resp.flags&tcpFlagRst == 0 {
+			continue
+		}
+		peerIP, ok := icmpPeer(peer)
+		if !ok {
+			continue
+		}
+		ep.mu.Lock()
+		wait, known := ep.waiting[peerIP]
+		waiting := known && wait.answers(resp)
+		if waiting {
+			select {
+			case wait.replied <- resp:
+			default:
+			}
+		}
+		ep.mu.Unlock()
+		if waiting && isSynAck {
+			ep.reset(peerIP, resp)
+		}
+	}
+}
+
+// reset answers a SYN-ACK with RST so the probed port does not keep a
+// half-open connection.
+func (ep *tcpPingEndpoint) reset(peer string, synAck tcpResponse) {
+	addr, err := netip.ParseAddr(peer)
+	if err != nil {
+		return
+	}
+	dstIP := net.IP(addr.AsSlice())
+	srcIP, err := ep.src.sourceIP(dstIP)
+	if err != nil {
+		return
+	}
+	_ = sendTCPSegment(ep.conn, srcIP, dstIP, ep.srcPort, synAck.srcPort, synAck.ack, 0, tcpFlagRst, nil)
+}
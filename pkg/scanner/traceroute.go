@@ -0,0 +1,446 @@
+package scanner
+
+import (
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"math/rand/v2"
+	"net"
+	"net/netip"
+	"runtime"
+	"sync"
+	"time"
+
+	"golang.org/x/net/icmp"
+	"golang.org/x/net/ipv4"
+	"golang.org/x/net/ipv6"
+)
+
+// TracerouteConfig controls TCP SYN traceroutes.
+type TracerouteConfig struct {
+	// MaxHops is the highest TTL probed; 0 means 30.
+	MaxHops int
+	// Timeout is how long each wave of probes waits for answers; 0 means 1s.
+	Timeout time.Duration
+	// Limiter, when set, caps the probe rate across every engine sharing it.
+	Limiter *RateLimiter
+	// Source pins the interface and address probes are sent from. Probes
+	// keep their own source ports, one per TTL.
+	Source SourceConfig
+}
+
+// Hop is one step of a route. Addr is empty when nothing answered at that TTL.
+type Hop struct {
+	TTL  int
+	Addr string
+	RTT  time.Duration
+}
+
+// Route is the path to a host. When Reached is set, the last hop is the host
+// itself and len(Hops) is its hop distance.
+type Route struct {
+	Hops    []Hop
+	Reached bool
+}
+
+// traceWave is the number of TTLs probed at once. Probes stop after the wave
+// in which the target answers, so close hosts do not get a full set of SYNs.
+const traceWave = 8
+
+// Tracer runs TCP SYN traceroutes over the SYN engine's raw sockets: SYNs to
+// an open port with increasing TTL, where routers answer with ICMP time
+// exceeded and the target with SYN-ACK or RST. Probes are matched by source
+// port and sequence number, which ICMP errors quote back. One Tracer can
+// trace several hosts concurrently.
+type Tracer struct {
+	cfg          TracerouteConfig
+	v4, v6       *traceEndpoint
+	v4Err, v6Err error
+}
+
+type traceEndpoint struct {
+	tcp  net.PacketConn
+	icmp *icmp.PacketConn
+	v6   bool
+	src  SourceConfig
+	// setTTL sets the TTL or hop limit of the next probes; sendMu keeps it
+	// paired with the write it applies to.
+	setTTL func(int) error
+	sendMu sync.Mutex
+
+	mu     sync.Mutex
+	probes map[int]traceProbe // by source port
+}
+
+type traceProbe struct {
+	trace *trace
+	ttl   int
+}
+
+// trace is one Trace call, updated by the endpoint readers.
+type trace struct {
+	dst     netip.Addr
+	seq     uint32
+	sent    map[int]time.Time
+	hops    map[int]Hop
+	reached int // lowest TTL the target answered, 0 if none
+	update  chan struct{}
+}
+
+// NewTracer opens raw TCP and ICMP sockets for each address family. It fails
+// when neither family can be traced, which usually means missing privileges.
+func NewTracer(cfg TracerouteConfig) (*Tracer, error) {
+	if runtime.GOOS != "linux" {
+		return nil, errors.New("traceroute currently supported on linux only")
+	}
+	if cfg.MaxHops <= 0 {
+		cfg.MaxHops = 30
+	}
+	if cfg.MaxHops > 255 {
+		cfg.MaxHops = 255
+	}
+	if cfg.Timeout <= 0 {
+		cfg.Timeout = time.Second
+	}
+	t := &Tracer{cfg: cfg}
+	t.v4, t.v4Err = openTraceEndpoint(false, cfg.Source)
+	t.v6, t.v6Err = openTraceEndpoint(true, cfg.Source)
+	if t.v4 == nil && t.v6 == nil {
+		return nil, t.v4Err
+	}
+	return t, nil
+}
+
+func openTraceEndpoint(v6 bool, src SourceConfig) (*traceEndpoint, error) {
+	tcpNet, icmpNet := "ip4:tcp", "ip4:icmp"
+	if v6 {
+		tcpNet, icmpNet = "ip6:tcp", "ip6:ipv6-icmp"
+	}
+	addr, err := src.listenAddr(v6)
+	if err != nil {
+		return nil, err
+	}
+	tcpConn, err := net.ListenPacket(tcpNet, addr)
+	if err != nil {
+		if isPermissionError(err) {
+			return nil, errors.New("insufficient privileges for traceroute (needs root/CAP_NET_RAW)")
+		}
+		return nil, fmt.Errorf("failed to open raw tcp socket: %w", err)
+	}
+	if err := src.bindPacketConn(tcpConn); err != nil {
+		_ = tcpConn.Close()
+		return nil, fmt.Errorf("failed to bind raw tcp socket to %s: %w", src.Interface, err)
+	}
+	icmpConn, err := icmp.ListenPacket(icmpNet, addr)
+	if err != nil {
+		_ = tcpConn.Close()
+		if isPermissionError(err) {
+			return nil, errors.New("insufficient privileges for traceroute (needs root/CAP_NET_RAW)")
+		}
+		return nil, fmt.Errorf("failed to open raw icmp socket: %w", err)
+	}
+	ep := &traceEndpoint{tcp: tcpConn, icmp: icmpConn, v6: v6, src: src, probes: make(map[int]traceProbe)}
+	if v6 {
+		p := ipv6.NewPacketConn(tcpConn)
+		ep.setTTL = p.SetHopLimit
+	} else {
+		p := ipv4.NewPacketConn(tcpConn)
+		ep.setTTL = p.SetTTL
+	}
+	go ep.readTCP()
+	go ep.readICMP()
+	return ep, nil
+}
+
+// Close releases the raw sockets.
+func (t *Tracer) Close() {
+	for _, ep := range []*traceEndpoint{t.v4, t.v6} {
+		if ep != nil {
+			_ = ep.tcp.Close()
+			_ = ep.icmp.Close()
+		}
+	}
+}
+
+// Trace sends SYNs to host:port with TTL 1, 2, ... in waves until the host
+// answers or MaxHops is reached. port should be open so the host replies; a
+// closed port works too, as its RST ends the trace just the same. Trailing
+// TTLs that got no answer are dropped from the route.
+func (t *Tracer) Trace(ctx context.Context, host string, port int) (Route, error) {
+	dstIP, err := resolveSYNTarget(host)
+	if err != nil {
+		return Route{}, err
+	}
+	dst, _ := netip.AddrFromSlice(dstIP)
+	ep, epErr := t.v4, t.v4Err
+	if dst.Is6() {
+		ep, epErr = t.v6, t.v6Err
+	}
+	if ep == nil {
+		return Route{}, epErr
+	}
+	srcIP, err := t.cfg.Source.sourceIP(dstIP)
+	if err != nil {
+		return Route{}, err
+	}
+
+	tr := &trace{
+		dst:    dst,
+		seq:    rand.Uint32(),
+		sent:   make(map[int]time.Time, t.cfg.MaxHops),
+		hops:   make(map[int]Hop, t.cfg.MaxHops),
+		update: make(chan struct{}, 1),
+	}
+	base := ep.register(tr, t.cfg.MaxHops)
+	defer ep.unregister(base, t.cfg.MaxHops)
+
+	for first := 1; first <= t.cfg.MaxHops; first += traceWave {
+		last := min(first+traceWave-1, t.cfg.MaxHops)
+		for ttl := first; ttl <= last; ttl++ {
+			if err := t.cfg.Limiter.Wait(ctx); err != nil {
+				return ep.route(tr), err
+			}
+			if err := ep.send(tr, srcIP, dstIP, base+ttl-1, port, ttl); err != nil {
+				return Route{}, fmt.Errorf("failed to send traceroute probe: %w", err)
+			}
+		}
+		if err := ep.wait(ctx, tr, last, t.cfg.Timeout); err != nil {
+			return ep.route(tr), err
+		}
+		ep.mu.Lock()
+		reached := tr.reached
+		ep.mu.Unlock()
+		if reached > 0 {
+			break
+		}
+	}
+	return ep.route(tr), nil
+}
+
+// register reserves maxHops consecutive source ports for tr, one per TTL.
+func (ep *traceEndpoint) register(tr *trace, maxHops int) int {
+	ep.mu.Lock()
+	defer ep.mu.Unlock()
+	for {
+		base := synSourcePortBase + rand.IntN(synSourcePortCount-maxHops)
+		free := true
+		for p := base; p < base+maxHops; p++ {
+			if _, used := ep.probes[p]; used {
+				free = false
+				break
+			}
+		}
+		if !free {
+			continue
+		}
+		for ttl := 1; ttl <= maxHops; ttl++ {
+			ep.probes[base+ttl-1] = traceProbe{trace: tr, ttl: ttl}
+		}
+		return base
+	}
+}
+
+func (ep *traceEndpoint) unregister(base, maxHops int) {
+	ep.mu.Lock()
+	defer ep.mu.Unlock()
+	for p := base; p < base+maxHops; p++ {
+		delete(ep.probes, p)
+	}
+}
+
+func (ep *traceEndpoint) send(tr *trace, srcIP, dstIP net.IP, srcPort, dstPort, ttl int) error {
+	ep.sendMu.Lock()
+	defer ep.sendMu.Unlock()
+	if err := ep.setTTL(ttl); err != nil {
+		return err
+	}
+	ep.mu.Lock()
+	tr.sent[ttl] = time.Now()
+	ep.mu.Unlock()
+	return sendTCPSegment(ep.tcp, srcIP, dstIP, srcPort, dstPort, tr.seq, 0, tcpFlagSyn, nil)
+}
+
+// wait returns once every TTL up to last, or up to the target, has answered,
+// or after timeout.
+func (ep *traceEndpoint) wait(ctx context.Context, tr *trace, last int, timeout time.Duration) error {
+	timer := time.NewTimer(timeout)
+	defer timer.Stop()
+	for {
+		ep.mu.Lock()
+		end := last
+		if tr.reached > 0 && tr.reached < end {
+			end = tr.reached
+		}
+		complete := tr.reached > 0 || len(tr.hops) >= last
+		for ttl := 1; complete && ttl < end; ttl++ {
+			if _, ok := tr.hops[ttl]; !ok {
+				complete = false
+			}
+		}
+		ep.mu.Unlock()
+		if complete {
+			return nil
+		}
+		select {
+		case <-tr.update:
+		case <-timer.C:
+			return nil
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+}
+
+// route lists the hops up to the target, or up to the last TTL that answered.
+func (ep *traceEndpoint) route(tr *trace) Route {
+	ep.mu.Lock()
+	defer ep.mu.Unlock()
+	last := tr.reached
+	if last == 0 {
+		for ttl := range tr.hops {
+			last = max(last, ttl)
+		}
+	}
+	r := Route{Reached: tr.reached > 0}
+	for ttl := 1; ttl <= last; ttl++ {
+		hop, ok := tr.hops[ttl]
+		if !ok {
+			hop = Hop{TTL: ttl}
+		}
+		r.Hops = append(r.Hops, hop)
+	}
+	return r
+}
+
+// record stores the answer to the probe sent with ttl; callers hold ep.mu.
+func (ep *traceEndpoint) record(tr *trace, ttl int, from netip.Addr, target bool) {
+	if _, seen := tr.hops[ttl]; seen {
+		return
+	}
+	hop := Hop{TTL: ttl, Addr: from.String()}
+	if sent, ok := tr.sent[ttl]; ok {
+		hop.RTT = time.Since(sent)
+	}
+	tr.hops[ttl] = hop
+	if target && (tr.reached == 0 || ttl < tr.reached) {
+		tr.reached = ttl
+	}
+	select {
+	case tr.update <- struct{}{}:
+	default:
+	}
+}
+
+// readTCP takes the target's SYN-ACK or RST to a probe and resets SYN-ACKs,
+// like the SYN engine.
+func (ep *traceEndpoint) readTCP() {
+	read := newTCPSegmentReader(ep.tcp)
+	buf := make([]byte, 4096)
+	for {
+		n, _, peer, err := read(buf)
+		if err != nil {
+			if errors.Is(err, net.ErrClosed) {
+				return
+			}
+			continue
+		}
+		resp, ok, _ := parseTCPSegment(buf[:n])
+		if !ok || resp.flags&(tcpFlagSyn|tcpFlagRst) == 0 {
+			continue
+		}
+		from, ok := peerAddr(peer)
+		if !ok {
+			continue
+		}
+		ep.mu.Lock()
+		probe, known := ep.probes[resp.dstPort]
+		valid := known && probe.trace.dst == from && resp.ack-1 == probe.trace.seq
+		if valid {
+			ep.record(probe.trace, probe.ttl, from, true)
+		}
+		ep.mu.Unlock()
+		if valid && resp.flags&(tcpFlagSyn|tcpFlagAck) == tcpFlagSyn|tcpFlagAck {
+			dstIP := net.IP(from.AsSlice())
+			if srcIP, err := ep.src.sourceIP(dstIP); err == nil {
+				_ = sendTCPSegment(ep.tcp, srcIP, dstIP, resp.dstPort, resp.srcPort, resp.ack, 0, tcpFlagRst, nil)
+			}
+		}
+	}
+}
+
+// readICMP takes time-exceeded messages that quote one of our probes.
+func (ep *traceEndpoint) readICMP() {
+	proto := icmpProtoV4
+	if ep.v6 {
+		proto = icmpProtoV6
+	}
+	buf := make([]byte, 1500)
+	for {
+		n, peer, err := ep.icmp.ReadFrom(buf)
+		if err != nil {
+			if errors.Is(err, net.ErrClosed) {
+				return
+			}
+			continue
+		}
+		msg, err := icmp.ParseMessage(proto, buf[:n])
+		if err != nil {
+			continue
+		}
+		exceeded, ok := msg.Body.(*icmp.TimeExceeded)
+		if !ok {
+			continue
+		}
+		quotedDst, srcPort, seq, ok := quotedTCPProbe(exceeded.Data, ep.v6)
+		if !ok {
+			continue
+		}
+		from, ok := peerAddr(peer)
+		if !ok {
+			continue
+		}
+		ep.mu.Lock()
+		if probe, known := ep.probes[srcPort]; known && probe.trace.dst == quotedDst && probe.trace.seq == seq {
+			ep.record(probe.trace, probe.ttl, from, false)
+		}
+		ep.mu.Unlock()
+	}
+}
+
+// quotedTCPProbe reads the destination, TCP source port and sequence number of
+// the datagram quoted in an ICMP error: its IP header plus at least the first
+// 8 bytes of the TCP header.
+func quotedTCPProbe(data []byte, v6 bool) (dst netip.Addr, srcPort int, seq uint32, ok bool) {
+	var seg []byte
+	if v6 {
+		if len(data) < 40 || data[0]>>4 != 6 || data[6] != 6 {
+			return netip.Addr{}, 0, 0, false
+		}
+		dst, _ = netip.AddrFromSlice(data[24:40])
+		seg = data[40:]
+	} else {
+		if len(data) < 20 || data[0]>>4 != 4 || data[9] != 6 {
+			return netip.Addr{}, 0, 0, false
+		}
+		ihl := int(data[0]&0x0f) * 4
+		if ihl < 20 || len(data) < ihl {
+			return netip.Addr{}, 0, 0, false
+		}
+		dst, _ = netip.AddrFromSlice(data[16:20])
+		seg = data[ihl:]
+	}
+	if len(seg) < 8 {
+		return netip.Addr{}, 0, 0, false
+	}
+	return dst, int(binary.BigEndian.Uint16(seg[0:2])), binary.BigEndian.Uint32(seg[4:8]), true
+}
+
+func peerAddr(addr net.Addr) (netip.Addr, bool) {
+	ipAddr, ok := addr.(*net.IPAddr)
+	if !ok {
+		return netip.Addr{}, false
+	}
+	a, ok := netip.AddrFromSlice(ipAddr.IP)
+	return a.Unmap(), ok
+}
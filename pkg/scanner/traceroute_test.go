@@ -0,0 +1,66 @@
+package scanner
+
+import (
+	"context"
+	"encoding/binary"
+	"net"
+	"net/netip"
+	"testing"
+
+	"github.com/NexusFireMan/gomap/v2/internal/testutil"
+)
+
+func TestQuotedTCPProbe(t *testing.T) {
+	seg := buildTCPSegment(net.IPv4(192, 0, 2, 2).To4(), net.IPv4(198, 51, 100, 7).To4(), 41000, 443, 0xdeadbeef, 0, tcpFlagSyn, nil)
+
+	v4 := make([]byte, 20, 28)
+	v4[0] = 0x45
+	v4[9] = 6
+	copy(v4[12:16], []byte{192, 0, 2, 2})
+	copy(v4[16:20], []byte{198, 51, 100, 7})
+	// Routers must quote at least the first 8 bytes of the TCP header.
+	v4 = append(v4, seg[:8]...)
+	dst, srcPort, seq, ok := quotedTCPProbe(v4, false)
+	if !ok || dst != netip.MustParseAddr("198.51.100.7") || srcPort != 41000 || seq != 0xdeadbeef {
+		t.Fatalf("ipv4 quote: dst=%v port=%d seq=%x ok=%v", dst, srcPort, seq, ok)
+	}
+
+	v6 := make([]byte, 40)
+	v6[0] = 0x60
+	v6[6] = 6
+	binary.BigEndian.PutUint16(v6[4:6], uint16(len(seg)))
+	copy(v6[24:40], netip.MustParseAddr("2001:db8::7").AsSlice())
+	v6 = append(v6, seg...)
+	dst, srcPort, seq, ok = quotedTCPProbe(v6, true)
+	if !ok || dst != netip.MustParseAddr("2001:db8::7") || srcPort != 41000 || seq != 0xdeadbeef {
+		t.Fatalf("ipv6 quote: dst=%v port=%d seq=%x ok=%v", dst, srcPort, seq, ok)
+	}
+
+	// UDP payloads and truncated quotes are not ours.
+	v4[9] = 17
+	if _, _, _, ok := quotedTCPProbe(v4, false); ok {
+		t.Fatal("expected udp quote to be rejected")
+	}
+	if _, _, _, ok := quotedTCPProbe(v6[:44], true); ok {
+		t.Fatal("expected truncated quote to be rejected")
+	}
+}
+
+func TestTracerReachesLoopback(t *testing.T) {
+	open := testutil.StartService(t, nil)
+
+	for _, src := range []SourceConfig{{}, {Interface: "lo", IP: net.ParseIP("127.0.0.1")}} {
+		tracer, err := NewTracer(TracerouteConfig{MaxHops: 4, Source: src})
+		if err != nil {
+			t.Skipf("raw sockets unavailable: %v", err)
+		}
+		route, err := tracer.Trace(context.Background(), "127.0.0.1", open)
+		tracer.Close()
+		if err != nil {
+			t.Fatalf("trace from %+v: %v", src, err)
+		}
+		if !route.Reached || len(route.Hops) != 1 || route.Hops[0].TTL != 1 || route.Hops[0].Addr != "127.0.0.1" {
+			t.Fatalf("unexpected route from %+v: %+v", src, route)
+		}
+	}
+}
@@ -2,10 +2,27 @@ package scanner
 
 import "time"
 
+// PortState describes how a probed port answered.
+type PortState string
+
+const (
+	// PortOpen means the port accepted a connection or answered a probe.
+	PortOpen PortState = "open"
+	// PortClosed means the host actively refused the port (TCP RST or ICMP port unreachable).
+	PortClosed PortState = "closed"
+	// PortFiltered means no usable answer arrived, usually because a firewall dropped the probe.
+	PortFiltered PortState = "filtered"
+	// PortOpenFiltered means silence that cannot distinguish open from filtered (UDP and stealth scans).
+	PortOpenFiltered PortState = "open|filtered"
+	// PortUnfiltered means the port is reachable but its open/closed state is unknown (ACK scans).
+	PortUnfiltered PortState = "unfiltered"
+)
+
 // ScanResult holds the result of a single port scan
 type ScanResult struct {
 	Port          int           `json:"port"`
 	IsOpen        bool          `json:"open"`
+	State         PortState     `json:"state,omitempty"`
 	ServiceName   string        `json:"service,omitempty"`
 	Version       string        `json:"version,omitempty"`
 	Hostname      string        `json:"hostname,omitempty"`
@@ -22,6 +39,26 @@ type ScanResult struct {
 	DetectionPath string        `json:"detection_path,omitempty"`
 }
 
+// EffectiveState returns the result state. Results built without a state predate
+// the port state model, when only open ports were reported, so they count as open.
+func (r ScanResult) EffectiveState() PortState {
+	if r.State != "" {
+		return r.State
+	}
+	return PortOpen
+}
+
+// OpenCount returns the number of open ports in a result set.
+func OpenCount(results []ScanResult) int {
+	count := 0
+	for _, r := range results {
+		if r.EffectiveState() == PortOpen {
+			count++
+		}
+	}
+	return count
+}
+
 // GetTop1000Ports returns the top 1000 most commonly used ports
 func GetTop1000Ports() []int {
 	// Curated top TCP ports used for quick scans.
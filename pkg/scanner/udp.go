@@ -2,12 +2,15 @@ package scanner
 
 import (
 	"bytes"
+	"context"
+	"errors"
 	"fmt"
 	"math/rand/v2"
 	"net"
 	"sort"
 	"strings"
 	"sync"
+	"syscall"
 	"time"
 )
 
@@ -20,8 +23,10 @@ func GetTopUDPPorts() []int {
 	})
 }
 
-// ScanUDP probes UDP ports and returns only ports that send a UDP response.
-func (s *Scanner) ScanUDP(ports []int, detectServices bool) []ScanResult {
+// ScanUDP probes UDP ports and returns ports that send a UDP response, plus
+// closed (ICMP port unreachable) or open|filtered (silent) ports when requested.
+func (s *Scanner) ScanUDP(ctx context.Context, ports []int, detectServices bool) []ScanResult {
+	s.scanCtx = ctx
 	if s.GhostMode {
 		rand.Shuffle(len(ports), func(i, j int) {
 			ports[i], ports[j] = ports[j], ports[i]
@@ -47,50 +52,67 @@ func (s *Scanner) ScanUDP(ports []int, detectServices bool) []ScanResult {
 		go func() {
 			defer wg.Done()
 			for port := range portsChan {
+				if ctx.Err() != nil {
+					continue
+				}
 				if s.GhostMode {
 					s.addJitter()
 				}
-				if rateLimiter != nil {
-					<-rateLimiter
+				if !waitRateSlot(ctx, rateLimiter) {
+					continue
+				}
+				result := s.scanUDPPort(ctx, port, detectServices)
+				if ctx.Err() != nil && !result.IsOpen {
+					continue
 				}
-				resultsChan <- s.scanUDPPort(port, detectServices)
+				s.emitResult(result)
+				resultsChan <- result
 			}
 		}()
 	}
 
-	for _, port := range ports {
-		portsChan <- port
-	}
-	close(portsChan)
+	feedPorts(ctx, portsChan, ports)
 
 	wg.Wait()
 	close(resultsChan)
 
-	openPorts := make([]ScanResult, 0)
+	reported := make([]ScanResult, 0)
 	for result := range resultsChan {
-		if result.IsOpen {
-			openPorts = append(openPorts, result)
+		if s.reportsState(result.EffectiveState()) {
+			reported = append(reported, result)
 		}
 	}
 
-	sort.Slice(openPorts, func(i, j int) bool {
-		return openPorts[i].Port < openPorts[j].Port
+	sort.Slice(reported, func(i, j int) bool {
+		return reported[i].Port < reported[j].Port
 	})
-	return dedupeOpenResults(openPorts)
+	return dedupeOpenResults(reported)
 }
 
-func (s *Scanner) scanUDPPort(port int, detectServices bool) ScanResult {
+func (s *Scanner) scanUDPPort(ctx context.Context, port int, detectServices bool) ScanResult {
 	address := net.JoinHostPort(s.Host, fmt.Sprintf("%d", port))
-	start := time.Now()
 	probe := udpProbePayload(port)
 
 	var (
 		response []byte
 		err      error
+		// latency is the exchange time of the last attempt, without the
+		// waits for the rate limiter and the dial budget.
+		latency time.Duration
 	)
 	for attempt := 0; attempt <= s.Retries; attempt++ {
-		response, err = s.exchangeUDP(address, probe)
-		if err == nil {
+		if err = s.limiter.Wait(ctx); err != nil {
+			break
+		}
+		if !s.budget.acquire(ctx) {
+			err = ctx.Err()
+			break
+		}
+		attemptStart := time.Now()
+		response, err = s.exchangeUDP(ctx, address, probe)
+		latency = time.Since(attemptStart)
+		s.budget.release()
+		if err == nil || ctx.Err() != nil {
 			break
 		}
 		if attempt < s.Retries && !s.GhostMode {
@@ -98,19 +120,19 @@ func (s *Scanner) scanUDPPort(port int, detectServices bool) ScanResult {
 		}
 	}
 
-	latency := time.Since(start)
 	latencyMs := latency.Milliseconds()
 	if latencyMs == 0 {
 		latencyMs = 1
 	}
 	if err != nil {
-		return ScanResult{Port: port, IsOpen: false, Latency: latency, LatencyMs: latencyMs}
+		return ScanResult{Port: port, IsOpen: false, State: udpErrorState(err), Latency: latency, LatencyMs: latencyMs}
 	}
 
 	service, version, confidence, evidence := s.classifyUDPResponse(port, response, detectServices)
 	return ScanResult{
 		Port:          port,
 		IsOpen:        true,
+		State:         PortOpen,
 		ServiceName:   service,
 		Version:       version,
 		Latency:       latency,
@@ -121,8 +143,8 @@ func (s *Scanner) scanUDPPort(port int, detectServices bool) ScanResult {
 	}
 }
 
-func (s *Scanner) exchangeUDP(address string, payload []byte) ([]byte, error) {
-	conn, err := net.DialTimeout("udp", address, s.currentTimeout())
+func (s *Scanner) exchangeUDP(ctx context.Context, address string, payload []byte) ([]byte, error) {
+	conn, err := s.dialContext(ctx, "udp", address, s.currentTimeout())
 	if err != nil {
 		return nil, err
 	}
@@ -144,6 +166,15 @@ func (s *Scanner) exchangeUDP(address string, payload []byte) ([]byte, error) {
 	return buf[:n], nil
 }
 
+// udpErrorState maps a failed UDP exchange to a port state. Connected UDP sockets
+// surface ICMP port unreachable as ECONNREFUSED; silence cannot tell open from filtered.
+func udpErrorState(err error) PortState {
+	if errors.Is(err, syscall.ECONNREFUSED) {
+		return PortClosed
+	}
+	return PortOpenFiltered
+}
+
 func (s *Scanner) classifyUDPResponse(port int, response []byte, detectServices bool) (service, version, confidence, evidence string) {
 	service = s.PortManager.GetServiceName(port, "")
 	if service == "" {
@@ -1,6 +1,7 @@
 package scanner
 
 import (
+	"context"
 	"net"
 	"testing"
 	"time"
@@ -29,7 +30,7 @@ func TestScanUDPDetectsResponsivePort(t *testing.T) {
 	s := NewScanner("127.0.0.1", false)
 	s.Configure(ScanConfig{Timeout: 200 * time.Millisecond, NumWorkers: 1})
 
-	results := s.ScanUDP([]int{port}, true)
+	results := s.ScanUDP(context.Background(), []int{port}, true)
 	if len(results) != 1 {
 		t.Fatalf("expected one udp result, got %d (%v)", len(results), results)
 	}